@@ -0,0 +1,301 @@
+// Command struccy-gen generates reflection-free accessors for structs with `readxs`/`writexs` tags
+// (and their `readif`/`writeif` conditions):
+//
+//	func (u *User) ToMapForRoles(roles []string) map[string]any
+//	func (u *User) ApplyUpdate(updateMap map[string]any, roles []string) error
+//
+// struccy.StructToMapFieldsWithReadXS and struccy.ApplyMapUpdate detect the methods through the
+// struccy.RoleMapper and struccy.RoleUpdater interfaces and use them instead of reflection. The other
+// struccy functions, such as struccy.MergeMapStringFieldsToStruct, do not call the generated methods.
+//
+// It is meant to be run by go generate:
+//
+//	//go:generate go run github.com/itsatony/struccy/cmd/struccy-gen -type User,Account
+//
+// Without -type, the accessors are generated for all tagged structs declared in $GOFILE.
+// The output is written to <file>_struccy.go (or <file>_struccy_test.go for test files) next to $GOFILE.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const generatedHeader = "// Code generated by struccy-gen. DO NOT EDIT."
+
+func main() {
+	typeList := flag.String("type", "", "comma separated struct type names (default: all tagged structs of $GOFILE)")
+	output := flag.String("output", "", "output file (default: <$GOFILE>_struccy.go)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: struccy-gen [-type T1,T2] [-output file] [dir]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	goFile := os.Getenv("GOFILE")
+	var typeNames []string
+	if *typeList != "" {
+		typeNames = strings.Split(*typeList, ",")
+	}
+	if goFile == "" && len(typeNames) == 0 {
+		fail(errors.New("-type is required when not run by go generate"))
+	}
+
+	src, err := generate(dir, goFile, typeNames)
+	if err != nil {
+		fail(err)
+	}
+	outputFile := *output
+	if outputFile == "" {
+		outputFile = outputFileName(goFile, typeNames)
+	}
+	if err := os.WriteFile(filepath.Join(dir, outputFile), src, 0o644); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "struccy-gen:", err)
+	os.Exit(1)
+}
+
+// outputFileName derives the output file from $GOFILE, or from the first type name.
+func outputFileName(goFile string, typeNames []string) string {
+	if goFile == "" {
+		return strings.ToLower(typeNames[0]) + "_struccy.go"
+	}
+	if base, ok := strings.CutSuffix(goFile, "_test.go"); ok {
+		return base + "_struccy_test.go"
+	}
+	return strings.TrimSuffix(goFile, ".go") + "_struccy.go"
+}
+
+type genField struct {
+	Name      string
+	JSONName  string
+	ReadXS    string
+	WriteXS   string
+	ReadIf    string
+	WriteIf   string
+	Immutable bool // the `@immutable` marker, the field is only set while zero
+}
+
+type genType struct {
+	Name     string
+	Receiver string
+	Fields   []genField
+	WriteIfs bool // some field has a `writeif` condition
+}
+
+// generate returns the formatted accessors of the types in the package of goFile in dir. Without
+// type names, all structs declared in goFile with a `readxs` or `writexs` tag are generated.
+func generate(dir string, goFile string, typeNames []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	files, err := parsePackage(fset, dir, goFile)
+	if err != nil {
+		return nil, err
+	}
+	// type errors (e.g. of imports that cannot be resolved) are ignored,
+	// the struct fields and tags are known anyway
+	config := types.Config{Importer: importer.ForCompiler(fset, "source", nil), Error: func(error) {}}
+	pkg, _ := config.Check(files[0].Name.Name, fset, files, nil)
+
+	var objects []*types.TypeName
+	if len(typeNames) > 0 {
+		for _, name := range typeNames {
+			object, ok := pkg.Scope().Lookup(strings.TrimSpace(name)).(*types.TypeName)
+			if !ok || !isStruct(object) {
+				return nil, fmt.Errorf("%s is not a struct type of package %s", name, pkg.Name())
+			}
+			objects = append(objects, object)
+		}
+	} else {
+		for _, name := range pkg.Scope().Names() {
+			object, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+			if ok && isStruct(object) && filepath.Base(fset.Position(object.Pos()).Filename) == goFile && hasAccessTags(object) {
+				objects = append(objects, object)
+			}
+		}
+		sort.Slice(objects, func(i, j int) bool { return objects[i].Pos() < objects[j].Pos() })
+	}
+
+	genTypes := make([]genType, 0, len(objects))
+	for _, object := range objects {
+		genTypes = append(genTypes, newGenType(object))
+	}
+	var buf bytes.Buffer
+	if err := accessorTemplate.Execute(&buf, map[string]any{"Package": pkg.Name(), "Types": genTypes}); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// parsePackage parses the files of the package goFile belongs to, skipping files generated by struccy-gen.
+// Test files are only included if goFile is one.
+func parsePackage(fset *token.FileSet, dir string, goFile string) ([]*ast.File, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	withTests := strings.HasSuffix(goFile, "_test.go")
+	packageName := ""
+	if goFile != "" {
+		file, err := parser.ParseFile(fset, filepath.Join(dir, goFile), nil, parser.PackageClauseOnly)
+		if err != nil {
+			return nil, err
+		}
+		packageName = file.Name.Name
+	}
+
+	var files []*ast.File
+	for _, path := range paths {
+		if !withTests && strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if packageName == "" {
+			packageName = file.Name.Name
+		}
+		if file.Name.Name != packageName || isGenerated(file) {
+			continue
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return files, nil
+}
+
+func isGenerated(file *ast.File) bool {
+	for _, group := range file.Comments {
+		if group.Pos() > file.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if comment.Text == generatedHeader {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isStruct reports whether the object is a non-generic named struct type.
+func isStruct(object *types.TypeName) bool {
+	named, ok := object.Type().(*types.Named)
+	if !ok || object.IsAlias() || named.TypeParams().Len() > 0 {
+		return false
+	}
+	_, ok = named.Underlying().(*types.Struct)
+	return ok
+}
+
+func hasAccessTags(object *types.TypeName) bool {
+	structType := object.Type().Underlying().(*types.Struct)
+	for i := 0; i < structType.NumFields(); i++ {
+		tag := reflect.StructTag(structType.Tag(i))
+		if _, ok := tag.Lookup("readxs"); ok {
+			return true
+		}
+		if _, ok := tag.Lookup("writexs"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// newGenType collects the exported direct fields, the fields struccy keys by Go name.
+func newGenType(object *types.TypeName) genType {
+	structType := object.Type().Underlying().(*types.Struct)
+	receiver := []rune(object.Name())[0]
+	generated := genType{Name: object.Name(), Receiver: string(unicode.ToLower(receiver))}
+	for i := 0; i < structType.NumFields(); i++ {
+		field := structType.Field(i)
+		if !field.Exported() {
+			continue
+		}
+		tag := reflect.StructTag(structType.Tag(i))
+		jsonName := strings.Split(tag.Get("json"), ",")[0]
+		if jsonName == "" || jsonName == "-" {
+			jsonName = field.Name()
+		}
+		generated.Fields = append(generated.Fields, genField{
+			Name:      field.Name(),
+			JSONName:  jsonName,
+			ReadXS:    tag.Get("readxs"),
+			WriteXS:   tag.Get("writexs"),
+			ReadIf:    tag.Get("readif"),
+			WriteIf:   tag.Get("writeif"),
+			Immutable: slices.Contains(strings.Split(tag.Get("writexs"), ","), "@immutable"),
+		})
+		generated.WriteIfs = generated.WriteIfs || tag.Get("writeif") != ""
+	}
+	return generated
+}
+
+var accessorTemplate = template.Must(template.New("accessors").Parse(generatedHeader + `
+
+package {{.Package}}
+
+import "github.com/itsatony/struccy"
+{{range .Types}}{{$recv := .Receiver}}
+// ToMapForRoles returns the fields of {{.Name}} the roles may read, like struccy.StructToMapFieldsWithReadXS.
+func ({{$recv}} *{{.Name}}) ToMapForRoles(roles []string) map[string]any {
+	fields := make(map[string]any, {{len .Fields}})
+{{- range .Fields}}
+{{- if and (eq .ReadXS "*") (not .ReadIf)}}
+	fields[{{printf "%q" .Name}}] = {{$recv}}.{{.Name}}
+{{- else}}
+	if {{if ne .ReadXS "*"}}struccy.IsFieldAccessAllowed(roles, {{printf "%q" .ReadXS}}){{if .ReadIf}} && {{end}}{{end}}{{if .ReadIf}}struccy.FieldConditionHolds({{$recv}}, {{printf "%q" .ReadIf}}, roles){{end}} {
+		fields[{{printf "%q" .Name}}] = {{$recv}}.{{.Name}}
+	}
+{{- end}}
+{{- end}}
+	return fields
+}
+
+// ApplyUpdate sets the fields of {{.Name}} the roles may write from the map keyed by Go field names,
+// like struccy.ApplyMapUpdate without the validation.
+func ({{$recv}} *{{.Name}}) ApplyUpdate(updateMap map[string]any, roles []string) error {
+	fieldErrs := &struccy.FieldErrors{}
+{{- if .WriteIfs}}
+	// the writeif conditions hold for {{.Name}} as it was before the update
+{{- range .Fields}}{{if .WriteIf}}
+	writeIf{{.Name}} := struccy.FieldConditionHolds({{$recv}}, {{printf "%q" .WriteIf}}, roles)
+{{- end}}{{end}}
+{{- end}}
+{{- range .Fields}}
+	if value, ok := updateMap[{{printf "%q" .Name}}]; ok{{if ne .WriteXS "*"}} && struccy.IsWriteAccessAllowed(roles, {{printf "%q" .WriteXS}}){{end}}{{if .WriteIf}} && writeIf{{.Name}}{{end}}{{if .Immutable}} && struccy.IsZeroValue({{$recv}}.{{.Name}}){{end}} {
+		struccy.SetFieldValue(&{{$recv}}.{{.Name}}, value, {{printf "%q" .Name}}, {{printf "%q" .JSONName}}, fieldErrs)
+	}
+{{- end}}
+	if fieldErrs.Len() > 0 {
+		return fieldErrs
+	}
+	return nil
+}
+{{end}}`))
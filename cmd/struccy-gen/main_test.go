@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	src, err := generate("testdata/models", "models.go", nil)
+	assert.NoError(t, err)
+	code := string(src)
+	assert.True(t, strings.HasPrefix(code, generatedHeader+"\n\npackage models\n"))
+	// Audit has no access tags and Pair is generic
+	assert.Equal(t, 2, strings.Count(code, "func (a *Account)"))
+	assert.NotContains(t, code, "*Audit")
+	assert.NotContains(t, code, "*Pair")
+	assert.Contains(t, code, `	fields["ID"] = a.ID
+	if struccy.IsFieldAccessAllowed(roles, "admin") {
+		fields["Owner"] = a.Owner
+	}
+	fields["CreatedAt"] = a.CreatedAt
+	if struccy.IsFieldAccessAllowed(roles, "") {
+		fields["Audit"] = a.Audit
+	}
+	return fields`)
+	assert.Contains(t, code, `	if value, ok := updateMap["Owner"]; ok && struccy.IsWriteAccessAllowed(roles, "admin") {
+		struccy.SetFieldValue(&a.Owner, value, "Owner", "owner", fieldErrs)
+	}`)
+	assert.NotContains(t, code, "internal")
+
+	src, err = generate("testdata/models", "", []string{"Audit"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), "func (a *Audit) ApplyUpdate(")
+
+	_, err = generate("testdata/models", "", []string{"Pair"})
+	assert.Error(t, err)
+	_, err = generate("testdata/models", "", []string{"Missing"})
+	assert.Error(t, err)
+}
+
+func TestGenerateConditions(t *testing.T) {
+	src, err := generate("testdata/models", "", []string{"Post"})
+	assert.NoError(t, err)
+	code := string(src)
+	assert.Contains(t, code, `	fields["Status"] = p.Status
+	if struccy.FieldConditionHolds(p, "Status=published@!editor", roles) {
+		fields["Content"] = p.Content
+	}
+	if struccy.IsFieldAccessAllowed(roles, "editor") {
+		fields["Notes"] = p.Notes
+	}`)
+	// the conditions are evaluated before any field is set
+	assert.Contains(t, code, `	fieldErrs := &struccy.FieldErrors{}
+	// the writeif conditions hold for Post as it was before the update
+	writeIfContent := struccy.FieldConditionHolds(p, "Status!=archived", roles)
+	writeIfNotes := struccy.FieldConditionHolds(p, "!Locked", roles)
+	if value, ok := updateMap["Status"]; ok && struccy.IsWriteAccessAllowed(roles, "editor") {`)
+	assert.Contains(t, code, `	if value, ok := updateMap["Content"]; ok && struccy.IsWriteAccessAllowed(roles, "editor") && writeIfContent {`)
+	// immutable fields are only set while zero, system fields are never set
+	assert.Contains(t, code, `	if value, ok := updateMap["Slug"]; ok && struccy.IsWriteAccessAllowed(roles, "editor,@immutable") && struccy.IsZeroValue(p.Slug) {`)
+	assert.Contains(t, code, `	if value, ok := updateMap["Views"]; ok && struccy.IsWriteAccessAllowed(roles, "@system") {`)
+}
+
+// TestGeneratedFileUpToDate checks that the accessors used by the struccy tests match the generator.
+func TestGeneratedFileUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "..")
+	src, err := generate(dir, "generated_test.go", []string{"genUser"})
+	assert.NoError(t, err)
+	committed, err := os.ReadFile(filepath.Join(dir, "generated_struccy_test.go"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(committed), string(src), "run go generate in the module root")
+}
+
+func TestOutputFileName(t *testing.T) {
+	assert.Equal(t, "user_struccy.go", outputFileName("user.go", nil))
+	assert.Equal(t, "user_struccy_test.go", outputFileName("user_test.go", nil))
+	assert.Equal(t, "account_struccy.go", outputFileName("", []string{"Account", "User"}))
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type Account struct {
+	ID        string    `json:"id" readxs:"*" writexs:"admin"`
+	Owner     string    `json:"owner,omitempty" readxs:"admin" writexs:"admin"`
+	CreatedAt time.Time `readxs:"*"`
+	Audit
+	internal string
+}
+
+type Audit struct {
+	Revision int
+}
+
+type Pair[T any] struct {
+	Left  T `readxs:"*"`
+	Right T `readxs:"*"`
+}
+
+type Post struct {
+	Status  string `readxs:"*" writexs:"editor"`
+	Content string `readxs:"*" writexs:"editor" readif:"Status=published@!editor" writeif:"Status!=archived"`
+	Notes   string `readxs:"editor" writexs:"editor" writeif:"!Locked"`
+	Locked  bool   `readxs:"*" writexs:"admin"`
+	Slug    string `readxs:"*" writexs:"editor,@immutable"`
+	Views   int    `readxs:"*" writexs:"@system"`
+}
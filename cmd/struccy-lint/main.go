@@ -0,0 +1,15 @@
+// Command struccy-lint checks the readxs/writexs struct tags of struccy (see package xslint).
+// It runs standalone on package patterns or as a go vet tool:
+//
+//	struccy-lint -roles=admin,user,self ./...
+//	go vet -vettool=$(which struccy-lint) -roles=admin,user,self ./...
+package main
+
+import (
+	"github.com/itsatony/struccy/xslint"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(xslint.Analyzer)
+}
@@ -0,0 +1,392 @@
+// Command struccy prints the access matrix of a struct type: which role may read (`readxs`) and
+// write (`writexs`) each field, including the fields of nested and embedded structs.
+//
+//	struccy -type User [-roles admin,self,guest] [-format table|md|csv|json] [-naming json|go] [package]
+//
+// The package defaults to the one in the current directory. Without -roles, the columns are the
+// roles named in the tags of the type. Nested fields are only accessible if every field along the
+// path allows it, as for struccy.IsAllowedPath. With -naming json, fields are named and embedded
+// structs promoted like encoding/json (and struccy.JSONFieldNames); with -naming go, embedded
+// structs are fields of their own, like struccy.GoFieldNames.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/itsatony/struccy"
+	"golang.org/x/tools/go/packages"
+)
+
+func main() {
+	typeName := flag.String("type", "", "struct type name")
+	roleList := flag.String("roles", "", "comma separated roles (default: all roles named in the tags)")
+	format := flag.String("format", "table", "output format: table, md, csv or json")
+	naming := flag.String("naming", "json", "field names: json or go")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: struccy -type T [-roles r1,r2] [-format table|md|csv|json] [-naming json|go] [package]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	pattern := "."
+	if flag.NArg() > 0 {
+		pattern = flag.Arg(0)
+	}
+	var roles []string
+	if *roleList != "" {
+		roles = strings.Split(*roleList, ",")
+	}
+
+	named, err := loadType(pattern, *typeName)
+	if err != nil {
+		fail(err)
+	}
+	m, err := buildMatrix(named, roles, *naming)
+	if err != nil {
+		fail(err)
+	}
+	if err := writeMatrix(os.Stdout, m, *format); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "struccy:", err)
+	os.Exit(1)
+}
+
+// loadType loads the package matching pattern and looks up the named struct type in it.
+func loadType(pattern string, typeName string) (*types.Named, error) {
+	// the dependencies are type-checked from source, so the export data format of the toolchain does not matter
+	config := &packages.Config{Mode: packages.NeedName | packages.NeedTypes | packages.NeedSyntax | packages.NeedImports | packages.NeedDeps}
+	pkgs, err := packages.Load(config, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("%s matches %d packages, expected one", pattern, len(pkgs))
+	}
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return nil, pkg.Errors[0]
+	}
+	object, ok := pkg.Types.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("type %s not found in package %s", typeName, pkg.PkgPath)
+	}
+	named, ok := object.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() > 0 {
+		return nil, fmt.Errorf("%s is not a non-generic named type", typeName)
+	}
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		return nil, fmt.Errorf("%s is not a struct type", typeName)
+	}
+	return named, nil
+}
+
+// matrix is the access matrix of a struct type.
+type matrix struct {
+	Type   string      `json:"type"`
+	Roles  []string    `json:"roles"`
+	Fields []matrixRow `json:"fields"`
+}
+
+// matrixRow is the access of a single (nested) field. Read and Write list the roles of the
+// matrix allowed to access the field, taking the fields along the path into account.
+type matrixRow struct {
+	Path    string   `json:"path"`
+	Type    string   `json:"type"`
+	ReadXS  string   `json:"readxs"`
+	WriteXS string   `json:"writexs"`
+	Read    []string `json:"read"`
+	Write   []string `json:"write"`
+
+	// the tags of the fields along the path, including this one
+	readPath  []string
+	writePath []string
+}
+
+// buildMatrix collects the fields of the struct type and evaluates their access for the roles.
+func buildMatrix(named *types.Named, roles []string, naming string) (*matrix, error) {
+	if naming != "json" && naming != "go" {
+		return nil, fmt.Errorf("unknown naming %q, expected json or go", naming)
+	}
+	qualifier := types.RelativeTo(named.Obj().Pkg())
+	collector := &fieldCollector{jsonNaming: naming == "json", qualifier: qualifier, ancestors: map[*types.Named]bool{}}
+	collector.collect(named, "", nil, nil)
+
+	if roles == nil {
+		roles = tagRoles(collector.rows)
+	}
+	for i := range collector.rows {
+		row := &collector.rows[i]
+		row.Read = allowedRoles(roles, row.readPath, struccy.IsFieldAccessAllowed)
+		row.Write = allowedRoles(roles, row.writePath, struccy.IsWriteAccessAllowed)
+	}
+	return &matrix{Type: types.TypeString(named, nil), Roles: roles, Fields: collector.rows}, nil
+}
+
+type fieldCollector struct {
+	jsonNaming bool
+	qualifier  types.Qualifier
+	ancestors  map[*types.Named]bool
+	rows       []matrixRow
+}
+
+// collectedField is a field of a struct, with the fields promoted from embedded structs inlined.
+type collectedField struct {
+	name    string
+	field   *types.Var
+	readXS  string
+	writeXS string
+	parents []reflect.StructTag // tags of the embedded structs the field is promoted through
+}
+
+// collect adds the rows of the fields of the struct type t below the path.
+func (c *fieldCollector) collect(t types.Type, path string, readPath []string, writePath []string) {
+	named, _ := t.(*types.Named)
+	if named != nil {
+		if c.ancestors[named] {
+			return
+		}
+		c.ancestors[named] = true
+		defer delete(c.ancestors, named)
+	}
+
+	for _, field := range c.fields(t.Underlying().(*types.Struct)) {
+		row := matrixRow{
+			Path:      field.name,
+			Type:      types.TypeString(field.field.Type(), c.qualifier),
+			ReadXS:    field.readXS,
+			WriteXS:   field.writeXS,
+			readPath:  append(append([]string{}, readPath...), field.readXS),
+			writePath: append(append([]string{}, writePath...), field.writeXS),
+		}
+		// the access tags of embedded structs apply to their promoted fields as well
+		for _, tag := range field.parents {
+			if xs, ok := tag.Lookup("readxs"); ok {
+				row.readPath = append(row.readPath, xs)
+			}
+			if xs, ok := tag.Lookup("writexs"); ok {
+				row.writePath = append(row.writePath, xs)
+			}
+		}
+		if path != "" {
+			row.Path = path + "." + field.name
+		}
+		c.rows = append(c.rows, row)
+		if nested := nestedStruct(field.field.Type()); nested != nil {
+			c.collect(nested, row.Path, row.readPath, row.writePath)
+		}
+	}
+}
+
+// fields returns the exported fields of the struct. With JSON naming, fields are named by their json
+// tag, `json:"-"` fields are skipped and the fields of embedded structs without a tag name are
+// promoted, the shallowest field winning a name; otherwise embedded structs are regular fields.
+func (c *fieldCollector) fields(structType *types.Struct) []collectedField {
+	type queued struct {
+		structType *types.Struct
+		parents    []reflect.StructTag
+	}
+	var fields []collectedField
+	taken := map[string]bool{}
+	next := []queued{{structType: structType}}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		var level []collectedField
+		for _, q := range current {
+			s := q.structType
+			for i := 0; i < s.NumFields(); i++ {
+				field := s.Field(i)
+				tag := reflect.StructTag(s.Tag(i))
+				name := field.Name()
+				if c.jsonNaming {
+					jsonTag := tag.Get("json")
+					if jsonTag == "-" {
+						continue
+					}
+					if tagName, _, _ := strings.Cut(jsonTag, ","); tagName != "" {
+						name = tagName
+					} else if field.Embedded() {
+						if embedded, ok := derefType(field.Type()).Underlying().(*types.Struct); ok {
+							next = append(next, queued{structType: embedded, parents: append(append([]reflect.StructTag{}, q.parents...), tag)})
+							continue
+						}
+					}
+				}
+				if !field.Exported() || taken[name] {
+					continue
+				}
+				level = append(level, collectedField{name: name, field: field, readXS: tag.Get("readxs"), writeXS: tag.Get("writexs"), parents: q.parents})
+			}
+		}
+		for _, field := range level {
+			taken[field.name] = true
+		}
+		fields = append(fields, level...)
+	}
+	return fields
+}
+
+// nestedStruct returns the struct type whose fields are listed below a field of type t, looking
+// through pointers, slices, arrays and map values. Types with their own JSON or text encoding
+// (e.g. time.Time) are not expanded.
+func nestedStruct(t types.Type) types.Type {
+	for {
+		switch typ := t.(type) {
+		case *types.Pointer:
+			t = typ.Elem()
+		case *types.Slice:
+			t = typ.Elem()
+		case *types.Array:
+			t = typ.Elem()
+		case *types.Map:
+			t = typ.Elem()
+		default:
+			if _, ok := t.Underlying().(*types.Struct); !ok || hasOwnEncoding(t) {
+				return nil
+			}
+			return t
+		}
+	}
+}
+
+func hasOwnEncoding(t types.Type) bool {
+	methods := types.NewMethodSet(types.NewPointer(t))
+	return methods.Lookup(nil, "MarshalJSON") != nil || methods.Lookup(nil, "MarshalText") != nil
+}
+
+func derefType(t types.Type) types.Type {
+	if pointer, ok := t.(*types.Pointer); ok {
+		return pointer.Elem()
+	}
+	return t
+}
+
+// tagRoles returns the sorted roles named in the tags of the rows, without negations.
+func tagRoles(rows []matrixRow) []string {
+	seen := map[string]bool{}
+	for _, row := range rows {
+		for _, tag := range []string{row.ReadXS, row.WriteXS} {
+			for _, role := range strings.Split(tag, ",") {
+				role = strings.TrimPrefix(role, "!")
+				if role != "" && role != "*" {
+					seen[role] = true
+				}
+			}
+		}
+	}
+	roles := make([]string, 0, len(seen))
+	for role := range seen {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// allowedRoles returns the roles allowed by all tags.
+func allowedRoles(roles []string, tags []string, allow func(roles []string, tagValue string) bool) []string {
+	allowed := []string{}
+	for _, role := range roles {
+		ok := true
+		for _, tag := range tags {
+			ok = ok && allow([]string{role}, tag)
+		}
+		if ok {
+			allowed = append(allowed, role)
+		}
+	}
+	return allowed
+}
+
+// cell returns the table cell of the role: R, W, RW or -.
+func (row *matrixRow) cell(role string) string {
+	var access string
+	if contains(row.Read, role) {
+		access += "R"
+	}
+	if contains(row.Write, role) {
+		access += "W"
+	}
+	if access == "" {
+		return "-"
+	}
+	return access
+}
+
+func contains(list []string, s string) bool {
+	for _, entry := range list {
+		if entry == s {
+			return true
+		}
+	}
+	return false
+}
+
+// records returns the header and the rows of the matrix as table cells.
+func (m *matrix) records() [][]string {
+	records := [][]string{append([]string{"field", "type"}, m.Roles...)}
+	for i := range m.Fields {
+		row := &m.Fields[i]
+		record := []string{row.Path, row.Type}
+		for _, role := range m.Roles {
+			record = append(record, row.cell(role))
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func writeMatrix(w io.Writer, m *matrix, format string) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for i, record := range m.records() {
+			if i == 0 {
+				record[0], record[1] = "FIELD", "TYPE"
+			}
+			fmt.Fprintln(tw, strings.Join(record, "\t"))
+		}
+		return tw.Flush()
+	case "md":
+		for i, record := range m.records() {
+			for j := range record {
+				record[j] = strings.ReplaceAll(record[j], "|", `\|`)
+			}
+			if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(record, " | ")); err != nil {
+				return err
+			}
+			if i == 0 {
+				separator := strings.Repeat(" --- |", len(record))
+				if _, err := fmt.Fprintf(w, "|%s\n", separator); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case "csv":
+		return csv.NewWriter(w).WriteAll(m.records())
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(m)
+	}
+	return errors.New("unknown format " + format + ", expected table, md, csv or json")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildMatrix(t *testing.T) {
+	named, err := loadType("./testdata/models", "User")
+	assert.NoError(t, err)
+
+	m, err := buildMatrix(named, nil, "json")
+	assert.NoError(t, err)
+	assert.Equal(t, "github.com/itsatony/struccy/cmd/struccy/testdata/models.User", m.Type)
+	assert.Equal(t, []string{"admin", "guest", "self"}, m.Roles)
+	// Password is `json:"-"`, Friends is not expanded again and the Audit fields are promoted
+	assert.Equal(t, [][]string{
+		{"field", "type", "admin", "guest", "self"},
+		{"id", "string", "RW", "R", "R"},
+		{"email", "string", "R", "-", "RW"},
+		{"createdAt", "time.Time", "R", "R", "R"},
+		{"address", "*Address", "RW", "-", "RW"},
+		{"address.city", "string", "RW", "-", "RW"},
+		{"address.note|private", "string", "RW", "-", "-"},
+		{"friends", "[]User", "-", "-", "R"},
+		{"revision", "int", "R", "-", "-"},
+	}, m.records())
+
+	m, err = buildMatrix(named, []string{"self"}, "go")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"field", "type", "self"},
+		{"ID", "string", "R"},
+		{"Email", "string", "RW"},
+		{"Password", "string", "W"},
+		{"CreatedAt", "time.Time", "R"},
+		{"Address", "*Address", "RW"},
+		{"Address.City", "string", "RW"},
+		{"Address.Note", "string", "-"},
+		{"Friends", "[]User", "R"},
+		{"Audit", "Audit", "-"},
+		{"Audit.Revision", "int", "-"},
+	}, m.records())
+
+	_, err = buildMatrix(named, nil, "yaml")
+	assert.Error(t, err)
+
+	// the access tags of an embedded struct apply to its promoted fields
+	named, err = loadType("./testdata/models", "Tenant")
+	assert.NoError(t, err)
+	m, err = buildMatrix(named, []string{"admin", "guest"}, "json")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"field", "type", "admin", "guest"},
+		{"name", "string", "R", "R"},
+		{"owner", "string", "RW", "W"},
+	}, m.records())
+}
+
+func TestLoadTypeErrors(t *testing.T) {
+	_, err := loadType("./testdata/models", "Missing")
+	assert.Error(t, err)
+	_, err = loadType("./testdata/models", "Role")
+	assert.Error(t, err)
+}
+
+func TestWriteMatrix(t *testing.T) {
+	named, err := loadType("./testdata/models", "Address")
+	assert.NoError(t, err)
+	m, err := buildMatrix(named, []string{"admin", "guest"}, "json")
+	assert.NoError(t, err)
+
+	write := func(format string) string {
+		var buf bytes.Buffer
+		assert.NoError(t, writeMatrix(&buf, m, format))
+		return buf.String()
+	}
+	assert.Equal(t, "FIELD         TYPE    admin  guest\ncity          string  RW     RW\nnote|private  string  RW     -\n", write("table"))
+	assert.Equal(t, "| field | type | admin | guest |\n| --- | --- | --- | --- |\n| city | string | RW | RW |\n| note\\|private | string | RW | - |\n", write("md"))
+	assert.Equal(t, "field,type,admin,guest\ncity,string,RW,RW\nnote|private,string,RW,-\n", write("csv"))
+
+	var decoded map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(write("json")), &decoded))
+	assert.Equal(t, map[string]any{
+		"path": "note|private", "type": "string", "readxs": "admin", "writexs": "admin",
+		"read": []any{"admin"}, "write": []any{"admin"},
+	}, decoded["fields"].([]any)[1])
+
+	assert.Error(t, writeMatrix(&bytes.Buffer{}, m, "xml"))
+}
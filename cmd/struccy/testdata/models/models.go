@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type User struct {
+	ID        string    `json:"id" readxs:"*" writexs:"admin"`
+	Email     string    `json:"email" readxs:"self,admin" writexs:"self"`
+	Password  string    `json:"-" readxs:"" writexs:"self"`
+	CreatedAt time.Time `json:"createdAt" readxs:"*"`
+	Address   *Address  `json:"address" readxs:"!guest" writexs:"self,admin"`
+	Friends   []User    `json:"friends" readxs:"self"`
+	Audit
+	internal string
+}
+
+type Address struct {
+	City string `json:"city" readxs:"*" writexs:"*"`
+	Note string `json:"note|private" readxs:"admin" writexs:"admin"`
+}
+
+type Audit struct {
+	Revision int `json:"revision" readxs:"admin"`
+}
+
+type Tenant struct {
+	Name string `json:"name" readxs:"*"`
+	Base `readxs:"admin"`
+}
+
+type Base struct {
+	Owner string `json:"owner" readxs:"*" writexs:"*"`
+}
+
+type Role string
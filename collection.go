@@ -0,0 +1,237 @@
+package struccy
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// ErrInvalidStructSlice is returned when a collection is not a slice of structs or struct pointers.
+var ErrInvalidStructSlice = errors.New("value must be a slice of structs or struct pointers")
+
+// minParallelChunk is the smallest number of items a worker of WithParallelism projects.
+const minParallelChunk = 512
+
+var roleMapperType = reflect.TypeOf((*RoleMapper)(nil)).Elem()
+
+// StructSliceToMapsWithReadXS converts every element of a slice (or array) of structs or struct pointers
+// into a map like StructToMapFieldsWithReadXS; nil elements become nil maps. The field plans and the
+// `readxs` checks are resolved once for the element type, not per element, and generated ToMapForRoles
+// methods are used when no naming is selected. Large slices can be split across goroutines with WithParallelism.
+func StructSliceToMapsWithReadXS(slice any, xsList []string, opts ...ConvertOption) ([]map[string]any, error) {
+	sliceValue := reflect.ValueOf(slice)
+	if sliceValue.Kind() == reflect.Ptr && !sliceValue.IsNil() {
+		sliceValue = sliceValue.Elem()
+	}
+	if sliceValue.Kind() != reflect.Slice && sliceValue.Kind() != reflect.Array {
+		return nil, ErrInvalidStructSlice
+	}
+	if indirectType(sliceValue.Type().Elem()).Kind() != reflect.Struct {
+		return nil, ErrInvalidStructSlice
+	}
+	if sliceValue.Kind() == reflect.Slice && sliceValue.IsNil() {
+		return nil, nil
+	}
+	projector := newReadProjector(xsList, opts, false)
+	return projector.sliceMaps(sliceValue), nil
+}
+
+// ProjectAll is the typed form of StructSliceToMapsWithReadXS for a []T of structs or struct pointers.
+func ProjectAll[T any](items []T, xsList []string, opts ...ConvertOption) ([]map[string]any, error) {
+	return StructSliceToMapsWithReadXS(items, xsList, opts...)
+}
+
+// ProjectMap converts every value of a map of structs or struct pointers like StructToMapFieldsWithReadXS,
+// keeping the keys; nil values become nil maps.
+func ProjectMap[K comparable, T any](items map[K]T, xsList []string, opts ...ConvertOption) (map[K]map[string]any, error) {
+	if indirectType(reflect.TypeOf(items).Elem()).Kind() != reflect.Struct {
+		return nil, ErrInvalidStructSlice
+	}
+	if items == nil {
+		return nil, nil
+	}
+	projector := newReadProjector(xsList, opts, false)
+	projected := make(map[K]map[string]any, len(items))
+	for key, item := range items {
+		projected[key] = projector.itemMap(reflect.ValueOf(&item).Elem())
+	}
+	return projected, nil
+}
+
+// ProjectEnvelope converts an envelope struct pointer, such as a page of results with items, a total and
+// a cursor, into a map like StructToMapFieldsWithReadXS, but also projects the structs nested in its
+// fields: struct, slice, array and map fields holding structs (e.g. `Items []User`) become maps (or
+// slices of maps) of the fields the roles may read. Nested structs are keyed with the same naming, and
+// slices of structs are converted like StructSliceToMapsWithReadXS, including WithParallelism.
+func ProjectEnvelope(envelope any, xsList []string, opts ...ConvertOption) (map[string]any, error) {
+	envelopeValue := reflect.ValueOf(envelope)
+	if envelopeValue.Kind() != reflect.Ptr || envelopeValue.Elem().Kind() != reflect.Struct {
+		return nil, ErrInvalidStructPointer
+	}
+	projector := newReadProjector(xsList, opts, true)
+	return projector.structMap(envelopeValue.Elem()), nil
+}
+
+// WithParallelism lets StructSliceToMapsWithReadXS, ProjectAll and ProjectEnvelope split slices across
+// up to workers goroutines. Every goroutine projects at least 512 elements, so small slices are
+// projected sequentially.
+func WithParallelism(workers int) ConvertOption {
+	return func(options *convertOptions) {
+		options.parallelism = workers
+	}
+}
+
+// readProjector converts structs into maps of the fields the roles may read, resolving the readable
+// field plans once per struct type.
+type readProjector struct {
+	roles       []string
+	naming      FieldNaming
+	useMappers  bool
+	deep        bool
+	parallelism int
+
+	mu       sync.RWMutex
+	readable map[reflect.Type][]*fieldPlan
+}
+
+func newReadProjector(xsList []string, opts []ConvertOption, deep bool) *readProjector {
+	options := newConvertOptions(opts)
+	return &readProjector{
+		roles:       xsList,
+		naming:      options.naming,
+		useMappers:  !options.namingSet && !deep,
+		deep:        deep,
+		parallelism: options.parallelism,
+		readable:    make(map[reflect.Type][]*fieldPlan),
+	}
+}
+
+// readablePlans returns the plans of the fields of the struct type the roles may read. Their `readif`
+// conditions are evaluated per struct.
+func (p *readProjector) readablePlans(structType reflect.Type) []*fieldPlan {
+	p.mu.RLock()
+	plans, ok := p.readable[structType]
+	p.mu.RUnlock()
+	if ok {
+		return plans
+	}
+	allPlans := fieldPlans(structType, p.naming)
+	plans = make([]*fieldPlan, 0, len(allPlans))
+	for i := range allPlans {
+		if allPlans[i].xsAllowed(p.roles, OpRead) {
+			plans = append(plans, &allPlans[i])
+		}
+	}
+	p.mu.Lock()
+	p.readable[structType] = plans
+	p.mu.Unlock()
+	return plans
+}
+
+// structMap converts the struct value into a map of its readable fields.
+func (p *readProjector) structMap(structValue reflect.Value) map[string]any {
+	plans := p.readablePlans(structValue.Type())
+	fieldMap := make(map[string]any, len(plans))
+	for _, plan := range plans {
+		if !plan.conditionsHold(structValue, p.roles, OpRead) {
+			continue
+		}
+		value, ok := plan.value(structValue)
+		if !ok || plan.omit(value) {
+			continue
+		}
+		if p.deep && !plan.asString && hasNestedStructs(value.Type()) {
+			fieldMap[plan.name] = p.nested(value)
+		} else {
+			fieldMap[plan.name] = plan.output(value)
+		}
+	}
+	return fieldMap
+}
+
+// itemMap converts a collection item, a struct or a struct pointer, into a map; nil pointers become nil.
+func (p *readProjector) itemMap(item reflect.Value) map[string]any {
+	for item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface {
+		if item.IsNil() {
+			return nil
+		}
+		item = item.Elem()
+	}
+	if p.useMappers && item.CanAddr() && reflect.PointerTo(item.Type()).Implements(roleMapperType) {
+		return item.Addr().Interface().(RoleMapper).ToMapForRoles(p.roles)
+	}
+	return p.structMap(item)
+}
+
+// sliceMaps converts the items of the slice or array, in parallel if configured and worthwhile.
+func (p *readProjector) sliceMaps(sliceValue reflect.Value) []map[string]any {
+	length := sliceValue.Len()
+	projected := make([]map[string]any, length)
+	// resolve the plans before the workers start
+	p.readablePlans(indirectType(sliceValue.Type().Elem()))
+
+	chunk := length
+	if p.parallelism > 1 && length >= 2*minParallelChunk {
+		chunk = max((length+p.parallelism-1)/p.parallelism, minParallelChunk)
+	}
+	if chunk >= length {
+		for i := 0; i < length; i++ {
+			projected[i] = p.itemMap(sliceValue.Index(i))
+		}
+		return projected
+	}
+
+	var wg sync.WaitGroup
+	for start := 0; start < length; start += chunk {
+		end := min(start+chunk, length)
+		wg.Add(1)
+		go func(start int, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				projected[i] = p.itemMap(sliceValue.Index(i))
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return projected
+}
+
+// nested projects the structs contained in a field value of an envelope.
+func (p *readProjector) nested(value reflect.Value) any {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		if isOpaqueStruct(value.Type()) {
+			return valueInterface(value)
+		}
+		return p.structMap(value)
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil
+		}
+		if indirectType(value.Type().Elem()).Kind() == reflect.Struct && !isOpaqueStruct(indirectType(value.Type().Elem())) {
+			return p.sliceMaps(value)
+		}
+		items := make([]any, value.Len())
+		for i := range items {
+			items[i] = p.nested(value.Index(i))
+		}
+		return items
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		entries := make(map[string]any, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			entries[mapKeyString(iter.Key())] = p.nested(iter.Value())
+		}
+		return entries
+	}
+	return valueInterface(value)
+}
@@ -0,0 +1,146 @@
+package struccy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type collectionUser struct {
+	ID    int          `json:"id" readxs:"*"`
+	Name  string       `json:"name" readxs:"*"`
+	Email string       `json:"email,omitempty" readxs:"self,admin"`
+	Home  *maskAddress `json:"home" readxs:"admin"`
+}
+
+type collectionPage[T any] struct {
+	Items      []T    `json:"items" readxs:"*"`
+	Total      int    `json:"total" readxs:"*"`
+	NextCursor string `json:"nextCursor,omitempty" readxs:"*"`
+	Debug      string `json:"debug" readxs:"admin"`
+}
+
+func newCollectionUsers(n int) []*collectionUser {
+	users := make([]*collectionUser, n)
+	for i := range users {
+		users[i] = &collectionUser{ID: i, Name: fmt.Sprintf("user-%d", i), Email: fmt.Sprintf("u%d@example.com", i)}
+	}
+	return users
+}
+
+func TestStructSliceToMapsWithReadXS(t *testing.T) {
+	users := newCollectionUsers(3)
+	users[1] = nil
+	maps, err := StructSliceToMapsWithReadXS(users, []string{"guest"})
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{
+		{"ID": 0, "Name": "user-0"},
+		nil,
+		{"ID": 2, "Name": "user-2"},
+	}, maps)
+
+	values := []collectionUser{*users[0], *users[2]}
+	maps, err = StructSliceToMapsWithReadXS(&values, []string{"self"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{
+		{"id": 0, "name": "user-0", "email": "u0@example.com"},
+		{"id": 2, "name": "user-2", "email": "u2@example.com"},
+	}, maps)
+
+	for _, element := range values {
+		expected, err := StructToMapFieldsWithReadXS(&element, []string{"admin"})
+		assert.NoError(t, err)
+		maps, err := ProjectAll([]collectionUser{element}, []string{"admin"})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, maps[0])
+	}
+
+	maps, err = ProjectAll[*collectionUser](nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, maps)
+	_, err = StructSliceToMapsWithReadXS([]int{1}, nil)
+	assert.Equal(t, ErrInvalidStructSlice, err)
+	_, err = StructSliceToMapsWithReadXS(users[0], nil)
+	assert.Equal(t, ErrInvalidStructSlice, err)
+}
+
+func TestProjectAllUsesRoleMapper(t *testing.T) {
+	users := []genMapped{{Name: "a"}, {Name: "b"}}
+	maps, err := ProjectAll(users, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"generated": "a"}, {"generated": "b"}}, maps)
+
+	// a naming bypasses the generated method
+	maps, err = ProjectAll(users, []string{"admin"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"name": "a"}, {"name": "b"}}, maps)
+}
+
+type genMapped struct {
+	Name string `json:"name" readxs:"*"`
+}
+
+func (g *genMapped) ToMapForRoles(roles []string) map[string]any {
+	return map[string]any{"generated": g.Name}
+}
+
+func TestWithParallelism(t *testing.T) {
+	users := newCollectionUsers(5000)
+	users[4321] = nil
+	sequential, err := ProjectAll(users, []string{"self"})
+	assert.NoError(t, err)
+	parallel, err := ProjectAll(users, []string{"self"}, WithParallelism(4))
+	assert.NoError(t, err)
+	assert.Equal(t, sequential, parallel)
+	assert.Nil(t, parallel[4321])
+	assert.Equal(t, map[string]any{"ID": 4999, "Name": "user-4999", "Email": "u4999@example.com"}, parallel[4999])
+}
+
+func TestProjectMap(t *testing.T) {
+	users := map[string]*collectionUser{"a": {ID: 1, Name: "A"}, "b": nil}
+	projected, err := ProjectMap(users, nil, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]any{"a": {"id": 1, "name": "A"}, "b": nil}, projected)
+
+	_, err = ProjectMap(map[string]int{}, nil)
+	assert.Equal(t, ErrInvalidStructSlice, err)
+}
+
+func TestProjectEnvelope(t *testing.T) {
+	users := newCollectionUsers(2)
+	users[0].Home = &maskAddress{City: "Oslo", Geo: &maskGeo{Lat: 1, Lng: 2}}
+	page := &collectionPage[*collectionUser]{Items: users, Total: 10, Debug: "query took 3ms"}
+
+	projected, err := ProjectEnvelope(page, []string{"guest"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"items": []map[string]any{
+			{"id": 0, "name": "user-0"},
+			{"id": 1, "name": "user-1"},
+		},
+		"total": 10,
+	}, projected)
+
+	projected, err = ProjectEnvelope(page, []string{"admin"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"id": 0, "name": "user-0", "email": "u0@example.com",
+		"home": map[string]any{"city": "Oslo", "zip": "", "geo": map[string]any{"lat": 1.0, "lng": 2.0}},
+	}, projected["items"].([]map[string]any)[0])
+	assert.Equal(t, "query took 3ms", projected["debug"])
+
+	grouped := &struct {
+		Groups map[string][]collectionUser `readxs:"*"`
+		Tags   []string                    `readxs:"*"`
+	}{Groups: map[string][]collectionUser{"x": {{ID: 7, Email: "x@example.com"}}}, Tags: []string{"t"}}
+	projected, err = ProjectEnvelope(grouped, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"Groups": map[string]any{"x": []map[string]any{{"ID": 7, "Name": ""}}},
+		"Tags":   []string{"t"},
+	}, projected)
+
+	_, err = ProjectEnvelope(*page, nil)
+	assert.Equal(t, ErrInvalidStructPointer, err)
+}
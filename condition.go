@@ -0,0 +1,173 @@
+package struccy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const (
+	tagNameReadIf  = "readif"
+	tagNameWriteIf = "writeif"
+)
+
+// Condition is a predicate registered with RegisterCondition and referenced in `readif`/`writeif`
+// tags as `name()`. It receives the struct pointer whose field is accessed and the roles.
+type Condition func(entity any, roles []string) bool
+
+// conditions holds the registered Condition functions by name.
+var conditions sync.Map // map[string]Condition
+
+// RegisterCondition registers a Condition under the name, replacing an earlier one with the same name.
+func RegisterCondition(name string, condition Condition) {
+	conditions.Store(name, condition)
+}
+
+// conditionTerm is a single parsed entry of a `readif` or `writeif` tag, e.g. `Status=published@guest`.
+type conditionTerm struct {
+	negate    bool
+	field     string   // field name, empty for a registered condition
+	condition string   // name of the registered condition
+	values    []string // values the field must (not) have, empty for a truthiness check
+	xs        string   // role spec the term applies to (IsFieldAccessAllowed syntax), empty for all roles
+	invalid   bool
+}
+
+// conditionTerms caches the parsed terms per tag value.
+var conditionTerms sync.Map // map[string][]conditionTerm
+
+// parseConditionTerms parses a `readif`/`writeif` tag value:
+//
+//	Field            the field is not the zero value (nil pointers are zero)
+//	!Field           the field is the zero value
+//	Field=a|b        the field, formatted with fmt.Sprint, is one of the values
+//	Field!=a|b       the field is none of the values
+//	name()           the Condition registered under name returns true
+//	!name()          it returns false
+//
+// Fields are referenced by their Go or JSON name. Every term can be restricted to certain roles like
+// the `validate` rules, e.g. `Status=published@guest|user`. All terms must hold.
+func parseConditionTerms(tag string) []conditionTerm {
+	if cached, ok := conditionTerms.Load(tag); ok {
+		return cached.([]conditionTerm)
+	}
+	terms := make([]conditionTerm, 0)
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		term := conditionTerm{}
+		if idx := strings.LastIndex(part, "@"); idx >= 0 && roleSpecPattern.MatchString(part[idx+1:]) {
+			term.xs = strings.ReplaceAll(part[idx+1:], "|", ",")
+			part = part[:idx]
+		}
+		operand, values, hasValues := strings.Cut(part, "=")
+		if hasValues {
+			if strings.HasSuffix(operand, "!") {
+				operand = strings.TrimSuffix(operand, "!")
+				term.negate = true
+			}
+			term.values = strings.Split(values, "|")
+		} else if strings.HasPrefix(operand, "!") {
+			operand = strings.TrimPrefix(operand, "!")
+			term.negate = true
+		}
+		if name, ok := strings.CutSuffix(operand, "()"); ok {
+			term.condition = name
+			term.invalid = hasValues
+		} else {
+			term.field = operand
+		}
+		term.invalid = term.invalid || operand == ""
+		terms = append(terms, term)
+	}
+	conditionTerms.Store(tag, terms)
+	return terms
+}
+
+// conditionHolds evaluates a `readif`/`writeif` tag value against the struct value for the roles.
+// An empty tag always holds. Terms referencing unknown fields or conditions fail, hiding the field.
+func conditionHolds(structValue reflect.Value, tag string, roles []string) bool {
+	if tag == "" {
+		return true
+	}
+	for _, term := range parseConditionTerms(tag) {
+		if term.xs != "" && !IsFieldAccessAllowed(roles, term.xs) {
+			continue
+		}
+		if term.invalid || term.holds(structValue, roles) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// holds evaluates the term without its negation.
+func (term *conditionTerm) holds(structValue reflect.Value, roles []string) bool {
+	if term.condition != "" {
+		condition, ok := conditions.Load(term.condition)
+		if !ok {
+			return term.negate // unknown conditions fail either way
+		}
+		entity := structValue.Interface()
+		if structValue.CanAddr() {
+			entity = structValue.Addr().Interface()
+		}
+		return condition.(Condition)(entity, roles)
+	}
+
+	field, ok := lookupField(structValue.Type(), term.field)
+	if !ok || !field.IsExported() {
+		return term.negate
+	}
+	value, err := structValue.FieldByIndexErr(field.Index)
+	if err == nil {
+		for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				err = ErrFieldIsNil
+				break
+			}
+			value = value.Elem()
+		}
+	}
+	if len(term.values) == 0 {
+		return err == nil && !value.IsZero()
+	}
+	formatted := ""
+	if err == nil {
+		formatted = fmt.Sprint(value.Interface())
+	}
+	for _, expected := range term.values {
+		if formatted == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldConditionHolds evaluates a `readif` or `writeif` tag value (see the README) against the struct
+// pointer entity for the roles. It is called by the code struccy-gen generates.
+func FieldConditionHolds(entity any, condition string, roles []string) bool {
+	structValue := reflect.ValueOf(entity)
+	for structValue.Kind() == reflect.Ptr && !structValue.IsNil() {
+		structValue = structValue.Elem()
+	}
+	if structValue.Kind() != reflect.Struct {
+		return false
+	}
+	return conditionHolds(structValue, condition, roles)
+}
+
+// fieldAccessAllowed checks the access tag of the operation and its `readif`/`writeif` condition,
+// evaluated against the struct value holding the field. Writes also check the `writexs` markers.
+func fieldAccessAllowed(structValue reflect.Value, field reflect.StructField, roles []string, op Operation) bool {
+	if op == OpWrite {
+		return writeDenial(structValue, field, roles) == nil
+	}
+	if !IsFieldAccessAllowed(roles, field.Tag.Get(op.tagName())) {
+		return false
+	}
+	return conditionHolds(structValue, field.Tag.Get(op.conditionTagName()), roles)
+}
@@ -0,0 +1,181 @@
+package struccy
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type conditionPost struct {
+	Status           string  `json:"status" readxs:"*" writexs:"editor"`
+	PublishedContent string  `json:"published_content" readxs:"*" writexs:"editor" readif:"Status=published@!editor" writeif:"status!=archived"`
+	Confidential     bool    `json:"confidential" readxs:"*" writexs:"admin"`
+	SalaryBand       string  `json:"salary_band" readxs:"*" writexs:"admin" readif:"!Confidential@!admin"`
+	Reviewer         *string `json:"reviewer" readxs:"*" writexs:"editor" readif:"Reviewer"`
+	Draft            string  `json:"draft" readxs:"editor" writexs:"editor" writeif:"owner()"`
+	Broken           string  `json:"broken" readxs:"*" writexs:"*" readif:"Missing=1"`
+	Owner            string  `json:"owner" readxs:"*" writexs:"admin"`
+}
+
+func init() {
+	RegisterCondition("owner", func(entity any, roles []string) bool {
+		post, ok := entity.(*conditionPost)
+		if !ok {
+			return false
+		}
+		for _, role := range roles {
+			if role == "user:"+post.Owner {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func TestConditionHolds(t *testing.T) {
+	reviewer := "ann"
+	post := &conditionPost{Status: "published", Reviewer: &reviewer, Owner: "bob"}
+	guest := []string{"guest"}
+
+	assert.True(t, FieldConditionHolds(post, "", guest))
+	assert.True(t, FieldConditionHolds(post, "Status=published", guest))
+	assert.True(t, FieldConditionHolds(post, "status=draft|published", guest))
+	assert.False(t, FieldConditionHolds(post, "Status!=draft|published", guest))
+	assert.True(t, FieldConditionHolds(post, "Reviewer=ann, Reviewer", guest))
+	assert.True(t, FieldConditionHolds(post, "!Confidential", guest))
+	assert.False(t, FieldConditionHolds(post, "Confidential", guest))
+	assert.True(t, FieldConditionHolds(post, "Confidential@admin", guest), "the term only applies to admins")
+	assert.False(t, FieldConditionHolds(post, "Confidential@admin", []string{"admin"}))
+	assert.True(t, FieldConditionHolds(post, "owner()", []string{"user:bob"}))
+	assert.False(t, FieldConditionHolds(post, "owner()", guest))
+	assert.True(t, FieldConditionHolds(post, "!owner()", guest))
+	assert.True(t, FieldConditionHolds(*post, "Status=published", guest), "structs work as well")
+
+	post.Reviewer = nil
+	assert.False(t, FieldConditionHolds(post, "Reviewer", guest))
+	assert.True(t, FieldConditionHolds(post, "Reviewer=", guest), "nil pointers compare as empty")
+
+	// unknown fields and conditions, and malformed terms fail either way
+	for _, condition := range []string{"Missing", "!Missing", "unknown()", "!unknown()", "owner()=1", "=1", "!"} {
+		assert.False(t, FieldConditionHolds(post, condition, []string{"user:bob"}), condition)
+	}
+	assert.False(t, FieldConditionHolds("post", "Status", guest))
+	assert.False(t, FieldConditionHolds((*conditionPost)(nil), "", guest))
+}
+
+func TestReadIf(t *testing.T) {
+	post := &conditionPost{Status: "draft", PublishedContent: "text", Confidential: true, SalaryBand: "B", Broken: "x"}
+
+	fields, err := StructToMapFieldsWithReadXS(post, []string{"guest"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.NotContains(t, fields, "published_content")
+	assert.NotContains(t, fields, "salary_band")
+	assert.NotContains(t, fields, "reviewer")
+	assert.NotContains(t, fields, "broken")
+	assert.Equal(t, "draft", fields["status"])
+
+	// editors see drafts, admins see confidential fields
+	fields, err = StructToMapFieldsWithReadXS(post, []string{"editor", "admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, "text", fields["PublishedContent"])
+	assert.Equal(t, "B", fields["SalaryBand"])
+
+	post.Status = "published"
+	post.Confidential = false
+	fields, err = StructToMapFieldsWithReadXS(post, []string{"guest"})
+	assert.NoError(t, err)
+	assert.Equal(t, "text", fields["PublishedContent"])
+	assert.Equal(t, "B", fields["SalaryBand"])
+
+	names, err := GetFieldNamesWithReadXS(&conditionPost{}, []string{"guest"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Status", "Confidential", "SalaryBand", "Owner"}, names)
+
+	_, err = GetPath(&conditionPost{SalaryBand: "B", Confidential: true}, "salary_band", []string{"guest"})
+	assert.True(t, errors.Is(err, ErrUnauthorizedFieldGet))
+}
+
+func TestReadIfFilterAndProject(t *testing.T) {
+	source := &conditionPost{Status: "draft", PublishedContent: "text", SalaryBand: "B"}
+
+	filtered := &conditionPost{PublishedContent: "stale"}
+	assert.NoError(t, FilterStructTo(source, filtered, []string{"guest"}, true))
+	assert.Equal(t, "", filtered.PublishedContent)
+	assert.Equal(t, "B", filtered.SalaryBand)
+
+	projected := ProjectWithReadXS([]*conditionPost{source, {Status: "published", PublishedContent: "live"}}, []string{"guest"}).([]any)
+	assert.NotContains(t, projected[0], "published_content")
+	assert.Equal(t, "live", projected[1].(map[string]any)["published_content"])
+
+	items, err := ProjectAll([]conditionPost{*source, {Status: "published", PublishedContent: "live"}}, []string{"guest"})
+	assert.NoError(t, err)
+	assert.NotContains(t, items[0], "PublishedContent")
+	assert.Equal(t, "live", items[1]["PublishedContent"])
+
+	values, err := ToValues(source, []string{"guest"})
+	assert.NoError(t, err)
+	assert.NotContains(t, values, "published_content")
+
+	var csv strings.Builder
+	assert.NoError(t, WriteCSV(&csv, []conditionPost{*source, {Status: "published", PublishedContent: "live"}}, []string{"guest"}))
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	assert.Equal(t, "draft,,false,B,,,", lines[1])
+	assert.Equal(t, "published,live,false,,,,", lines[2])
+}
+
+func TestWriteIf(t *testing.T) {
+	editor := []string{"editor"}
+
+	// the condition holds for the target before the update, so archiving and editing at once is allowed
+	post := &conditionPost{Status: "draft"}
+	err := DecodeJSONWithWriteXS([]byte(`{"status":"archived","published_content":"text"}`), post, editor)
+	assert.NoError(t, err)
+	assert.Equal(t, "archived", post.Status)
+	assert.Equal(t, "text", post.PublishedContent)
+
+	// but an archived post cannot be edited, not even by unarchiving it in the same update
+	err = DecodeJSONWithWriteXS([]byte(`{"status":"draft","published_content":"new"}`), post, editor)
+	assert.NoError(t, err)
+	assert.Equal(t, "draft", post.Status)
+	assert.Equal(t, "text", post.PublishedContent)
+
+	archived := &conditionPost{Status: "archived", PublishedContent: "text"}
+	merged, err := MergeStructUpdateTo(archived, &conditionPost{Status: "draft", PublishedContent: "new"}, editor)
+	assert.NoError(t, err)
+	assert.Equal(t, "text", merged.(*conditionPost).PublishedContent)
+	assert.Equal(t, "draft", merged.(*conditionPost).Status)
+
+	archived = &conditionPost{Status: "archived", PublishedContent: "text"}
+	assert.NoError(t, ApplyMapUpdate(archived, map[string]any{"Status": "draft", "PublishedContent": "new"}, editor))
+	assert.Equal(t, "text", archived.PublishedContent)
+
+	archived = &conditionPost{Status: "archived", PublishedContent: "text"}
+	assert.NoError(t, BindForm(archived, url.Values{"Status": {"draft"}, "PublishedContent": {"new"}}, editor))
+	assert.Equal(t, "draft", archived.Status)
+	assert.Equal(t, "text", archived.PublishedContent)
+
+	archived = &conditionPost{Status: "archived", PublishedContent: "text"}
+	updated, _, err := UpdateStructFields(archived, &conditionPost{Status: "draft", PublishedContent: "new"}, editor, false, false)
+	assert.NoError(t, err)
+	assert.NotContains(t, updated, "PublishedContent")
+	assert.Equal(t, "text", archived.PublishedContent)
+
+	archived = &conditionPost{Status: "archived", PublishedContent: "text"}
+	_, err = UpdateWithMask(archived, &conditionPost{Status: "draft", PublishedContent: "new"}, []string{"status", "published_content"}, editor)
+	assert.NoError(t, err)
+	assert.Equal(t, "text", archived.PublishedContent)
+
+	assert.False(t, IsAllowedToSetField(&conditionPost{Status: "archived"}, "PublishedContent", editor))
+	assert.True(t, IsAllowedToSetField(conditionPost{Status: "draft"}, "PublishedContent", editor))
+	assert.True(t, errors.Is(SetPath(&conditionPost{Status: "archived"}, "published_content", "new", editor), ErrUnauthorizedFieldSet))
+	assert.True(t, errors.Is(SetField(&conditionPost{Status: "archived"}, "PublishedContent", "new", false, editor), ErrUnauthorizedFieldSet))
+
+	// registered conditions receive the struct pointer and the roles
+	post = &conditionPost{Owner: "bob"}
+	assert.NoError(t, SetPath(post, "draft", "mine", []string{"editor", "user:bob"}))
+	assert.Equal(t, "mine", post.Draft)
+	assert.True(t, errors.Is(SetPath(post, "draft", "theirs", []string{"editor", "user:eve"}), ErrUnauthorizedFieldSet))
+}
@@ -0,0 +1,127 @@
+package struccy
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// parseStringValue converts a string into a value of the target type.
+// It supports strings, bools, all int/uint/float kinds, time.Duration (e.g. "5m"),
+// time.Time (RFC 3339), types implementing encoding.TextUnmarshaler, pointers to any of these
+// (a new pointer is allocated) and slices of any of these (comma separated elements).
+//
+// It returns an error wrapping ErrInvalidFieldValue if the string cannot be converted.
+func parseStringValue(s string, targetType reflect.Type) (reflect.Value, error) {
+	if targetType.Kind() == reflect.Ptr {
+		elem, err := parseStringValue(s, targetType.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(targetType.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	}
+
+	value := reflect.New(targetType).Elem()
+	if reflect.PointerTo(targetType).Implements(textUnmarshalerType) {
+		if err := value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return reflect.Value{}, fmt.Errorf("%w: %q as %v: %v", ErrInvalidFieldValue, s, targetType, err)
+		}
+		return value, nil
+	}
+	switch targetType {
+	case timeType:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%w: %q as %v: %v", ErrInvalidFieldValue, s, targetType, err)
+		}
+		return reflect.ValueOf(t), nil
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%w: %q as %v: %v", ErrInvalidFieldValue, s, targetType, err)
+		}
+		return reflect.ValueOf(d), nil
+	}
+
+	var err error
+	switch targetType.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(s, 10, targetType.Bits())
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(s, 10, targetType.Bits())
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, targetType.Bits())
+		value.SetFloat(f)
+	case reflect.Slice:
+		if targetType.Elem().Kind() == reflect.Uint8 {
+			value.SetBytes([]byte(s))
+			break
+		}
+		parts := make([]string, 0)
+		if s != "" {
+			parts = strings.Split(s, ",")
+		}
+		value = reflect.MakeSlice(targetType, len(parts), len(parts))
+		for i, part := range parts {
+			elem, elemErr := parseStringValue(strings.TrimSpace(part), targetType.Elem())
+			if elemErr != nil {
+				return reflect.Value{}, elemErr
+			}
+			value.Index(i).Set(elem)
+		}
+	default:
+		return reflect.Value{}, fmt.Errorf("%w: %q as %v: %v", ErrInvalidFieldValue, s, targetType, ErrUnsupportedFieldType)
+	}
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("%w: %q as %v: %v", ErrInvalidFieldValue, s, targetType, err)
+	}
+	return value, nil
+}
+
+// isStringParsable reports whether parseStringValue can convert a string into the given type.
+func isStringParsable(targetType reflect.Type) bool {
+	targetType = indirectType(targetType)
+	if targetType == timeType || reflect.PointerTo(targetType).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch targetType.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return targetType.Elem().Kind() == reflect.Uint8 || isStringParsable(targetType.Elem())
+	}
+	return false
+}
+
+// indirectType returns the type behind any number of pointers.
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,388 @@
+package struccy
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// RowError describes a CSV row that could not be read. Err is usually a *FieldErrors.
+type RowError struct {
+	Line int // line number of the row in the CSV input, the header being line 1
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// RowErrors aggregates the RowError entries collected by ReadCSV.
+// It implements `Unwrap() []error`, so errors.Is and errors.As match against every entry.
+type RowErrors struct {
+	Errors []*RowError
+}
+
+func (e *RowErrors) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	msgs := make([]string, len(e.Errors))
+	for i, re := range e.Errors {
+		msgs[i] = re.Error()
+	}
+	return fmt.Sprintf("%d row errors: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+func (e *RowErrors) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, re := range e.Errors {
+		errs[i] = re
+	}
+	return errs
+}
+
+// Add appends a RowError to the collection.
+func (e *RowErrors) Add(re *RowError) {
+	e.Errors = append(e.Errors, re)
+}
+
+// Len returns the number of collected row errors.
+func (e *RowErrors) Len() int {
+	return len(e.Errors)
+}
+
+// errOrNil returns the collection as an error, or nil if it is empty.
+func (e *RowErrors) errOrNil() error {
+	if e == nil || len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// WriteCSV writes the rows (structs or pointers to structs) as CSV with a header row. The columns are
+// the fields the roles may read (see `readxs`), named after the `csv` tag or, without one, the `json`
+// tag; fields without a tag name use their Go name unless another naming is selected with WithFieldNaming.
+// Nested structs are flattened into dotted headers (`address.city`), checking `readxs` at every level.
+// Cells of fields whose `readif` condition does not hold for the row are left empty.
+//
+// Cells are formatted so that ReadCSV parses them back: slices of scalars are comma separated and
+// values that have no string form (maps, slices of structs) are written as JSON. Nil rows are skipped.
+func WriteCSV[T any](w io.Writer, rows []T, roles []string, opts ...ConvertOption) error {
+	structType, err := csvStructType[T]()
+	if err != nil {
+		return err
+	}
+	options := newConvertOptions(opts)
+	columns := csvColumns(structType, roles, OpRead, csvFieldNaming(options))
+
+	writer := csv.NewWriter(w)
+	if options.comma != 0 {
+		writer.Comma = options.comma
+	}
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.header
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for i := range rows {
+		rowValue := reflect.ValueOf(&rows[i]).Elem()
+		if rowValue.Kind() == reflect.Ptr {
+			if rowValue.IsNil() {
+				continue
+			}
+			rowValue = rowValue.Elem()
+		}
+		for j, column := range columns {
+			if !column.allowed(rowValue, roles, OpRead) {
+				record[j] = ""
+				continue
+			}
+			if record[j], err = column.format(rowValue); err != nil {
+				return err
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadCSV reads CSV with a header row into structs (or pointers to structs), matching the headers like
+// WriteCSV. Only columns for fields the roles may write (see `writexs`) are bound; other columns are ignored.
+// The `writeif` conditions are evaluated on the zero value of the row struct, as every row starts from it.
+// Cells are converted like in SetField, with empty cells setting non-string fields to their zero value.
+//
+// Rows that fail to convert or validate (see `validate`) are left out of the result and reported
+// together as a *RowErrors, each RowError holding the *FieldErrors of its row; all other rows are
+// still returned. Malformed CSV aborts reading and returns the rows read so far along with the error.
+func ReadCSV[T any](r io.Reader, roles []string, opts ...ConvertOption) ([]T, error) {
+	structType, err := csvStructType[T]()
+	if err != nil {
+		return nil, err
+	}
+	options := newConvertOptions(opts)
+	reader := csv.NewReader(r)
+	if options.comma != 0 {
+		reader.Comma = options.comma
+	}
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := csvColumns(structType, roles, OpWrite, csvFieldNaming(options))
+	byHeader := make(map[string]*csvColumn, len(columns))
+	for i := range columns {
+		byHeader[columns[i].header] = &columns[i]
+	}
+	headerColumns := make([]*csvColumn, len(header))
+	for i, name := range header {
+		headerColumns[i] = byHeader[strings.TrimSpace(name)]
+	}
+
+	pointerRows := reflect.TypeOf((*T)(nil)).Elem().Kind() == reflect.Ptr
+	var rows []T
+	rowErrs := &RowErrors{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			// the *csv.ParseError holds the line
+			return rows, err
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			rowErrs.Add(&RowError{Line: line, Err: err})
+			continue
+		}
+
+		rowPtr := reflect.New(structType)
+		fieldErrs := &FieldErrors{}
+		for i, cell := range record {
+			column := headerColumns[i]
+			if column == nil || !column.allowed(reflect.Zero(structType), roles, OpWrite) {
+				continue
+			}
+			if err := column.set(rowPtr.Elem(), cell); err != nil {
+				fieldErrs.Add(&FieldError{
+					Path:     column.goPath,
+					JSONName: column.jsonPath,
+					Cause:    err,
+					Expected: column.fieldType(),
+					Actual:   reflect.TypeOf(cell),
+					Value:    cell,
+				})
+			}
+		}
+		validateStructValue(rowPtr.Elem(), roles, "", "", fieldErrs)
+		if fieldErrs.Len() > 0 {
+			rowErrs.Add(&RowError{Line: line, Err: fieldErrs})
+			continue
+		}
+		if pointerRows {
+			rows = append(rows, rowPtr.Interface().(T))
+		} else {
+			rows = append(rows, rowPtr.Elem().Interface().(T))
+		}
+	}
+	return rows, rowErrs.errOrNil()
+}
+
+// csvStructType returns the struct type of the row type T, which must be a struct or a pointer to a struct.
+func csvStructType[T any]() (reflect.Type, error) {
+	rowType := reflect.TypeOf((*T)(nil)).Elem()
+	structType := rowType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: rows must be structs or pointers to structs, got %v", ErrInvalidStructPointer, rowType)
+	}
+	return structType, nil
+}
+
+// csvFieldNaming returns the naming selected with WithFieldNaming, defaulting to tag names with Go names as fallback.
+func csvFieldNaming(options *convertOptions) FieldNaming {
+	if !options.namingSet {
+		return JSONFieldNames
+	}
+	return options.naming
+}
+
+// csvColumn is a (possibly nested) field written to or read from a CSV column.
+type csvColumn struct {
+	header   string
+	goPath   string
+	jsonPath string
+	plans    []*fieldPlan // the fields from the row struct down to the column's field
+}
+
+// csvColumns returns the columns of the struct type the roles may access with the operation, in field order.
+func csvColumns(structType reflect.Type, roles []string, op Operation, naming FieldNaming) []csvColumn {
+	var columns []csvColumn
+	appendCSVColumns(&columns, structType, roles, op, naming, nil, make(map[reflect.Type]bool))
+	return columns
+}
+
+func appendCSVColumns(columns *[]csvColumn, structType reflect.Type, roles []string, op Operation, naming FieldNaming, parents []*fieldPlan, ancestors map[reflect.Type]bool) {
+	ancestors[structType] = true
+	defer delete(ancestors, structType)
+	plans := taggedFieldPlans(structType, tagKeyCSV, naming)
+	for i := range plans {
+		plan := &plans[i]
+		if !plan.xsAllowed(roles, op) {
+			continue
+		}
+		path := append(append([]*fieldPlan{}, parents...), plan)
+		nestedType := indirectType(plan.field.Type)
+		if nestedType.Kind() == reflect.Struct && !isOpaqueStruct(nestedType) {
+			// recursive types cannot be flattened into a fixed set of columns
+			if !ancestors[nestedType] {
+				appendCSVColumns(columns, nestedType, roles, op, naming, path, ancestors)
+			}
+			continue
+		}
+		headers := make([]string, len(path))
+		goPath := make([]string, len(path))
+		jsonPath := make([]string, len(path))
+		for j, p := range path {
+			headers[j] = p.name
+			goPath[j] = p.field.Name
+			jsonPath[j] = jsonFieldName(p.field)
+		}
+		*columns = append(*columns, csvColumn{
+			header:   strings.Join(headers, "."),
+			goPath:   strings.Join(goPath, "."),
+			jsonPath: strings.Join(jsonPath, "."),
+			plans:    path,
+		})
+	}
+}
+
+// allowed reports whether the `readif`/`writeif` conditions along the column's path hold for the row.
+// Structs behind nil pointers are evaluated as zero values.
+func (c *csvColumn) allowed(rowValue reflect.Value, roles []string, op Operation) bool {
+	current := rowValue
+	for _, plan := range c.plans {
+		for current.Kind() == reflect.Ptr {
+			if current.IsNil() {
+				current = reflect.Zero(current.Type().Elem())
+			} else {
+				current = current.Elem()
+			}
+		}
+		if !plan.allowed(current, roles, op) {
+			return false
+		}
+		value, ok := plan.value(current)
+		if !ok {
+			value = reflect.Zero(plan.field.Type)
+		}
+		current = value
+	}
+	return true
+}
+
+func (c *csvColumn) fieldType() reflect.Type {
+	return c.plans[len(c.plans)-1].field.Type
+}
+
+// value returns the column's field in the row, or false if a nil pointer is in the way.
+func (c *csvColumn) value(rowValue reflect.Value) (reflect.Value, bool) {
+	current := rowValue
+	for _, plan := range c.plans {
+		for current.Kind() == reflect.Ptr {
+			if current.IsNil() {
+				return reflect.Value{}, false
+			}
+			current = current.Elem()
+		}
+		var ok bool
+		if current, ok = plan.value(current); !ok {
+			return reflect.Value{}, false
+		}
+	}
+	return current, true
+}
+
+// format returns the cell of the column for the row.
+func (c *csvColumn) format(rowValue reflect.Value) (string, error) {
+	value, ok := c.value(rowValue)
+	if !ok {
+		return "", nil
+	}
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return "", nil
+		}
+		value = value.Elem()
+	}
+	if !isStringParsable(value.Type()) {
+		cell, err := json.Marshal(value.Interface())
+		return string(cell), err
+	}
+	if value.Kind() == reflect.Slice && value.Type().Elem().Kind() != reflect.Uint8 && !isTextUnmarshaler(value.Type()) {
+		elems := make([]string, value.Len())
+		for i := range elems {
+			elems[i] = formatFormValue(value.Index(i))
+		}
+		return strings.Join(elems, ","), nil
+	}
+	return formatFormValue(value), nil
+}
+
+// set converts the cell into the column's field of the row, allocating nested pointers as needed.
+// Empty cells of fields behind nil pointers leave the pointers nil.
+func (c *csvColumn) set(rowValue reflect.Value, cell string) error {
+	if _, ok := c.value(rowValue); !ok && cell == "" {
+		return nil
+	}
+	current := rowValue
+	for _, plan := range c.plans {
+		for current.Kind() == reflect.Ptr {
+			if current.IsNil() {
+				current.Set(reflect.New(current.Type().Elem()))
+			}
+			current = current.Elem()
+		}
+		current = fieldByIndexAlloc(current, plan.index)
+	}
+
+	fieldType := current.Type()
+	if cell == "" && indirectType(fieldType).Kind() != reflect.String {
+		current.Set(reflect.Zero(fieldType))
+		return nil
+	}
+	if isStringParsable(fieldType) {
+		parsed, err := parseStringValue(cell, fieldType)
+		if err != nil {
+			return err
+		}
+		current.Set(parsed)
+		return nil
+	}
+	target := reflect.New(fieldType)
+	if err := json.Unmarshal([]byte(cell), target.Interface()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFieldValue, err)
+	}
+	current.Set(target.Elem())
+	return nil
+}
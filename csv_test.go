@@ -0,0 +1,135 @@
+package struccy
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type csvAddress struct {
+	City    string `json:"city" readxs:"*" writexs:"*"`
+	Country string `csv:"country_code" readxs:"*" writexs:"*"`
+	Street  string `json:"street" readxs:"admin" writexs:"admin"`
+}
+
+type csvCustomer struct {
+	ID       int               `json:"id" readxs:"*" writexs:"admin"`
+	Name     string            `json:"name" readxs:"*" writexs:"*" validate:"required"`
+	Email    string            `json:"email" csv:"e-mail" readxs:"*" writexs:"*"`
+	Tags     []string          `json:"tags" readxs:"*" writexs:"*"`
+	Since    time.Time         `json:"since" readxs:"*" writexs:"*"`
+	Address  *csvAddress       `json:"address" readxs:"*" writexs:"*"`
+	Meta     map[string]string `json:"meta" readxs:"*" writexs:"*"`
+	Revenue  float64           `json:"revenue" readxs:"admin" writexs:"admin"`
+	Password string            `json:"-" readxs:"*" writexs:"*"`
+}
+
+func TestWriteCSV(t *testing.T) {
+	customers := []*csvCustomer{
+		{
+			ID:      1,
+			Name:    "Acme, Inc.",
+			Email:   "ops@acme.test",
+			Tags:    []string{"gold", "eu"},
+			Since:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Address: &csvAddress{City: "Berlin", Country: "DE", Street: "Main St"},
+			Meta:    map[string]string{"tier": "1"},
+			Revenue: 1000,
+		},
+		nil,
+		{ID: 2, Name: "Globex"},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCSV(&buf, customers, []string{"support"}))
+	assert.Equal(t, strings.Join([]string{
+		"id,name,e-mail,tags,since,address.city,address.country_code,meta",
+		`1,"Acme, Inc.",ops@acme.test,"gold,eu",2024-01-02T03:04:05Z,Berlin,DE,"{""tier"":""1""}"`,
+		"2,Globex,,,0001-01-01T00:00:00Z,,,null",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	assert.NoError(t, WriteCSV(&buf, []csvAddress{{City: "Paris", Street: "Rue 1"}}, []string{"admin"}, WithComma(';')))
+	assert.Equal(t, "city;country_code;street\nParis;;Rue 1\n", buf.String())
+
+	assert.ErrorIs(t, WriteCSV(&buf, []string{"x"}, nil), ErrInvalidStructPointer)
+}
+
+func TestReadCSV(t *testing.T) {
+	input := strings.Join([]string{
+		"id,name,e-mail,tags,since,address.city,revenue,unknown",
+		`7,"Acme, Inc.",ops@acme.test,"gold,eu",2024-01-02T03:04:05Z,Berlin,1000,x`,
+		"8,Globex,,,,,,",
+		"9,,missing@name.test,,,,,",
+		"10,Initech,,,not-a-date,,,",
+		"11,Short",
+	}, "\n")
+
+	customers, err := ReadCSV[csvCustomer](strings.NewReader(input), []string{"support"})
+	assert.Len(t, customers, 2)
+	assert.Equal(t, csvCustomer{
+		Name:    "Acme, Inc.",
+		Email:   "ops@acme.test",
+		Tags:    []string{"gold", "eu"},
+		Since:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Address: &csvAddress{City: "Berlin"},
+	}, customers[0]) // id and revenue are not writable for support
+	assert.Nil(t, customers[1].Address)
+
+	var rowErrs *RowErrors
+	assert.True(t, errors.As(err, &rowErrs))
+	assert.Equal(t, 3, rowErrs.Len())
+	assert.Equal(t, 4, rowErrs.Errors[0].Line)
+	assert.True(t, errors.Is(rowErrs.Errors[0], ErrValidationFailed))
+	assert.Equal(t, 5, rowErrs.Errors[1].Line)
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(rowErrs.Errors[1], &fieldErrs))
+	assert.Equal(t, "Since", fieldErrs.Errors[0].Path)
+	assert.True(t, errors.Is(fieldErrs.Errors[0], ErrInvalidFieldValue))
+	assert.Equal(t, 6, rowErrs.Errors[2].Line)
+
+	admins, err := ReadCSV[*csvCustomer](strings.NewReader("id,revenue,name\n7,12.5,Acme\n"), []string{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, []*csvCustomer{{ID: 7, Revenue: 12.5, Name: "Acme"}}, admins)
+
+	empty, err := ReadCSV[csvCustomer](strings.NewReader(""), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestReadCSVMalformed(t *testing.T) {
+	// malformed CSV returns the rows read so far with the *csv.ParseError
+	customers, err := ReadCSV[csvCustomer](strings.NewReader("name\nAcme\n\"x\"y\n"), nil)
+	assert.Equal(t, []csvCustomer{{Name: "Acme"}}, customers)
+	var parseErr *csv.ParseError
+	assert.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, 3, parseErr.StartLine)
+	assert.True(t, errors.Is(err, csv.ErrQuote))
+
+	customers, err = ReadCSV[csvCustomer](strings.NewReader("name\n\"x\"y\n"), nil)
+	assert.Empty(t, customers)
+	assert.True(t, errors.Is(err, csv.ErrQuote))
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	customers := []csvCustomer{{
+		ID:      1,
+		Name:    "Acme",
+		Tags:    []string{"a", "b"},
+		Since:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Address: &csvAddress{City: "Berlin", Country: "DE", Street: "Main St"},
+		Meta:    map[string]string{"tier": "1"},
+		Revenue: 12.5,
+	}}
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCSV(&buf, customers, []string{"admin"}, WithComma('\t')))
+	read, err := ReadCSV[csvCustomer](&buf, []string{"admin"}, WithComma('\t'))
+	assert.NoError(t, err)
+	assert.Equal(t, customers, read)
+}
@@ -0,0 +1,150 @@
+package struccy
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+const tagNameDefault = "default"
+
+var ErrInvalidDefaultValue = errors.New("invalid default value")
+
+// ApplyDefaults fills the zero-valued fields of a struct from their `default` tag. It is meant for newly
+// created entities, e.g. right after decoding a create request. Fields that are set keep their values,
+// also if the roles are not allowed to write them; decode the request with the write filters (e.g.
+// DecodeJSONWithWriteXS) to keep callers from choosing such values.
+//
+// Default values are parsed with the same conversion rules SetField applies to strings:
+// numbers, bools, durations ("5m"), times (RFC 3339), encoding.TextUnmarshaler implementations
+// and comma separated slices (`default:"a,b,c"`). Pointer fields get a newly allocated value.
+// Nested structs (and pointers to structs with defaults, which are allocated if nil and writable) are
+// processed recursively; a nested field counts as not writable if its parent is not writable.
+//
+// Fields whose default cannot be parsed are reported together as a *FieldErrors with
+// ErrInvalidDefaultValue as cause.
+func ApplyDefaults(structPtr any, roles []string) error {
+	structValue := reflect.ValueOf(structPtr)
+	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	walker := &defaultsWalker{
+		roles: roles,
+		fill: func(path string, isZero bool, writable bool) bool {
+			return isZero
+		},
+		fieldErrs: &FieldErrors{},
+		ancestors: make(map[reflect.Type]bool),
+	}
+	walker.walk(structValue.Elem(), "", "", true)
+	return walker.fieldErrs.errOrNil()
+}
+
+// applyMergeDefaults applies the defaults after a merge: zero-valued fields get their default
+// unless they were sent and writable (i.e. the zero value was set on purpose).
+func applyMergeDefaults(structValue reflect.Value, roles []string, sent map[string]bool, fieldErrs *FieldErrors) {
+	walker := &defaultsWalker{
+		roles: roles,
+		fill: func(path string, isZero bool, writable bool) bool {
+			topLevel, _, _ := strings.Cut(path, ".")
+			return isZero && !(sent[topLevel] && writable)
+		},
+		fieldErrs: fieldErrs,
+		ancestors: make(map[reflect.Type]bool),
+	}
+	walker.walk(structValue, "", "", true)
+}
+
+// defaultsWalker sets the default of every field for which fill returns true.
+type defaultsWalker struct {
+	roles     []string
+	fill      func(path string, isZero bool, writable bool) bool
+	fieldErrs *FieldErrors
+	ancestors map[reflect.Type]bool // struct types on the current path, to stop at recursive types
+}
+
+func (w *defaultsWalker) walk(structValue reflect.Value, pathPrefix string, jsonPrefix string, parentWritable bool) {
+	structType := structValue.Type()
+	w.ancestors[structType] = true
+	defer delete(w.ancestors, structType)
+	// the `writeif` conditions and `@immutable` markers apply to the struct as it was before any default was filled in
+	denials := make([]error, structType.NumField())
+	for i := range denials {
+		denials[i] = writeDenial(structValue, structType.Field(i), w.roles)
+	}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		path := pathPrefix + field.Name
+		jsonPath := jsonPrefix + jsonFieldName(field)
+		value := structValue.Field(i)
+		// immutable fields that are set already could be set by the roles while they were zero,
+		// so they count as writable, but keep their values
+		immutable := errors.Is(denials[i], ErrFieldImmutable)
+		writable := parentWritable && (denials[i] == nil || immutable)
+
+		if defaultValue, ok := field.Tag.Lookup(tagNameDefault); ok {
+			if immutable || !w.fill(path, value.IsZero(), writable) {
+				continue
+			}
+			parsed, err := parseStringValue(defaultValue, field.Type)
+			if err != nil {
+				w.fieldErrs.Add(&FieldError{
+					Path:     path,
+					JSONName: jsonPath,
+					Cause:    ErrInvalidDefaultValue,
+					Expected: field.Type,
+					Actual:   reflect.TypeOf(defaultValue),
+					Value:    defaultValue,
+				})
+				continue
+			}
+			value.Set(parsed)
+			continue
+		}
+
+		nestedType := indirectType(field.Type)
+		if nestedType.Kind() != reflect.Struct || nestedType == timeType || w.ancestors[nestedType] || !hasDefaults(nestedType) {
+			continue
+		}
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				// the parent decides: nil pointers that were sent or may not be written stay nil
+				if !writable || !w.fill(path, true, writable) {
+					continue
+				}
+				value.Set(reflect.New(nestedType))
+			}
+			value = value.Elem()
+		}
+		w.walk(value, path+".", jsonPath+".", writable)
+	}
+}
+
+// hasDefaults reports whether the struct type or any of its nested structs has a `default` tag.
+func hasDefaults(structType reflect.Type) bool {
+	return hasDefaultsVisited(structType, make(map[reflect.Type]bool))
+}
+
+func hasDefaultsVisited(structType reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[structType] {
+		return false
+	}
+	visited[structType] = true
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if _, ok := field.Tag.Lookup(tagNameDefault); ok {
+			return true
+		}
+		nestedType := indirectType(field.Type)
+		if nestedType.Kind() == reflect.Struct && nestedType != timeType && hasDefaultsVisited(nestedType, visited) {
+			return true
+		}
+	}
+	return false
+}
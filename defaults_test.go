@@ -0,0 +1,183 @@
+package struccy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type defaultsSettings struct {
+	Theme    string `json:"theme" writexs:"*" default:"dark"`
+	PageSize *int   `json:"page_size" writexs:"*" default:"25"`
+}
+
+type defaultsAccount struct {
+	Name      string            `json:"name" writexs:"*"`
+	Plan      string            `json:"plan" writexs:"admin" default:"free"`
+	Active    bool              `json:"active" writexs:"admin" default:"true"`
+	Quota     float64           `json:"quota" writexs:"*" default:"1.5"`
+	Timeout   time.Duration     `json:"timeout" writexs:"*" default:"30s"`
+	CreatedAt time.Time         `json:"created_at" writexs:"system" default:"2024-01-02T03:04:05Z"`
+	Tags      []string          `json:"tags" writexs:"*" default:"new, trial"`
+	Limits    []int             `json:"limits" writexs:"*" default:"1,2,3"`
+	Settings  *defaultsSettings `json:"settings" writexs:"*"`
+	Next      *defaultsAccount  `json:"next" writexs:"*"`
+}
+
+func TestApplyDefaults(t *testing.T) {
+	account := &defaultsAccount{
+		Name:  "acme",
+		Plan:  "enterprise", // set, kept although not writable by user
+		Quota: 3,            // set, kept
+	}
+	err := ApplyDefaults(account, []string{"user"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "acme", account.Name)
+	assert.Equal(t, "enterprise", account.Plan)
+	assert.True(t, account.Active, "zero fields get their default, also if not writable")
+	assert.Equal(t, 3.0, account.Quota)
+	assert.Equal(t, 30*time.Second, account.Timeout)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), account.CreatedAt)
+	assert.Equal(t, []string{"new", "trial"}, account.Tags)
+	assert.Equal(t, []int{1, 2, 3}, account.Limits)
+	assert.NotNil(t, account.Settings)
+	assert.Equal(t, "dark", account.Settings.Theme)
+	assert.Equal(t, 25, *account.Settings.PageSize)
+	assert.Nil(t, account.Next)
+
+	admin := &defaultsAccount{}
+	assert.NoError(t, ApplyDefaults(admin, []string{"admin"}))
+	assert.Equal(t, "free", admin.Plan)
+}
+
+func TestApplyDefaults_InvalidDefault(t *testing.T) {
+	type Broken struct {
+		Count int  `default:"many"`
+		Flag  bool `default:"maybe"`
+	}
+	err := ApplyDefaults(&Broken{}, nil)
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 2, fieldErrs.Len())
+	assert.True(t, errors.Is(err, ErrInvalidDefaultValue))
+	assert.Equal(t, "many", fieldErrs.Errors[0].Value)
+
+	assert.Equal(t, ErrInvalidStructPointer, ApplyDefaults(Broken{}, nil))
+}
+
+func TestDefaultsWriteIf(t *testing.T) {
+	type post struct {
+		Status string `writexs:"*"`
+		Title  string `writexs:"*" writeif:"Status!=archived" default:"untitled"`
+	}
+
+	// ApplyDefaults keeps set fields whether or not their `writeif` condition holds
+	archived := &post{Status: "archived", Title: "chosen"}
+	assert.NoError(t, ApplyDefaults(archived, []string{"user"}))
+	assert.Equal(t, "chosen", archived.Title)
+	archived = &post{Status: "archived"}
+	assert.NoError(t, ApplyDefaults(archived, []string{"user"}))
+	assert.Equal(t, "untitled", archived.Title)
+
+	// a zero value sent for such a field is no choice of the caller either
+	archived = &post{Status: "archived"}
+	_, err := MergeMapStringFieldsToStruct(archived, map[string]any{"Title": ""}, []string{"user"}, WithDefaults())
+	assert.NoError(t, err)
+	assert.Equal(t, "untitled", archived.Title)
+	draft := &post{Status: "draft"}
+	_, err = MergeMapStringFieldsToStruct(draft, map[string]any{"Title": ""}, []string{"user"}, WithDefaults())
+	assert.NoError(t, err)
+	assert.Equal(t, "", draft.Title)
+}
+
+func TestMergeMapStringFieldsToStruct_WithDefaults(t *testing.T) {
+	account := &defaultsAccount{}
+	_, err := MergeMapStringFieldsToStruct(account, map[string]any{
+		"Name":  "acme",
+		"Quota": 0.0,
+	}, []string{"user"}, WithDefaults())
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", account.Name)
+	assert.Equal(t, 0.0, account.Quota) // sent on purpose
+	assert.Equal(t, "free", account.Plan)
+	assert.Equal(t, 30*time.Second, account.Timeout)
+
+	// without the option nothing is filled
+	account = &defaultsAccount{}
+	_, err = MergeMapStringFieldsToStruct(account, map[string]any{"Name": "acme"}, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", account.Plan)
+}
+
+func TestDefaultsNilStructPointers(t *testing.T) {
+	// an explicit nil stays nil instead of being replaced by a struct with defaults
+	account := &defaultsAccount{}
+	_, err := MergeMapStringFieldsToStruct(account, map[string]any{"Settings": nil}, []string{"user"}, WithDefaults())
+	assert.NoError(t, err)
+	assert.Nil(t, account.Settings)
+
+	// without it the nested defaults are applied
+	account = &defaultsAccount{}
+	_, err = MergeMapStringFieldsToStruct(account, map[string]any{"Name": "acme"}, []string{"user"}, WithDefaults())
+	assert.NoError(t, err)
+	assert.Equal(t, "dark", account.Settings.Theme)
+	assert.Equal(t, 25, *account.Settings.PageSize)
+
+	// pointers the roles may not write are not allocated
+	type profile struct {
+		Settings *defaultsSettings `writexs:"admin"`
+	}
+	user := &profile{}
+	assert.NoError(t, ApplyDefaults(user, []string{"user"}))
+	assert.Nil(t, user.Settings)
+	_, err = MergeMapStringFieldsToStruct(user, map[string]any{}, []string{"user"}, WithDefaults())
+	assert.NoError(t, err)
+	assert.Nil(t, user.Settings)
+	admin := &profile{}
+	assert.NoError(t, ApplyDefaults(admin, []string{"admin"}))
+	assert.Equal(t, "dark", admin.Settings.Theme)
+}
+
+func TestUpdateStructFields_WithDefaults(t *testing.T) {
+	type Incoming struct {
+		Name string
+		Plan string
+	}
+	account := &defaultsAccount{}
+	updated, _, err := UpdateStructFields(account, &Incoming{Name: "acme", Plan: "enterprise"}, []string{"user"}, true, false, WithDefaults())
+	assert.NoError(t, err)
+	assert.Len(t, updated, 1)
+	assert.Equal(t, "free", account.Plan)
+	assert.Equal(t, []string{"new", "trial"}, account.Tags)
+}
+
+func TestSetField_ParsesStrings(t *testing.T) {
+	type Target struct {
+		Count   int           `writexs:"*"`
+		Ratio   *float32      `writexs:"*"`
+		Enabled bool          `writexs:"*"`
+		Timeout time.Duration `writexs:"*"`
+		Since   time.Time     `writexs:"*"`
+		IDs     []uint        `writexs:"*"`
+	}
+	target := &Target{}
+	roles := []string{"user"}
+	assert.NoError(t, SetField(target, "Count", "42", true, roles))
+	assert.NoError(t, SetField(target, "Ratio", "0.5", true, roles))
+	assert.NoError(t, SetField(target, "Enabled", "true", true, roles))
+	assert.NoError(t, SetField(target, "Timeout", "1m30s", true, roles))
+	assert.NoError(t, SetField(target, "Since", "2024-05-06T07:08:09Z", true, roles))
+	assert.NoError(t, SetField(target, "IDs", "1,2", true, roles))
+	assert.Equal(t, 42, target.Count)
+	assert.Equal(t, float32(0.5), *target.Ratio)
+	assert.True(t, target.Enabled)
+	assert.Equal(t, 90*time.Second, target.Timeout)
+	assert.Equal(t, 2024, target.Since.Year())
+	assert.Equal(t, []uint{1, 2}, target.IDs)
+
+	assert.Equal(t, ErrInvalidFieldValue, SetField(target, "Count", "forty-two", true, roles))
+	assert.Equal(t, 42, target.Count)
+}
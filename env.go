@@ -0,0 +1,129 @@
+package struccy
+
+import (
+	"reflect"
+	"strings"
+)
+
+const tagNameEnv = "env"
+
+// LoadEnv overrides the fields of the target struct pointer from environment variables.
+// A field is read from the variable named by its `env` tag, or from its Go name in upper snake
+// case (`MaxConns` -> `MAX_CONNS`), with the prefix prepended as is (`APP_`). Fields tagged
+// `env:"-"` are never read. Nested structs (and pointers to structs, which are only allocated if
+// one of their fields is set) extend the prefix with their own variable name and an underscore
+// (`APP_DATABASE_HOST`, or `APP_DB_HOST` with `env:"DB"`); embedded structs without an `env` tag keep the prefix of their parent,
+// while their own `writexs` tag still applies.
+//
+// Values are converted like in SetField, with empty values setting non-string fields to their
+// zero value. Variables for fields the roles (e.g. the deployment tier) may not write are ignored;
+// `writexs` (including its markers) and `writeif` are checked at every struct field along the path.
+// Fields that cannot be converted are reported together as a *FieldErrors, along with the failures
+// of the `validate` tags.
+//
+// Variables are looked up with os.LookupEnv unless another lookup is set with WithLookupEnv.
+func LoadEnv(target any, prefix string, roles []string, opts ...EnvOption) error {
+	targetValue := reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Ptr || targetValue.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	structValue := targetValue.Elem()
+	loader := &envLoader{
+		roles:     roles,
+		lookup:    newEnvOptions(opts).lookup,
+		fieldErrs: &FieldErrors{},
+		ancestors: make(map[reflect.Type]bool),
+	}
+	loader.load(structValue, prefix, "", "", true)
+	validateStructValue(structValue, roles, "", "", loader.fieldErrs)
+	return loader.fieldErrs.errOrNil()
+}
+
+// envLoader sets struct fields from the variables returned by lookup.
+type envLoader struct {
+	roles     []string
+	lookup    func(key string) (string, bool)
+	fieldErrs *FieldErrors
+	ancestors map[reflect.Type]bool // struct types on the current path, to stop at recursive types
+}
+
+// load sets the fields of structValue and reports whether any variable was applied.
+func (l *envLoader) load(structValue reflect.Value, prefix string, pathPrefix string, jsonPrefix string, parentWritable bool) bool {
+	structType := structValue.Type()
+	l.ancestors[structType] = true
+	defer delete(l.ancestors, structType)
+	// the `writeif` conditions and `@immutable` markers apply to the struct as it was before any variable was loaded
+	allowed := make([]bool, structType.NumField())
+	for i := range allowed {
+		field := structType.Field(i)
+		if _, ok := field.Tag.Lookup(tagNameWriteXS); field.Anonymous && !ok {
+			// embedded structs without an own `writexs` tag only restrict their promoted fields by `writeif`
+			allowed[i] = conditionHolds(structValue, field.Tag.Get(tagNameWriteIf), l.roles)
+			continue
+		}
+		allowed[i] = writeDenial(structValue, field, l.roles) == nil
+	}
+	loaded := false
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		envName, tagged := field.Tag.Lookup(tagNameEnv)
+		// fields of unexported embedded structs are promoted too, unless they are behind a pointer
+		promoted := field.Anonymous && field.Type.Kind() == reflect.Struct && !isOpaqueStruct(field.Type)
+		if (!field.IsExported() && !promoted) || envName == "-" {
+			continue
+		}
+		if envName == "" {
+			envName = strings.ToUpper(toSnakeCase(field.Name))
+		}
+		path := pathPrefix + field.Name
+		jsonPath := jsonPrefix + jsonFieldName(field)
+		value := structValue.Field(i)
+
+		nestedType := indirectType(field.Type)
+		if nestedType.Kind() == reflect.Struct && !isOpaqueStruct(nestedType) {
+			if l.ancestors[nestedType] {
+				continue
+			}
+			nestedPrefix := prefix + envName + "_"
+			writable := parentWritable && allowed[i]
+			if field.Anonymous && !tagged {
+				// promoted fields keep the prefix of their parent, like with encoding/json
+				nestedPrefix = prefix
+			}
+			if !writable {
+				continue
+			}
+			if value.Kind() == reflect.Ptr && value.IsNil() {
+				nested := reflect.New(nestedType)
+				if l.load(nested.Elem(), nestedPrefix, path+".", jsonPath+".", writable) {
+					setFormParsed(value, nested.Elem())
+					loaded = true
+				}
+				continue
+			}
+			for value.Kind() == reflect.Ptr {
+				value = value.Elem()
+			}
+			loaded = l.load(value, nestedPrefix, path+".", jsonPath+".", writable) || loaded
+			continue
+		}
+
+		envValue, ok := l.lookup(prefix + envName)
+		if !ok || !parentWritable || !allowed[i] {
+			continue
+		}
+		if err := setFormValue(value, []string{envValue}, true); err != nil {
+			l.fieldErrs.Add(&FieldError{
+				Path:     path,
+				JSONName: jsonPath,
+				Cause:    err,
+				Expected: field.Type,
+				Actual:   reflect.TypeOf(envValue),
+				Value:    envValue,
+			})
+			continue
+		}
+		loaded = true
+	}
+	return loaded
+}
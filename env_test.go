@@ -0,0 +1,148 @@
+package struccy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type envDatabase struct {
+	Host     string `env:"HOST" writexs:"*"`
+	Port     int    `writexs:"*" validate:"max=65535"`
+	Password string `env:"PASSWORD" writexs:"dev"`
+}
+
+type envTelemetry struct {
+	Endpoint string `writexs:"*"`
+}
+
+type envServiceConfig struct {
+	envTelemetry
+	LogLevel  string        `env:"LOG_LEVEL" writexs:"*"`
+	Timeout   time.Duration `writexs:"*"`
+	Replicas  int           `writexs:"ops"`
+	Features  []string      `writexs:"*"`
+	StartedAt time.Time     `writexs:"*"`
+	Database  envDatabase   `env:"DB" writexs:"*"`
+	Cache     *envDatabase  `writexs:"*"`
+	Backup    *envDatabase  `writexs:"*"`
+	Internal  string        `env:"-" writexs:"*"`
+}
+
+func envLookup(vars map[string]string) EnvOption {
+	return WithLookupEnv(func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	})
+}
+
+func TestLoadEnv(t *testing.T) {
+	vars := map[string]string{
+		"APP_ENDPOINT":    "otel:4317",
+		"APP_LOG_LEVEL":   "debug",
+		"APP_TIMEOUT":     "5s",
+		"APP_REPLICAS":    "5",
+		"APP_FEATURES":    "a, b",
+		"APP_STARTED_AT":  "2024-05-01T12:00:00Z",
+		"APP_DB_HOST":     "db.internal",
+		"APP_DB_PORT":     "5433",
+		"APP_DB_PASSWORD": "hunter2",
+		"APP_CACHE_HOST":  "cache.internal",
+		"APP_INTERNAL":    "x",
+		"INTERNAL":        "x",
+		"LOG_LEVEL":       "warn",
+	}
+	config := &envServiceConfig{
+		Replicas: 2,
+		Database: envDatabase{Host: "localhost", Port: 5432, Password: "secret"},
+	}
+	err := LoadEnv(config, "APP_", []string{"prod"}, envLookup(vars))
+	assert.NoError(t, err)
+	assert.Equal(t, &envServiceConfig{
+		envTelemetry: envTelemetry{Endpoint: "otel:4317"},
+		LogLevel:     "debug",
+		Timeout:      5 * time.Second,
+		Replicas:     2,
+		Features:     []string{"a", "b"},
+		StartedAt:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Database:     envDatabase{Host: "db.internal", Port: 5433, Password: "secret"},
+		Cache:        &envDatabase{Host: "cache.internal"},
+	}, config)
+
+	config = &envServiceConfig{}
+	err = LoadEnv(config, "APP_", []string{"dev", "ops"}, envLookup(vars))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, config.Replicas)
+	assert.Equal(t, "hunter2", config.Database.Password)
+
+	// without a prefix
+	config = &envServiceConfig{}
+	err = LoadEnv(config, "", []string{"prod"}, envLookup(vars))
+	assert.NoError(t, err)
+	assert.Equal(t, "warn", config.LogLevel)
+	assert.Equal(t, "", config.Internal)
+	assert.Nil(t, config.Cache)
+
+	err = LoadEnv(*config, "", nil)
+	assert.Equal(t, ErrInvalidStructPointer, err)
+}
+
+func TestLoadEnvErrors(t *testing.T) {
+	vars := map[string]string{
+		"TIMEOUT":  "soon",
+		"DB_PORT":  "70000",
+		"REPLICAS": "",
+		"DB_HOST":  "",
+	}
+	config := &envServiceConfig{Replicas: 3, Database: envDatabase{Host: "localhost"}}
+	err := LoadEnv(config, "", []string{"ops"}, envLookup(vars))
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 2, fieldErrs.Len())
+	assert.Equal(t, "Timeout", fieldErrs.Errors[0].Path)
+	assert.ErrorIs(t, fieldErrs.Errors[0], ErrInvalidFieldValue)
+	assert.Equal(t, "soon", fieldErrs.Errors[0].Value)
+	assert.Equal(t, "Database.Port", fieldErrs.Errors[1].Path)
+	assert.ErrorIs(t, fieldErrs.Errors[1], ErrValidationFailed)
+	// empty values reset non-string fields
+	assert.Equal(t, 0, config.Replicas)
+	assert.Equal(t, "", config.Database.Host)
+}
+
+func TestLoadEnvWriteIf(t *testing.T) {
+	type featureConfig struct {
+		Locked bool   `writexs:"*"`
+		Mode   string `writexs:"*" writeif:"!Locked"`
+	}
+	vars := map[string]string{"APP_LOCKED": "false", "APP_MODE": "fast"}
+
+	// the condition applies to the config before loading, so unlocking does not unlock Mode
+	config := &featureConfig{Locked: true, Mode: "safe"}
+	assert.NoError(t, LoadEnv(config, "APP_", nil, envLookup(vars)))
+	assert.Equal(t, &featureConfig{Locked: false, Mode: "safe"}, config)
+
+	assert.NoError(t, LoadEnv(config, "APP_", nil, envLookup(vars)))
+	assert.Equal(t, "fast", config.Mode)
+}
+
+func TestLoadEnvEmbeddedAccess(t *testing.T) {
+	type envAccess struct {
+		Secret string `writexs:"*"`
+	}
+	type appConfig struct {
+		envAccess `writexs:"admin"`
+		Name      string `writexs:"*"`
+	}
+	vars := map[string]string{"APP_SECRET": "s", "APP_NAME": "n"}
+
+	// the `writexs` tag of an embedded struct applies to its promoted fields
+	config := &appConfig{}
+	assert.NoError(t, LoadEnv(config, "APP_", []string{"user"}, envLookup(vars)))
+	assert.Equal(t, &appConfig{Name: "n"}, config)
+
+	config = &appConfig{}
+	assert.NoError(t, LoadEnv(config, "APP_", []string{"admin"}, envLookup(vars)))
+	assert.Equal(t, &appConfig{envAccess: envAccess{Secret: "s"}, Name: "n"}, config)
+}
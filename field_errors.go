@@ -0,0 +1,105 @@
+package struccy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldError describes a single field that could not be written.
+// Cause holds one of the package's sentinel errors (e.g. ErrFieldTypeMismatch,
+// ErrUnauthorizedFieldSet), so callers can still use errors.Is on it.
+type FieldError struct {
+	Path     string       // Go field path, e.g. "Address.City"
+	JSONName string       // JSON field path, e.g. "address.city"
+	Cause    error        // sentinel error describing the failure
+	Expected reflect.Type // type of the target field, nil if unknown
+	Actual   reflect.Type // type of the offending value, nil if unknown or nil value
+	Value    any          // the offending value
+	Rule     string       // the validation rule that failed, e.g. "min=3", empty for other causes
+}
+
+func (e *FieldError) Error() string {
+	msg := fmt.Sprintf("field '%s': %v", e.Path, e.Cause)
+	if e.Rule != "" {
+		return msg + ": " + e.Rule
+	}
+	if e.Expected != nil && e.Actual != nil {
+		msg += fmt.Sprintf(", expected %v, got %v", e.Expected, e.Actual)
+	}
+	return msg
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Cause
+}
+
+// FieldErrors aggregates all FieldError entries collected during a write operation.
+// It implements `Unwrap() []error`, so errors.Is and errors.As match against every entry
+// as well as against the sentinel causes.
+type FieldErrors struct {
+	Errors []*FieldError
+}
+
+func (e *FieldErrors) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return fmt.Sprintf("%d field errors: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+func (e *FieldErrors) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// Add appends a FieldError to the collection.
+func (e *FieldErrors) Add(fe *FieldError) {
+	e.Errors = append(e.Errors, fe)
+}
+
+// Len returns the number of collected field errors.
+func (e *FieldErrors) Len() int {
+	return len(e.Errors)
+}
+
+// errOrNil returns the collection as an error, or nil if it is empty.
+func (e *FieldErrors) errOrNil() error {
+	if e == nil || len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// newFieldError builds a FieldError for the given struct field, cause and offending value.
+// Expected is taken from the field type, Actual from the value (if it is not nil).
+func newFieldError(field reflect.StructField, cause error, value any) *FieldError {
+	fe := &FieldError{
+		Path:     field.Name,
+		JSONName: jsonFieldName(field),
+		Cause:    cause,
+		Expected: field.Type,
+		Value:    value,
+	}
+	if value != nil {
+		fe.Actual = reflect.TypeOf(value)
+	}
+	return fe
+}
+
+// jsonFieldName returns the name used for the field by encoding/json,
+// i.e. the first part of the json tag or the Go field name if there is none.
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,112 @@
+package struccy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeMapStringFieldsToStruct_FieldErrors(t *testing.T) {
+	type Target struct {
+		Name  string `json:"name"`
+		Age   int    `json:"age"`
+		Email string `json:"email"`
+	}
+
+	target := &Target{Name: "initial"}
+	_, err := MergeMapStringFieldsToStruct(target, map[string]any{
+		"Name":  "updated",
+		"Age":   "not a number",
+		"Email": nil,
+	}, nil)
+	assert.Error(t, err)
+
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 2, fieldErrs.Len())
+
+	ageErr := fieldErrs.Errors[0]
+	assert.Equal(t, "Age", ageErr.Path)
+	assert.Equal(t, "age", ageErr.JSONName)
+	assert.Equal(t, ErrFieldTypeMismatch, ageErr.Cause)
+	assert.Equal(t, reflect.TypeOf(0), ageErr.Expected)
+	assert.Equal(t, reflect.TypeOf(""), ageErr.Actual)
+	assert.Equal(t, "not a number", ageErr.Value)
+
+	emailErr := fieldErrs.Errors[1]
+	assert.Equal(t, "Email", emailErr.Path)
+	assert.Equal(t, "email", emailErr.JSONName)
+	assert.Equal(t, ErrFieldIsNil, emailErr.Cause)
+	assert.Nil(t, emailErr.Actual)
+
+	assert.True(t, errors.Is(err, ErrFieldTypeMismatch))
+	assert.True(t, errors.Is(err, ErrFieldIsNil))
+	assert.False(t, errors.Is(err, ErrUnauthorizedFieldSet))
+}
+
+func TestMergeStructUpdateTo_FieldErrors(t *testing.T) {
+	type Target struct {
+		Field1 string `writexs:"admin"`
+		Field2 int    `writexs:"admin"`
+	}
+	type Update struct {
+		Field1 int    `writexs:"admin"`
+		Field2 string `writexs:"admin"`
+		Field3 bool   `writexs:"admin"`
+	}
+
+	_, err := MergeStructUpdateTo(&Target{}, &Update{Field1: 1, Field2: "two"}, []string{"admin"})
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 3, fieldErrs.Len())
+	assert.Equal(t, "Field1", fieldErrs.Errors[0].Path)
+	assert.Equal(t, ErrFieldTypeMismatch, fieldErrs.Errors[0].Cause)
+	assert.Equal(t, "Field2", fieldErrs.Errors[1].Path)
+	assert.Equal(t, ErrFieldTypeMismatch, fieldErrs.Errors[1].Cause)
+	assert.Equal(t, "Field3", fieldErrs.Errors[2].Path)
+	assert.True(t, errors.Is(fieldErrs.Errors[2], ErrFieldNotFound))
+}
+
+func TestUpdateStructFields_FieldErrors(t *testing.T) {
+	type Entity struct {
+		Field1 string `writexs:"admin" json:"field_1"`
+		Field2 int    `writexs:"admin" json:"field_2"`
+		Field3 *int   `writexs:"admin" json:"field_3"`
+	}
+	type Incoming struct {
+		Field1 []string
+		Field2 string
+		Field3 int
+	}
+
+	entity := &Entity{}
+	updated, unsettable, err := UpdateStructFields(entity, &Incoming{Field1: []string{"a"}, Field2: "b", Field3: 3}, []string{"admin"}, true, false)
+	assert.Nil(t, updated)
+	assert.Len(t, unsettable, 3)
+
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 3, fieldErrs.Len())
+	assert.Equal(t, "field_1", fieldErrs.Errors[0].JSONName)
+	assert.Equal(t, "field_2", fieldErrs.Errors[1].JSONName)
+	assert.Equal(t, "field_3", fieldErrs.Errors[2].JSONName)
+
+	// ignoring unsettables reports no error
+	_, unsettable, err = UpdateStructFields(&Entity{}, &Incoming{Field1: []string{"a"}, Field2: "b"}, []string{"admin"}, true, true)
+	assert.NoError(t, err)
+	assert.Len(t, unsettable, 2)
+}
+
+func TestFieldErrors_Error(t *testing.T) {
+	single := &FieldErrors{}
+	single.Add(&FieldError{Path: "Age", Cause: ErrFieldTypeMismatch, Expected: reflect.TypeOf(0), Actual: reflect.TypeOf("")})
+	assert.Equal(t, "field 'Age': field type mismatch, expected int, got string", single.Error())
+
+	multi := &FieldErrors{}
+	multi.Add(&FieldError{Path: "Age", Cause: ErrFieldTypeMismatch})
+	multi.Add(&FieldError{Path: "Name", Cause: ErrFieldIsNil})
+	assert.Equal(t, "2 field errors: field 'Age': field type mismatch; field 'Name': field value is nil", multi.Error())
+	assert.Nil(t, (&FieldErrors{}).errOrNil())
+}
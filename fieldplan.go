@@ -0,0 +1,548 @@
+package struccy
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// FieldNaming selects the keys used when converting structs to maps and JSON.
+type FieldNaming int
+
+const (
+	// GoFieldNames keys every exported field by its Go name and ignores the json tag (the default).
+	GoFieldNames FieldNaming = iota
+	// JSONFieldNames keys fields exactly like encoding/json: json tag names, `-`, `omitempty`,
+	// `omitzero` and `string` are honored and fields of embedded structs are promoted.
+	JSONFieldNames
+	// SnakeCaseFieldNames is like JSONFieldNames, but fields without a json tag name are keyed
+	// by their Go name in snake_case (UserID -> user_id).
+	SnakeCaseFieldNames
+	// CamelCaseFieldNames is like JSONFieldNames, but fields without a json tag name are keyed
+	// by their Go name in camelCase (UserID -> userID).
+	CamelCaseFieldNames
+	// LowerCaseFieldNames is like JSONFieldNames, but fields without a json tag name are keyed
+	// by their lower-cased Go name (UserID -> userid), as gopkg.in/yaml.v3 does.
+	LowerCaseFieldNames
+)
+
+func (naming FieldNaming) String() string {
+	switch naming {
+	case JSONFieldNames:
+		return "json"
+	case SnakeCaseFieldNames:
+		return "snake_case"
+	case CamelCaseFieldNames:
+		return "camelCase"
+	case LowerCaseFieldNames:
+		return "lowercase"
+	}
+	return "go"
+}
+
+// fieldName returns the key for a field without an explicit tag name.
+func (naming FieldNaming) fieldName(goName string) string {
+	switch naming {
+	case SnakeCaseFieldNames:
+		return toSnakeCase(goName)
+	case CamelCaseFieldNames:
+		return toCamelCase(goName)
+	case LowerCaseFieldNames:
+		return strings.ToLower(goName)
+	}
+	return goName
+}
+
+// fieldPlan describes how a single struct field is converted.
+type fieldPlan struct {
+	name      string
+	index     []int
+	field     reflect.StructField
+	readXS    string
+	writeXS   string
+	readIf    string
+	writeIf   string
+	omitEmpty bool
+	omitZero  bool
+	asString  bool
+	parents   []embeddedParent // embedded structs the field is promoted through, outermost first
+}
+
+// embeddedParent is an embedded struct field whose fields are promoted. Its own access tags and
+// conditions apply to the promoted fields as well.
+type embeddedParent struct {
+	holder     []int        // index of the struct holding the embedded field, empty for the top-level struct
+	holderType reflect.Type // type of that struct
+	field      reflect.StructField
+}
+
+// allowed reports whether the access tag of the operation, if the embedded field has one, and its
+// `readif`/`writeif` condition allow the roles to access the promoted fields of structValue.
+func (p *embeddedParent) allowed(structValue reflect.Value, roles []string, op Operation) bool {
+	holder, err := structValue.FieldByIndexErr(p.holder)
+	if err == nil && holder.Kind() == reflect.Ptr && !holder.IsNil() {
+		holder = holder.Elem()
+	}
+	if err != nil || holder.Kind() != reflect.Struct {
+		// promoted through a nil pointer, the conditions see the zero struct
+		holder = reflect.New(p.holderType).Elem()
+	}
+	if _, ok := p.field.Tag.Lookup(op.tagName()); ok {
+		return fieldAccessAllowed(holder, p.field, roles, op)
+	}
+	return conditionHolds(holder, p.field.Tag.Get(op.conditionTagName()), roles)
+}
+
+// embeddedParents returns the embedded struct fields a field at index of structType is promoted through,
+// outermost first.
+func embeddedParents(structType reflect.Type, index []int) []embeddedParent {
+	parents := make([]embeddedParent, 0, len(index)-1)
+	holderType := structType
+	for i := 0; i < len(index)-1; i++ {
+		field := holderType.Field(index[i])
+		parents = append(parents, embeddedParent{holder: index[:i], holderType: holderType, field: field})
+		holderType = indirectType(field.Type)
+	}
+	return parents
+}
+
+// promotedAllowed reports whether the embedded structs the field of structValue is promoted through
+// allow the roles to access it with the operation, like the parents of a fieldPlan.
+func promotedAllowed(structValue reflect.Value, field reflect.StructField, roles []string, op Operation) bool {
+	for _, parent := range embeddedParents(structValue.Type(), field.Index) {
+		if !parent.allowed(structValue, roles, op) {
+			return false
+		}
+	}
+	return true
+}
+
+// xs returns the access tag checked for the operation.
+func (f *fieldPlan) xs(op Operation) string {
+	if op == OpWrite {
+		return f.writeXS
+	}
+	return f.readXS
+}
+
+// allowed reports whether the roles may access the field of structValue for the operation: the access
+// tags of the field and its embedded parents must allow it and their `readif`/`writeif` conditions must
+// hold for structValue. Writes also check the `writexs` markers.
+func (f *fieldPlan) allowed(structValue reflect.Value, roles []string, op Operation) bool {
+	return f.xsAllowed(roles, op) && f.conditionsHold(structValue, roles, op)
+}
+
+// xsAllowed reports whether the access tags of the field and of the embedded structs it is promoted
+// through allow the roles to access it with the operation, regardless of the struct's state.
+func (f *fieldPlan) xsAllowed(roles []string, op Operation) bool {
+	if !op.accessAllowed(roles, f.xs(op)) {
+		return false
+	}
+	for i := range f.parents {
+		if tag, ok := f.parents[i].field.Tag.Lookup(op.tagName()); ok && !op.accessAllowed(roles, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// conditionsHold reports whether the `readif`/`writeif` conditions of the field and its embedded parents
+// hold for structValue. Writes also check the `writexs` markers.
+func (f *fieldPlan) conditionsHold(structValue reflect.Value, roles []string, op Operation) bool {
+	for i := range f.parents {
+		if !f.parents[i].allowed(structValue, roles, op) {
+			return false
+		}
+	}
+	if op == OpWrite {
+		return conditionHolds(structValue, f.writeIf, roles) && markerDenial(structValue, f.field, false) == nil
+	}
+	return conditionHolds(structValue, f.readIf, roles)
+}
+
+// allowedPlans returns the plans whose fields of structValue the roles may access. Write paths call it
+// before modifying the struct, so that a `writeif` condition cannot be satisfied by another field of
+// the same update.
+func allowedPlans(structValue reflect.Value, plans []fieldPlan, roles []string, op Operation) map[*fieldPlan]bool {
+	allowed := make(map[*fieldPlan]bool, len(plans))
+	for i := range plans {
+		if plans[i].allowed(structValue, roles, op) {
+			allowed[&plans[i]] = true
+		}
+	}
+	return allowed
+}
+
+// value returns the field value of structValue, or false if it is promoted through a nil embedded pointer.
+func (f *fieldPlan) value(structValue reflect.Value) (reflect.Value, bool) {
+	value, err := structValue.FieldByIndexErr(f.index)
+	return value, err == nil
+}
+
+// omit reports whether the value is left out because of the `omitempty` or `omitzero` option.
+func (f *fieldPlan) omit(value reflect.Value) bool {
+	return (f.omitEmpty && isEmptyValue(value)) || (f.omitZero && isZeroValue(value))
+}
+
+// output returns the value to store in a map, applying the `string` option like encoding/json.
+func (f *fieldPlan) output(value reflect.Value) any {
+	if !f.asString {
+		return value.Interface()
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.String:
+		quoted, _ := json.Marshal(value.String())
+		return string(quoted)
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(value.Interface())
+	}
+	return value.Interface()
+}
+
+// Tag keys whose names and options are honored by the field plans.
+const (
+	tagKeyJSON = "json"
+	tagKeyYAML = "yaml"
+	tagKeyTOML = "toml"
+	tagKeyForm = "form"
+	tagKeyCSV  = "csv"
+	tagKeyXML  = "xml"
+)
+
+type fieldPlanKey struct {
+	structType reflect.Type
+	tagKey     string
+	naming     FieldNaming
+}
+
+// fieldPlanCache caches the []fieldPlan of every struct type, tag key and naming.
+var fieldPlanCache sync.Map
+
+// fieldPlans returns the fields of the struct type as seen by encoding/json with the naming, in field order.
+// The result is cached and must not be modified.
+func fieldPlans(structType reflect.Type, naming FieldNaming) []fieldPlan {
+	return taggedFieldPlans(structType, tagKeyJSON, naming)
+}
+
+// taggedFieldPlans is like fieldPlans, but reads the names and options from the tag with the given key.
+func taggedFieldPlans(structType reflect.Type, tagKey string, naming FieldNaming) []fieldPlan {
+	key := fieldPlanKey{structType: structType, tagKey: tagKey, naming: naming}
+	if cached, ok := fieldPlanCache.Load(key); ok {
+		return cached.([]fieldPlan)
+	}
+	var plans []fieldPlan
+	switch {
+	case naming == GoFieldNames:
+		plans = goFieldPlans(structType)
+	case tagKey == tagKeyXML:
+		plans = xmlFieldPlans(structType)
+	default:
+		plans = tagFieldPlans(structType, tagKey, naming)
+	}
+	fieldPlanCache.Store(key, plans)
+	return plans
+}
+
+func goFieldPlans(structType reflect.Type) []fieldPlan {
+	plans := make([]fieldPlan, 0, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		plans = append(plans, fieldPlan{
+			name:    field.Name,
+			index:   field.Index,
+			field:   field,
+			readXS:  field.Tag.Get(tagNameReadXS),
+			writeXS: field.Tag.Get(tagNameWriteXS),
+			readIf:  field.Tag.Get(tagNameReadIf),
+			writeIf: field.Tag.Get(tagNameWriteIf),
+		})
+	}
+	return plans
+}
+
+// tagFieldPlans collects the fields the way encoding/json does: embedded structs without a
+// tag name are flattened, and of several fields with the same name the shallowest wins
+// (or the tagged one on the same depth); remaining conflicts drop the name entirely.
+// For the yaml tag, only fields with the `inline` option are flattened, as gopkg.in/yaml.v3 does.
+func tagFieldPlans(structType reflect.Type, tagKey string, naming FieldNaming) []fieldPlan {
+	candidates := fieldCandidates(structType, tagKey, naming)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].name != candidates[j].name {
+			return candidates[i].name < candidates[j].name
+		}
+		if candidates[i].depth != candidates[j].depth {
+			return candidates[i].depth < candidates[j].depth
+		}
+		return candidates[i].tagged && !candidates[j].tagged
+	})
+	plans := make([]fieldPlan, 0, len(candidates))
+	for i := 0; i < len(candidates); {
+		j := i + 1
+		for j < len(candidates) && candidates[j].name == candidates[i].name {
+			j++
+		}
+		dominant := candidates[i]
+		if j-i == 1 || candidates[i+1].depth > dominant.depth || (dominant.tagged && !candidates[i+1].tagged) {
+			plans = append(plans, dominant.fieldPlan)
+		}
+		i = j
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		return lessIndex(plans[i].index, plans[j].index)
+	})
+	return plans
+}
+
+// fieldCandidate is a field found by fieldCandidates, before conflicting names are resolved.
+type fieldCandidate struct {
+	fieldPlan
+	depth  int  // number of embedded structs the field is promoted through
+	tagged bool // whether the tag names the field
+}
+
+// fieldCandidates returns all fields of the struct type with embedded structs flattened like in
+// tagFieldPlans, including fields whose names conflict, in breadth-first order.
+func fieldCandidates(structType reflect.Type, tagKey string, naming FieldNaming) []fieldCandidate {
+	type queued struct {
+		structType reflect.Type
+		index      []int
+		parents    []embeddedParent
+	}
+
+	var candidates []fieldCandidate
+	visited := map[reflect.Type]bool{}
+	next := []queued{{structType: structType}}
+	for depth := 0; len(next) > 0; depth++ {
+		current := next
+		next = nil
+		for _, q := range current {
+			if visited[q.structType] {
+				continue
+			}
+			visited[q.structType] = true
+			for i := 0; i < q.structType.NumField(); i++ {
+				field := q.structType.Field(i)
+				fieldType := indirectType(field.Type)
+				if field.Anonymous {
+					if !field.IsExported() && fieldType.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !field.IsExported() {
+					continue
+				}
+				tag, ok := field.Tag.Lookup(tagKey)
+				if !ok && tagKey == tagKeyCSV {
+					// csv columns fall back to the json names
+					tag = field.Tag.Get(tagKeyJSON)
+				}
+				if tag == "-" {
+					continue
+				}
+				tagName, tagOptions, _ := strings.Cut(tag, ",")
+				index := append(append([]int{}, q.index...), i)
+				inline := field.Anonymous && tagName == ""
+				if tagKey == tagKeyYAML {
+					inline = hasTagOption(tagOptions, "inline")
+				}
+				if inline && fieldType.Kind() == reflect.Struct {
+					parents := append(append([]embeddedParent{}, q.parents...), embeddedParent{holder: q.index, holderType: q.structType, field: field})
+					next = append(next, queued{structType: fieldType, index: index, parents: parents})
+					continue
+				}
+				if !field.IsExported() {
+					continue
+				}
+				name := tagName
+				if name == "" {
+					name = naming.fieldName(field.Name)
+				}
+				plan := fieldPlan{
+					name:    name,
+					index:   index,
+					field:   field,
+					readXS:  field.Tag.Get(tagNameReadXS),
+					writeXS: field.Tag.Get(tagNameWriteXS),
+					readIf:  field.Tag.Get(tagNameReadIf),
+					writeIf: field.Tag.Get(tagNameWriteIf),
+					parents: q.parents,
+				}
+				for _, option := range strings.Split(tagOptions, ",") {
+					switch option {
+					case "omitempty":
+						plan.omitEmpty = true
+					case "omitzero":
+						plan.omitZero = true
+					case "string":
+						plan.asString = tagKey == tagKeyJSON
+					}
+				}
+				candidates = append(candidates, fieldCandidate{fieldPlan: plan, depth: depth, tagged: tagName != ""})
+			}
+		}
+	}
+	return candidates
+}
+
+func hasTagOption(tagOptions string, option string) bool {
+	for _, o := range strings.Split(tagOptions, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+func lessIndex(a []int, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// structFieldsToMap converts the fields of structValue for which include returns true into a map.
+func structFieldsToMap(structValue reflect.Value, naming FieldNaming, skipNilValues bool, include func(plan *fieldPlan) bool) map[string]any {
+	fieldMap := make(map[string]any)
+	eachIncludedField(structValue, tagKeyJSON, naming, skipNilValues, include, func(plan *fieldPlan, value reflect.Value) {
+		fieldMap[plan.name] = plan.output(value)
+	})
+	return fieldMap
+}
+
+// eachIncludedField calls fn in field order for every field of structValue for which include returns true
+// and that is not left out by `omitempty`/`omitzero`, skipNilValues or a nil embedded pointer.
+func eachIncludedField(structValue reflect.Value, tagKey string, naming FieldNaming, skipNilValues bool, include func(plan *fieldPlan) bool, fn func(plan *fieldPlan, value reflect.Value)) {
+	plans := taggedFieldPlans(structValue.Type(), tagKey, naming)
+	for i := range plans {
+		plan := &plans[i]
+		if !include(plan) {
+			continue
+		}
+		value, ok := plan.value(structValue)
+		if !ok || (skipNilValues && isNil(value)) || plan.omit(value) {
+			continue
+		}
+		fn(plan, value)
+	}
+}
+
+// decodeFieldsWithWriteXS decodes the document keys into the matching fields of targetStruct the
+// roles may write, using decode to decode the value of a key into a field pointer. Keys without
+// a writable field are skipped; with foldCase, keys also match field names case-insensitively.
+func decodeFieldsWithWriteXS(targetStruct any, xsList []string, tagKey string, naming FieldNaming, foldCase bool, keys []string, decode func(key string, target any) error) error {
+	targetValue := reflect.ValueOf(targetStruct)
+	if targetValue.Kind() != reflect.Ptr || targetValue.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	structValue := targetValue.Elem()
+	plans := taggedFieldPlans(structValue.Type(), tagKey, naming)
+	writable := allowedPlans(structValue, plans, xsList, OpWrite)
+
+	fieldErrs := &FieldErrors{}
+	for _, key := range keys {
+		plan := findFieldPlan(plans, key, foldCase)
+		if plan == nil || !writable[plan] {
+			continue
+		}
+		field := fieldByIndexAlloc(structValue, plan.index)
+		if err := decode(key, field.Addr().Interface()); err != nil {
+			fieldErrs.Add(&FieldError{
+				Path:     plan.field.Name,
+				JSONName: jsonFieldName(plan.field),
+				Cause:    fmt.Errorf("%w: %v", ErrInvalidFieldValue, err),
+				Expected: plan.field.Type,
+			})
+		}
+	}
+	validateStructValue(structValue, xsList, "", "", fieldErrs)
+	return fieldErrs.errOrNil()
+}
+
+// findFieldPlan returns the plan with the given name, or nil.
+func findFieldPlan(plans []fieldPlan, name string, foldCase bool) *fieldPlan {
+	for i := range plans {
+		if plans[i].name == name {
+			return &plans[i]
+		}
+	}
+	if foldCase {
+		for i := range plans {
+			if strings.EqualFold(plans[i].name, name) {
+				return &plans[i]
+			}
+		}
+	}
+	return nil
+}
+
+// isEmptyValue reports whether the value is empty in the sense of the `omitempty` json option.
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Ptr:
+		return value.IsZero()
+	}
+	return false
+}
+
+// isZeroValue reports whether the value is zero in the sense of the `omitzero` json option,
+// using an `IsZero() bool` method if the type has one.
+func isZeroValue(value reflect.Value) bool {
+	if (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) && value.IsNil() {
+		return true
+	}
+	if isZeroer, ok := value.Interface().(interface{ IsZero() bool }); ok {
+		return isZeroer.IsZero()
+	}
+	return value.IsZero()
+}
+
+// toSnakeCase converts a Go name to snake_case, keeping initialisms together (HTTPServer -> http_server).
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// toCamelCase converts a Go name to camelCase by lowering its leading initialism (HTTPServer -> httpServer).
+func toCamelCase(name string) string {
+	runes := []rune(name)
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
@@ -0,0 +1,183 @@
+package struccy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type planBase struct {
+	ID        string    `json:"id" readxs:"*"`
+	CreatedAt time.Time `json:"created_at,omitzero" readxs:"*"`
+}
+
+type planAudit struct {
+	UpdatedBy string `readxs:"*"`
+	Nick      string `json:"nick" readxs:"*"` // shadowed by the shallower planUser.Nick
+}
+
+type planUser struct {
+	planBase
+	*planAudit
+	Name     string            `json:"name" readxs:"*"`
+	Nick     string            `json:"nick,omitempty" readxs:"*"`
+	Age      int               `json:"age,string" readxs:"*"`
+	Score    *float64          `json:",string" readxs:"*"`
+	Password string            `json:"-" readxs:"*"`
+	Secret   string            `json:"secret" readxs:"admin"`
+	Labels   map[string]string `json:"labels,omitempty" readxs:"*"`
+	HTTPHost string            `readxs:"*"`
+	internal string
+}
+
+func TestStructToJSONFieldsWithReadXS_MatchesEncodingJSON(t *testing.T) {
+	score := 9.5
+	user := &planUser{
+		planBase:  planBase{ID: "u1"},
+		planAudit: &planAudit{UpdatedBy: "ops", Nick: "shadowed"},
+		Name:      "Ada",
+		Age:       36,
+		Score:     &score,
+		Password:  "hunter2",
+		HTTPHost:  "example.com",
+		internal:  "x",
+	}
+
+	got, err := StructToJSONFieldsWithReadXS(user, []string{"admin"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	expected, err := json.Marshal(user)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(expected), got)
+
+	fieldMap, err := StructToMapFieldsWithReadXS(user, []string{"user"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"id":        "u1",
+		"UpdatedBy": "ops",
+		"name":      "Ada",
+		"age":       "36",
+		"Score":     "9.5",
+		"HTTPHost":  "example.com",
+	}, fieldMap)
+
+	// promoted through a nil embedded pointer
+	user.planAudit = nil
+	fieldMap, err = StructToMapFieldsWithReadXS(user, []string{"user"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.NotContains(t, fieldMap, "UpdatedBy")
+}
+
+func TestStructToMapFieldsWithReadXS_Naming(t *testing.T) {
+	type Account struct {
+		UserID    string `readxs:"*"`
+		HTTPProxy string `readxs:"*"`
+		Plan      string `json:"plan_name" readxs:"*"`
+		Hidden    string `json:"-" readxs:"*"`
+	}
+	account := &Account{UserID: "u1", HTTPProxy: "p", Plan: "free", Hidden: "h"}
+
+	snake, err := StructToMapFieldsWithReadXS(account, []string{"user"}, WithFieldNaming(SnakeCaseFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"user_id": "u1", "http_proxy": "p", "plan_name": "free"}, snake)
+
+	camel, err := StructToMapFieldsWithReadXS(account, []string{"user"}, WithFieldNaming(CamelCaseFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"userID": "u1", "httpProxy": "p", "plan_name": "free"}, camel)
+
+	goNames, err := StructToMapFieldsWithReadXS(account, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"UserID": "u1", "HTTPProxy": "p", "Plan": "free", "Hidden": "h"}, goNames)
+}
+
+func TestStructToMapFieldsWithWriteXS_JSONNames(t *testing.T) {
+	type Profile struct {
+		Name  string `json:"name" writexs:"*"`
+		Bio   string `json:"bio,omitempty" writexs:"*"`
+		Email string `writexs:"*"`
+	}
+	fieldMap, err := StructToMapFieldsWithWriteXS(&Profile{Name: "Ada", Email: "a@b.c"}, []string{"user"}, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "Ada", "Email": "a@b.c"}, fieldMap)
+
+	// an explicit naming wins over useJsonFieldNames
+	fieldMap, err = StructToMapFieldsWithWriteXS(&Profile{Name: "Ada"}, []string{"user"}, false, true, WithFieldNaming(GoFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"Name": "Ada", "Bio": "", "Email": ""}, fieldMap)
+}
+
+type embeddedAccess struct {
+	Secret string `json:"secret" readxs:"*" writexs:"*"`
+}
+
+type embeddedFlags struct {
+	Beta bool `json:"beta" readxs:"*" writexs:"*"`
+}
+
+type embeddingUser struct {
+	embeddedAccess `readxs:"admin" writexs:"admin"`
+	*embeddedFlags `readif:"Public"`
+	Name           string `json:"name" readxs:"*" writexs:"*"`
+	Public         bool   `json:"public" readxs:"*"`
+}
+
+func TestEmbeddedStructAccess(t *testing.T) {
+	user := &embeddingUser{embeddedAccess: embeddedAccess{Secret: "s"}, embeddedFlags: &embeddedFlags{Beta: true}, Name: "n"}
+
+	// the naming does not change the access: the tags of the embedded field apply to its promoted fields
+	goNames, err := StructToMapFieldsWithReadXS(user, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"Name": "n", "Public": false}, goNames)
+	jsonNames, err := StructToMapFieldsWithReadXS(user, []string{"user"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "n", "public": false}, jsonNames)
+
+	user.Public = true
+	jsonNames, err = StructToMapFieldsWithReadXS(user, []string{"admin"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"secret": "s", "beta": true, "name": "n", "public": true}, jsonNames)
+
+	items, err := ProjectAll([]embeddingUser{*user}, []string{"user"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"beta": true, "name": "n", "public": true}, items[0])
+
+	yamlStr, err := StructToYAMLWithReadXS(user, []string{"user"})
+	assert.NoError(t, err)
+	assert.NotContains(t, yamlStr, "secret")
+
+	schema, err := JSONSchema(user, []string{"user"}, OpRead)
+	assert.NoError(t, err)
+	assert.NotContains(t, schema.Properties, "secret")
+	assert.Contains(t, schema.Properties, "beta")
+
+	assert.NoError(t, DecodeJSONWithWriteXS([]byte(`{"secret":"x","name":"m"}`), user, []string{"user"}))
+	assert.Equal(t, "s", user.Secret)
+	assert.Equal(t, "m", user.Name)
+}
+
+func TestStructToMapFieldsPromoted(t *testing.T) {
+	user := &planUser{planBase: planBase{ID: "u1"}, Name: "Ada"}
+	fieldMap, err := StructToMapFields(user, []string{"ID", "Name", "UpdatedBy", "Missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"ID": "u1", "Name": "Ada"}, fieldMap)
+
+	fieldMap, err = StructToMapFields(user, []string{"ID", "Name"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": "u1", "name": "Ada"}, fieldMap)
+}
+
+func TestFieldNamingCase(t *testing.T) {
+	cases := map[string][2]string{
+		"Name":        {"name", "name"},
+		"UserID":      {"user_id", "userID"},
+		"HTTPServer":  {"http_server", "httpServer"},
+		"ID":          {"id", "id"},
+		"Field1":      {"field1", "field1"},
+		"APIKeyValue": {"api_key_value", "apiKeyValue"},
+	}
+	for goName, expected := range cases {
+		assert.Equal(t, expected[0], toSnakeCase(goName), goName)
+		assert.Equal(t, expected[1], toCamelCase(goName), goName)
+	}
+}
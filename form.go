@@ -0,0 +1,307 @@
+package struccy
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxFormSliceIndex limits the slice indices accepted by BindForm, so a single key
+// like `items[100000000]` cannot allocate huge slices.
+const maxFormSliceIndex = 1000
+
+// BindForm binds url.Values (an `application/x-www-form-urlencoded` body or a query string) to the
+// target struct pointer. Keys follow the `form` tag; fields without a tag name are keyed by their Go
+// name unless another naming is selected with WithFieldNaming. Nested fields are addressed with dot or
+// bracket notation (`address.city`, `address[city]`, `items[0].name`, `filters[status]`), and a trailing
+// `[]` is ignored (`tags[]`).
+//
+// Values are converted like in SetField. Repeated keys fill slices element by element. A single value
+// becomes a single element, unless the field has the `split` option (`form:"ids,split"`), which splits
+// it at commas (`ids=1,2,3`). An empty value sets non-string fields to their zero value.
+//
+// Keys for fields the roles may not write (see `writexs` and `writeif`, checked at every struct field along
+// the path) are skipped, as are keys without a matching field; nil pointers, maps and slice elements along
+// the path are only allocated for keys that are bound. Fields that cannot be converted are reported together
+// as a *FieldErrors, along with the failures of the `validate` tags.
+func BindForm(target any, values url.Values, roles []string, opts ...ConvertOption) error {
+	targetValue := reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Ptr || targetValue.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	structValue := targetValue.Elem()
+	binder := &formBinder{roles: roles, naming: formFieldNaming(opts), fieldErrs: &FieldErrors{}, writable: map[formStruct]map[*fieldPlan]bool{}}
+	for _, key := range sortedMapKeys(values) {
+		segments, err := parsePath(strings.TrimSuffix(key, "[]"))
+		if err != nil {
+			binder.fieldErrs.Add(&FieldError{Path: key, JSONName: key, Cause: err})
+			continue
+		}
+		binder.bind(structValue, segments, values[key], false, &resolvedPath{})
+	}
+	validateStructValue(structValue, roles, "", "", binder.fieldErrs)
+	return binder.fieldErrs.errOrNil()
+}
+
+// ToValues converts the fields of the source struct pointer the roles may read (see `readxs`) into
+// url.Values, keyed like in BindForm. Nested structs and maps use dot notation (`address.city`),
+// slices of scalars become repeated keys and slices of structs are indexed (`items[0].name`).
+// Nil pointers and fields with the `omitempty` option and an empty value are left out.
+//
+// If the provided `src` is not a pointer to a struct, the function returns
+// an error (`ErrInvalidStructPointer`).
+func ToValues(src any, roles []string, opts ...ConvertOption) (url.Values, error) {
+	srcValue := reflect.ValueOf(src)
+	if srcValue.Kind() != reflect.Ptr || srcValue.Elem().Kind() != reflect.Struct {
+		return nil, ErrInvalidStructPointer
+	}
+	values := url.Values{}
+	addFormStruct(values, "", srcValue.Elem(), roles, formFieldNaming(opts))
+	return values, nil
+}
+
+// formFieldNaming returns the naming selected with WithFieldNaming, defaulting to form tag names with Go names as fallback.
+func formFieldNaming(opts []ConvertOption) FieldNaming {
+	options := newConvertOptions(opts)
+	if !options.namingSet {
+		return JSONFieldNames
+	}
+	return options.naming
+}
+
+type formBinder struct {
+	roles     []string
+	naming    FieldNaming
+	fieldErrs *FieldErrors
+	writable  map[formStruct]map[*fieldPlan]bool
+}
+
+// formStruct identifies a struct bound by a formBinder; a nested struct can share the address of its parent.
+type formStruct struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// writablePlans returns the plans of the struct the roles may write. The `writeif` conditions of a
+// struct are evaluated when it is first bound, before any of its fields is set.
+func (b *formBinder) writablePlans(current reflect.Value, plans []fieldPlan) map[*fieldPlan]bool {
+	if !current.CanAddr() {
+		return allowedPlans(current, plans, b.roles, OpWrite)
+	}
+	key := formStruct{addr: current.Addr().Pointer(), typ: current.Type()}
+	writable, ok := b.writable[key]
+	if !ok {
+		writable = allowedPlans(current, plans, b.roles, OpWrite)
+		b.writable[key] = writable
+	}
+	return writable
+}
+
+// bind sets the field at the path of segments below current to the values and reports whether it was set.
+// split selects whether a single value is split at commas for slice fields.
+func (b *formBinder) bind(current reflect.Value, segments []string, values []string, split bool, resolved *resolvedPath) bool {
+	if len(segments) == 0 {
+		if err := setFormValue(current, values, split); err != nil {
+			b.fieldErrs.Add(resolved.error(err, current.Type(), reflect.TypeOf(values), values))
+			return false
+		}
+		return true
+	}
+	if current.Kind() == reflect.Ptr {
+		if !current.IsNil() {
+			return b.bind(current.Elem(), segments, values, split, resolved)
+		}
+		// nil pointers are only allocated if the field below them is set
+		allocated := reflect.New(current.Type().Elem())
+		if !b.bind(allocated.Elem(), segments, values, split, resolved) {
+			return false
+		}
+		current.Set(allocated)
+		return true
+	}
+
+	segment := segments[0]
+	switch current.Kind() {
+	case reflect.Struct:
+		plans := taggedFieldPlans(current.Type(), tagKeyForm, b.naming)
+		plan := findFieldPlan(plans, segment, false)
+		if plan == nil || !b.writablePlans(current, plans)[plan] {
+			return false
+		}
+		resolved.push(plan.field.Name, jsonFieldName(plan.field))
+		_, tagOptions, _ := strings.Cut(plan.field.Tag.Get(tagKeyForm), ",")
+		return b.bind(fieldByIndexAlloc(current, plan.index), segments[1:], values, hasTagOption(tagOptions, "split"), resolved)
+	case reflect.Slice:
+		resolved.push(segment, segment)
+		index, err := strconv.Atoi(segment)
+		if err != nil {
+			b.fieldErrs.Add(resolved.error(ErrInvalidPath, nil, nil, segment))
+			return false
+		}
+		if index < 0 || index > maxFormSliceIndex {
+			b.fieldErrs.Add(resolved.error(ErrIndexOutOfRange, nil, nil, index))
+			return false
+		}
+		if index < current.Len() {
+			return b.bind(current.Index(index), segments[1:], values, false, resolved)
+		}
+		// the slice only grows if the new element is set
+		elem := reflect.New(current.Type().Elem()).Elem()
+		if !b.bind(elem, segments[1:], values, false, resolved) {
+			return false
+		}
+		current.Set(reflect.AppendSlice(current, reflect.MakeSlice(current.Type(), index+1-current.Len(), index+1-current.Len())))
+		current.Index(index).Set(elem)
+		return true
+	case reflect.Map:
+		resolved.push(segment, segment)
+		key, err := mapKey(segment, current.Type().Key())
+		if err != nil {
+			b.fieldErrs.Add(resolved.error(ErrInvalidPath, current.Type().Key(), nil, segment))
+			return false
+		}
+		// map entries are not addressable, so the entry is modified on a copy and stored again
+		entry := reflect.New(current.Type().Elem()).Elem()
+		if existing := current.MapIndex(key); existing.IsValid() {
+			entry.Set(existing)
+		}
+		if !b.bind(entry, segments[1:], values, false, resolved) {
+			return false
+		}
+		if current.IsNil() {
+			current.Set(reflect.MakeMap(current.Type()))
+		}
+		current.SetMapIndex(key, entry)
+		return true
+	default:
+		resolved.push(segment, segment)
+		b.fieldErrs.Add(resolved.error(ErrInvalidPath, nil, nil, segment))
+		return false
+	}
+}
+
+// setFormValue converts the values of a form key into the field. Slice fields get an element per value;
+// a single value is only split at commas if split is set.
+func setFormValue(field reflect.Value, values []string, split bool) error {
+	fieldType := field.Type()
+	elemType := indirectType(fieldType)
+	singleValue := len(values) == 1 && values[0] != ""
+	if (len(values) > 1 || (singleValue && !split)) && elemType.Kind() == reflect.Slice && elemType.Elem().Kind() != reflect.Uint8 && !isTextUnmarshaler(elemType) {
+		slice := reflect.MakeSlice(elemType, len(values), len(values))
+		for i, value := range values {
+			elem, err := parseStringValue(value, elemType.Elem())
+			if err != nil {
+				return err
+			}
+			slice.Index(i).Set(elem)
+		}
+		setFormParsed(field, slice)
+		return nil
+	}
+
+	value := ""
+	if len(values) > 0 {
+		value = values[0]
+	}
+	if value == "" && elemType.Kind() != reflect.String {
+		field.Set(reflect.Zero(fieldType))
+		return nil
+	}
+	parsed, err := parseStringValue(value, elemType)
+	if err != nil {
+		return err
+	}
+	setFormParsed(field, parsed)
+	return nil
+}
+
+// setFormParsed sets a parsed value, allocating pointers for pointer fields.
+func setFormParsed(field reflect.Value, parsed reflect.Value) {
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+	field.Set(parsed)
+}
+
+func isTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+func addFormStruct(values url.Values, prefix string, structValue reflect.Value, roles []string, naming FieldNaming) {
+	include := func(plan *fieldPlan) bool {
+		return plan.allowed(structValue, roles, OpRead)
+	}
+	eachIncludedField(structValue, tagKeyForm, naming, true, include, func(plan *fieldPlan, value reflect.Value) {
+		addFormValue(values, prefix+plan.name, value, roles, naming)
+	})
+}
+
+func addFormValue(values url.Values, key string, value reflect.Value, roles []string, naming FieldNaming) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		if !isOpaqueStruct(value.Type()) {
+			addFormStruct(values, key+".", value, roles, naming)
+			return
+		}
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		for i := 0; i < value.Len(); i++ {
+			elem := value.Index(i)
+			if hasNestedStructs(elem.Type()) {
+				addFormValue(values, fmt.Sprintf("%s[%d]", key, i), elem, roles, naming)
+			} else {
+				addFormValue(values, key, elem, roles, naming)
+			}
+		}
+		return
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			addFormValue(values, key+"."+mapKeyString(iter.Key()), iter.Value(), roles, naming)
+		}
+		return
+	}
+	values.Add(key, formatFormValue(value))
+}
+
+// formatFormValue formats a scalar value so that BindForm parses it back into the same value.
+func formatFormValue(value reflect.Value) string {
+	if value.CanInterface() {
+		switch v := value.Interface().(type) {
+		case time.Time:
+			return v.Format(time.RFC3339Nano)
+		case time.Duration:
+			return v.String()
+		case encoding.TextMarshaler:
+			if text, err := v.MarshalText(); err == nil {
+				return string(text)
+			}
+		}
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return string(value.Bytes())
+		}
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'g', -1, value.Type().Bits())
+	}
+	return fmt.Sprint(value.Interface())
+}
@@ -0,0 +1,161 @@
+package struccy
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type formAddress struct {
+	City string `form:"city" readxs:"*" writexs:"*"`
+	Zip  string `form:"zip" readxs:"*" writexs:"*"`
+}
+
+type formItem struct {
+	Name string `form:"name" readxs:"*" writexs:"*"`
+	Qty  int    `form:"qty" readxs:"*" writexs:"*"`
+}
+
+type formOrder struct {
+	Customer string            `form:"customer" readxs:"*" writexs:"*" validate:"required"`
+	Tags     []string          `form:"tags" readxs:"*" writexs:"*"`
+	IDs      []int             `form:"ids,split" readxs:"*" writexs:"*"`
+	Express  bool              `form:"express" readxs:"*" writexs:"*"`
+	Discount *float64          `form:"discount" readxs:"*" writexs:"*"`
+	Notes    string            `form:"notes,omitempty" readxs:"*" writexs:"*"`
+	Address  *formAddress      `form:"address" readxs:"*" writexs:"*"`
+	Items    []formItem        `form:"items" readxs:"*" writexs:"*"`
+	Filters  map[string]string `form:"filters" readxs:"*" writexs:"*"`
+	Deadline time.Time         `form:"deadline" readxs:"*" writexs:"*"`
+	Status   string            `form:"status" readxs:"*" writexs:"admin"`
+	Cost     int               `form:"cost" readxs:"admin" writexs:"admin"`
+	Created  time.Time         `form:"-"`
+}
+
+func TestBindForm(t *testing.T) {
+	values := url.Values{
+		"customer":       {"acme"},
+		"tags[]":         {"a", "b"},
+		"ids":            {"1,2,3"},
+		"express":        {"true"},
+		"discount":       {"0.5"},
+		"address.city":   {"Berlin"},
+		"address[zip]":   {"10115"},
+		"items[0].name":  {"pen"},
+		"items[1][name]": {"ink"},
+		"items[1].qty":   {"3"},
+		"filters[color]": {"red"},
+		"deadline":       {"2024-05-06T07:08:09Z"},
+		"status":         {"shipped"},
+		"csrf_token":     {"ignored"},
+	}
+	order := &formOrder{Status: "new"}
+	err := BindForm(order, values, []string{"customer"})
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", order.Customer)
+	assert.Equal(t, []string{"a", "b"}, order.Tags)
+	assert.Equal(t, []int{1, 2, 3}, order.IDs)
+	assert.True(t, order.Express)
+	assert.Equal(t, 0.5, *order.Discount)
+	assert.Equal(t, &formAddress{City: "Berlin", Zip: "10115"}, order.Address)
+	assert.Equal(t, []formItem{{Name: "pen"}, {Name: "ink", Qty: 3}}, order.Items)
+	assert.Equal(t, map[string]string{"color": "red"}, order.Filters)
+	assert.Equal(t, 2024, order.Deadline.Year())
+	assert.Equal(t, "new", order.Status) // not writable for customers
+
+	assert.NoError(t, BindForm(order, url.Values{"status": {"shipped"}, "discount": {""}}, []string{"admin"}))
+	assert.Equal(t, "shipped", order.Status)
+	assert.Nil(t, order.Discount)
+}
+
+func TestBindForm_Errors(t *testing.T) {
+	order := &formOrder{}
+	err := BindForm(order, url.Values{
+		"ids":             {"1", "x"},
+		"items[5000].qty": {"1"},
+		"express":         {"maybe"},
+	}, []string{"customer"})
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 4, fieldErrs.Len())
+	assert.Equal(t, "Express", fieldErrs.Errors[0].Path)
+	assert.True(t, errors.Is(fieldErrs.Errors[0], ErrInvalidFieldValue))
+	assert.Equal(t, "IDs", fieldErrs.Errors[1].Path)
+	assert.Equal(t, "Items.5000", fieldErrs.Errors[2].Path)
+	assert.True(t, errors.Is(fieldErrs.Errors[2], ErrIndexOutOfRange))
+	assert.True(t, errors.Is(fieldErrs.Errors[3], ErrValidationFailed)) // customer is required
+
+	assert.Equal(t, ErrInvalidStructPointer, BindForm(formOrder{}, nil, nil))
+}
+
+func TestBindForm_SingleValuesAndNilPointers(t *testing.T) {
+	// a single value is only split for fields with the `split` option
+	order := &formOrder{}
+	assert.NoError(t, BindForm(order, url.Values{"customer": {"acme"}, "tags": {"a,b"}, "ids": {"1,2"}}, []string{"customer"}))
+	assert.Equal(t, []string{"a,b"}, order.Tags)
+	assert.Equal(t, []int{1, 2}, order.IDs)
+
+	// nil pointers, maps and slice elements are not allocated for keys that are not bound
+	type restricted struct {
+		Secret string `form:"secret" writexs:"admin"`
+	}
+	type account struct {
+		Profile  *restricted           `form:"profile" writexs:"*"`
+		Profiles []restricted          `form:"profiles" writexs:"*"`
+		ByName   map[string]restricted `form:"by_name" writexs:"*"`
+	}
+	target := &account{}
+	assert.NoError(t, BindForm(target, url.Values{"profile.secret": {"s"}, "profiles[2].secret": {"s"}, "by_name[x].secret": {"s"}}, []string{"user"}))
+	assert.Equal(t, &account{}, target)
+	assert.NoError(t, BindForm(target, url.Values{"profile.secret": {"s"}, "profiles[1].secret": {"s"}, "by_name[x].secret": {"s"}}, []string{"admin"}))
+	assert.Equal(t, &account{
+		Profile:  &restricted{Secret: "s"},
+		Profiles: []restricted{{}, {Secret: "s"}},
+		ByName:   map[string]restricted{"x": {Secret: "s"}},
+	}, target)
+}
+
+func TestToValues(t *testing.T) {
+	discount := 0.25
+	order := &formOrder{
+		Customer: "acme",
+		Tags:     []string{"a", "b"},
+		IDs:      []int{7},
+		Discount: &discount,
+		Address:  &formAddress{City: "Berlin"},
+		Items:    []formItem{{Name: "pen", Qty: 2}},
+		Filters:  map[string]string{"color": "red"},
+		Deadline: time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		Cost:     99,
+		Created:  time.Now(),
+	}
+	values, err := ToValues(order, []string{"customer"})
+	assert.NoError(t, err)
+	assert.Equal(t, url.Values{
+		"customer":      {"acme"},
+		"tags":          {"a", "b"},
+		"ids":           {"7"},
+		"express":       {"false"},
+		"discount":      {"0.25"},
+		"address.city":  {"Berlin"},
+		"address.zip":   {""},
+		"items[0].name": {"pen"},
+		"items[0].qty":  {"2"},
+		"filters.color": {"red"},
+		"deadline":      {"2024-05-06T07:08:09Z"},
+		"status":        {""},
+	}, values)
+
+	// round trip
+	bound := &formOrder{}
+	assert.NoError(t, BindForm(bound, values, []string{"customer"}))
+	order.Cost = 0
+	order.Created = time.Time{}
+	assert.Equal(t, order, bound)
+
+	_, err = ToValues(*order, nil)
+	assert.Equal(t, ErrInvalidStructPointer, err)
+}
@@ -0,0 +1,107 @@
+package struccy
+
+import (
+	"errors"
+	"reflect"
+)
+
+// RoleMapper is implemented by the ToMapForRoles method struccy-gen generates (see cmd/struccy-gen).
+// StructToMapFieldsWithReadXS calls it instead of using reflection when no ConvertOption is given.
+type RoleMapper interface {
+	// ToMapForRoles returns the same map as StructToMapFieldsWithReadXS(structPtr, roles).
+	ToMapForRoles(roles []string) map[string]any
+}
+
+// RoleUpdater is implemented by the ApplyUpdate method struccy-gen generates (see cmd/struccy-gen).
+// ApplyMapUpdate calls it instead of using reflection to set the fields. The other merge and update
+// functions keep using reflection: MergeMapStringFieldsToStruct does not skip fields by their `writexs`
+// tags and reports the marker violations ApplyUpdate skips, and UpdateStructFields and SetField take no map.
+type RoleUpdater interface {
+	// ApplyUpdate sets the fields like ApplyMapUpdate, without validating the result.
+	ApplyUpdate(updateMap map[string]any, roles []string) error
+}
+
+// ApplyMapUpdate sets the fields of the target struct pointer from a map keyed by Go field names.
+// Only the fields the roles may write (see `writexs` and `writeif`) are set; other keys are skipped, as are keys
+// without a matching exported field. Values are converted like in SetField, except that zero values
+// are set as well and nil sets pointer, slice, map and interface fields to nil.
+//
+// If the target implements RoleUpdater (see cmd/struccy-gen), its ApplyUpdate method sets the fields.
+// All fields that cannot be set are returned together as a *FieldErrors, along with the failures of
+// the `validate` tags. The target is only changed if there are no errors.
+func ApplyMapUpdate(target any, updateMap map[string]any, roles []string) error {
+	targetValue := reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Ptr || targetValue.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	// the update works on a copy that replaces the target only if it succeeds
+	structValue := detachedCopy(targetValue.Elem())
+
+	fieldErrs := &FieldErrors{}
+	if updater, ok := structValue.Addr().Interface().(RoleUpdater); ok {
+		if err := updater.ApplyUpdate(updateMap, roles); err != nil && !errors.As(err, &fieldErrs) {
+			return err
+		}
+	} else {
+		plans := fieldPlans(structValue.Type(), GoFieldNames)
+		writable := allowedPlans(structValue, plans, roles, OpWrite)
+		for i := range plans {
+			plan := &plans[i]
+			value, sent := updateMap[plan.name]
+			if !sent || !writable[plan] {
+				continue
+			}
+			if err := assignFieldValue(structValue.FieldByIndex(plan.index), value); err != nil {
+				fieldErrs.Add(newFieldError(plan.field, err, value))
+			}
+		}
+	}
+	validateStructValue(structValue, roles, "", "", fieldErrs)
+	if err := fieldErrs.errOrNil(); err != nil {
+		return err
+	}
+	targetValue.Elem().Set(structValue)
+	return nil
+}
+
+// IsZeroValue reports whether the value is the zero value of its type. It is called by the code
+// struccy-gen generates for fields with the `@immutable` marker.
+func IsZeroValue[T any](value T) bool {
+	return reflect.ValueOf(&value).Elem().IsZero()
+}
+
+// SetFieldValue converts the value like ApplyMapUpdate and stores it in *field. Failures are added to
+// fieldErrs under the given Go and JSON field names. It is called by the code struccy-gen generates,
+// assigning values of the field's type without reflection.
+func SetFieldValue[T any](field *T, value any, path string, jsonName string, fieldErrs *FieldErrors) {
+	if typed, ok := value.(T); ok {
+		*field = typed
+		return
+	}
+	if err := assignFieldValue(reflect.ValueOf(field).Elem(), value); err != nil {
+		fieldErr := &FieldError{
+			Path:     path,
+			JSONName: jsonName,
+			Cause:    err,
+			Expected: reflect.TypeOf(field).Elem(),
+			Value:    value,
+		}
+		if value != nil {
+			fieldErr.Actual = reflect.TypeOf(value)
+		}
+		fieldErrs.Add(fieldErr)
+	}
+}
+
+// assignFieldValue sets the field to the value, converting it like setReflectField.
+func assignFieldValue(field reflect.Value, value any) error {
+	if value == nil {
+		switch field.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		return ErrFieldIsNil
+	}
+	return setReflectField(field, value)
+}
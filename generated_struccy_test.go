@@ -0,0 +1,55 @@
+// Code generated by struccy-gen. DO NOT EDIT.
+
+package struccy_test
+
+import "github.com/itsatony/struccy"
+
+// ToMapForRoles returns the fields of genUser the roles may read, like struccy.StructToMapFieldsWithReadXS.
+func (g *genUser) ToMapForRoles(roles []string) map[string]any {
+	fields := make(map[string]any, 7)
+	fields["ID"] = g.ID
+	fields["Name"] = g.Name
+	if struccy.IsFieldAccessAllowed(roles, "self,admin") {
+		fields["Age"] = g.Age
+	}
+	fields["Nickname"] = g.Nickname
+	fields["Tags"] = g.Tags
+	if struccy.IsFieldAccessAllowed(roles, "self") {
+		fields["Settings"] = g.Settings
+	}
+	if struccy.IsFieldAccessAllowed(roles, "admin") {
+		fields["LastLogin"] = g.LastLogin
+	}
+	return fields
+}
+
+// ApplyUpdate sets the fields of genUser the roles may write from the map keyed by Go field names,
+// like struccy.ApplyMapUpdate without the validation.
+func (g *genUser) ApplyUpdate(updateMap map[string]any, roles []string) error {
+	fieldErrs := &struccy.FieldErrors{}
+	if value, ok := updateMap["ID"]; ok && struccy.IsWriteAccessAllowed(roles, "admin") {
+		struccy.SetFieldValue(&g.ID, value, "ID", "id", fieldErrs)
+	}
+	if value, ok := updateMap["Name"]; ok && struccy.IsWriteAccessAllowed(roles, "self,admin") {
+		struccy.SetFieldValue(&g.Name, value, "Name", "name", fieldErrs)
+	}
+	if value, ok := updateMap["Age"]; ok && struccy.IsWriteAccessAllowed(roles, "self") {
+		struccy.SetFieldValue(&g.Age, value, "Age", "age", fieldErrs)
+	}
+	if value, ok := updateMap["Nickname"]; ok && struccy.IsWriteAccessAllowed(roles, "self") {
+		struccy.SetFieldValue(&g.Nickname, value, "Nickname", "nickname", fieldErrs)
+	}
+	if value, ok := updateMap["Tags"]; ok && struccy.IsWriteAccessAllowed(roles, "self") {
+		struccy.SetFieldValue(&g.Tags, value, "Tags", "tags", fieldErrs)
+	}
+	if value, ok := updateMap["Settings"]; ok && struccy.IsWriteAccessAllowed(roles, "self") {
+		struccy.SetFieldValue(&g.Settings, value, "Settings", "settings", fieldErrs)
+	}
+	if value, ok := updateMap["LastLogin"]; ok && struccy.IsWriteAccessAllowed(roles, "!self") {
+		struccy.SetFieldValue(&g.LastLogin, value, "LastLogin", "lastLogin", fieldErrs)
+	}
+	if fieldErrs.Len() > 0 {
+		return fieldErrs
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package struccy_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/itsatony/struccy"
+	"github.com/stretchr/testify/assert"
+)
+
+//go:generate go run ./cmd/struccy-gen -type genUser
+
+// genUser has accessors generated by struccy-gen (generated_struccy_test.go).
+type genUser struct {
+	ID        string            `json:"id" readxs:"*" writexs:"admin"`
+	Name      string            `json:"name" readxs:"*" writexs:"self,admin" validate:"required"`
+	Age       int               `json:"age" readxs:"self,admin" writexs:"self"`
+	Nickname  *string           `json:"nickname" readxs:"*" writexs:"self"`
+	Tags      []string          `json:"tags" readxs:"*" writexs:"self"`
+	Settings  map[string]string `json:"settings" readxs:"self" writexs:"self"`
+	LastLogin time.Time         `json:"lastLogin" readxs:"admin" writexs:"!self"`
+	password  string
+}
+
+// plainUser is genUser without generated accessors.
+type plainUser struct {
+	ID        string            `json:"id" readxs:"*" writexs:"admin"`
+	Name      string            `json:"name" readxs:"*" writexs:"self,admin" validate:"required"`
+	Age       int               `json:"age" readxs:"self,admin" writexs:"self"`
+	Nickname  *string           `json:"nickname" readxs:"*" writexs:"self"`
+	Tags      []string          `json:"tags" readxs:"*" writexs:"self"`
+	Settings  map[string]string `json:"settings" readxs:"self" writexs:"self"`
+	LastLogin time.Time         `json:"lastLogin" readxs:"admin" writexs:"!self"`
+	password  string
+}
+
+func TestGeneratedAccessors(t *testing.T) {
+	var _ struccy.RoleMapper = &genUser{}
+	var _ struccy.RoleUpdater = &genUser{}
+
+	nick := "ali"
+	generated := &genUser{ID: "u-1", Name: "Alice", Age: 30, Nickname: &nick, Tags: []string{"a"}, LastLogin: time.Unix(0, 0), password: "x"}
+	plain := plainUser(*generated)
+	for _, roles := range [][]string{nil, {"self"}, {"admin"}} {
+		expected, err := struccy.StructToMapFieldsWithReadXS(&plain, roles)
+		assert.NoError(t, err)
+		actual, err := struccy.StructToMapFieldsWithReadXS(generated, roles)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+		assert.Equal(t, expected, generated.ToMapForRoles(roles))
+	}
+
+	updates := []map[string]any{
+		{"ID": "hacked", "Name": "Bob", "Age": int8(31), "Nickname": "bobby", "Tags": nil, "Settings": map[string]string{"theme": "dark"}},
+		{"Name": "", "Age": "old", "LastLogin": time.Unix(10, 0), "password": "y", "Unknown": 1},
+		{"LastLogin": "2024-05-01T12:00:00Z", "Nickname": nil, "Age": nil},
+	}
+	for _, roles := range [][]string{{"self"}, {"admin"}} {
+		for _, update := range updates {
+			generatedCopy, plainCopy := *generated, plain
+			generatedErr := struccy.ApplyMapUpdate(&generatedCopy, update, roles)
+			plainErr := struccy.ApplyMapUpdate(&plainCopy, update, roles)
+			assert.Equal(t, plainErr, generatedErr)
+			assert.Equal(t, plainCopy, plainUser(generatedCopy))
+		}
+	}
+}
+
+func TestApplyMapUpdate(t *testing.T) {
+	user := &plainUser{ID: "u-1", Name: "Alice", Tags: []string{"a"}}
+	err := struccy.ApplyMapUpdate(user, map[string]any{"ID": "hacked", "Name": "Bob", "Age": "31", "Tags": nil}, []string{"self"})
+	assert.NoError(t, err)
+	assert.Equal(t, &plainUser{ID: "u-1", Name: "Bob", Age: 31}, user)
+
+	err = struccy.ApplyMapUpdate(user, map[string]any{"ID": []int{1}, "Name": "", "LastLogin": nil}, []string{"admin"})
+	var fieldErrs *struccy.FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 3, fieldErrs.Len())
+	assert.Equal(t, "ID", fieldErrs.Errors[0].Path)
+	assert.ErrorIs(t, fieldErrs.Errors[0], struccy.ErrInvalidFieldType)
+	assert.Equal(t, "LastLogin", fieldErrs.Errors[1].Path)
+	assert.ErrorIs(t, fieldErrs.Errors[1], struccy.ErrFieldIsNil)
+	assert.ErrorIs(t, fieldErrs.Errors[2], struccy.ErrValidationFailed)
+	// a failed update leaves the target unchanged
+	assert.Equal(t, &plainUser{ID: "u-1", Name: "Bob", Age: 31}, user)
+
+	err = struccy.ApplyMapUpdate(*user, nil, nil)
+	assert.Equal(t, struccy.ErrInvalidStructPointer, err)
+}
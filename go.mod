@@ -2,11 +2,17 @@ module github.com/itsatony/struccy
 
 go 1.22.0
 
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/stretchr/testify v1.9.0
+	golang.org/x/tools v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/ramya-rao-a/go-outline v0.0.0-20210608161538-9736a4bde949 // indirect
-	github.com/stretchr/testify v1.9.0 // indirect
-	golang.org/x/tools v0.1.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/mod v0.23.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
 )
@@ -0,0 +1,113 @@
+// Package httpx connects struccy to net/http: Middleware stores the roles of a request in its
+// context, WriteJSON projects responses with `readxs` for those roles and BindJSON decodes request
+// bodies with `writexs` for them.
+//
+//	mux.Handle("/users/", httpx.Middleware(httpx.HeaderRoles("X-Roles"))(handler))
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		user := &User{}
+//		if err := httpx.BindJSON(r, user); err != nil {
+//			http.Error(w, err.Error(), http.StatusBadRequest)
+//			return
+//		}
+//		_ = httpx.WriteJSON(w, r, user)
+//	}
+//
+// Authentication is not part of this package: the RoleFunc reads roles that were verified upstream,
+// e.g. by a proxy setting a header or a JWT middleware storing the claims in the context.
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/itsatony/struccy"
+)
+
+// ErrEmptyBody is returned by BindJSON for requests without a body.
+var ErrEmptyBody = errors.New("empty request body")
+
+// RoleFunc returns the roles of a request. An error rejects the request.
+type RoleFunc func(r *http.Request) ([]string, error)
+
+type rolesKey struct{}
+
+// ContextWithRoles returns a copy of the context carrying the roles.
+func ContextWithRoles(ctx context.Context, roles []string) context.Context {
+	return context.WithValue(ctx, rolesKey{}, roles)
+}
+
+// RolesFromContext returns the roles stored by Middleware or ContextWithRoles, or nil.
+func RolesFromContext(ctx context.Context) []string {
+	roles, _ := ctx.Value(rolesKey{}).([]string)
+	return roles
+}
+
+// Middleware stores the roles returned by the RoleFunc in the request context. If the RoleFunc fails,
+// the request is answered with 401 Unauthorized and not passed on.
+func Middleware(roleFunc RoleFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			roles, err := roleFunc(r)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithRoles(r.Context(), roles)))
+		})
+	}
+}
+
+// HeaderRoles returns a RoleFunc reading the roles from a comma separated header, e.g. `X-Roles: admin,self`.
+// Requests without the header have no roles.
+func HeaderRoles(header string) RoleFunc {
+	return func(r *http.Request) ([]string, error) {
+		var roles []string
+		for _, value := range r.Header.Values(header) {
+			for _, role := range strings.Split(value, ",") {
+				if role = strings.TrimSpace(role); role != "" {
+					roles = append(roles, role)
+				}
+			}
+		}
+		return roles, nil
+	}
+}
+
+// WriteJSON writes v as JSON, projected with struccy.ProjectWithReadXS for the roles of the request:
+// structs, also nested in pointers, slices, maps and other structs, only contain the fields the roles
+// may read. The Content-Type is set to application/json unless already set. If v cannot be encoded,
+// nothing is written and the error is returned.
+func WriteJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	data, err := json.Marshal(struccy.ProjectWithReadXS(v, RolesFromContext(r.Context())))
+	if err != nil {
+		return err
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// BindJSON decodes the JSON object in the request body into the target struct pointer with
+// struccy.DecodeJSONWithWriteXS: only the fields the roles of the request may write are set.
+// Field and validation failures are returned as a *struccy.FieldErrors. Use http.MaxBytesReader
+// to limit the body size.
+func BindJSON(r *http.Request, target any) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return ErrEmptyBody
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return ErrEmptyBody
+	}
+	return struccy.DecodeJSONWithWriteXS(data, target, RolesFromContext(r.Context()))
+}
@@ -0,0 +1,125 @@
+package httpx
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/itsatony/struccy"
+	"github.com/stretchr/testify/assert"
+)
+
+type httpAddress struct {
+	City string `json:"city" readxs:"*" writexs:"*"`
+	Note string `json:"note" readxs:"admin" writexs:"admin"`
+}
+
+type httpUser struct {
+	ID      string       `json:"id" readxs:"*" writexs:"admin"`
+	Name    string       `json:"name" readxs:"*" writexs:"self,admin" validate:"required"`
+	Email   string       `json:"email" readxs:"self,admin" writexs:"self"`
+	Address *httpAddress `json:"address" readxs:"*" writexs:"self"`
+}
+
+func newServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		users := []httpUser{
+			{ID: "1", Name: "Alice", Email: "alice@example.com", Address: &httpAddress{City: "Oslo", Note: "vip"}},
+			{ID: "2", Name: "Bob", Email: "bob@example.com"},
+		}
+		_ = WriteJSON(w, r, map[string]any{"users": users, "total": len(users)})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		user := &httpUser{ID: "1", Name: "Alice"}
+		if err := BindJSON(r, user); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_ = WriteJSON(w, r, user)
+	})
+	rejectGuests := func(r *http.Request) ([]string, error) {
+		roles, _ := HeaderRoles("X-Roles")(r)
+		if len(roles) == 0 {
+			return nil, errors.New("no roles")
+		}
+		return roles, nil
+	}
+	return httptest.NewServer(Middleware(rejectGuests)(mux))
+}
+
+func do(t *testing.T, server *httptest.Server, method string, path string, roles string, body string) (int, string) {
+	t.Helper()
+	request, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	assert.NoError(t, err)
+	if roles != "" {
+		request.Header.Set("X-Roles", roles)
+	}
+	response, err := server.Client().Do(request)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	return response.StatusCode, string(data)
+}
+
+func TestWriteJSON(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	status, body := do(t, server, http.MethodGet, "/users", "guest", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"total": 2, "users": [
+		{"id": "1", "name": "Alice", "address": {"city": "Oslo"}},
+		{"id": "2", "name": "Bob", "address": null}
+	]}`, body)
+
+	_, body = do(t, server, http.MethodGet, "/users", "self, admin", "")
+	assert.JSONEq(t, `{"total": 2, "users": [
+		{"id": "1", "name": "Alice", "email": "alice@example.com", "address": {"city": "Oslo", "note": "vip"}},
+		{"id": "2", "name": "Bob", "email": "bob@example.com", "address": null}
+	]}`, body)
+
+	status, _ = do(t, server, http.MethodGet, "/users", "", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	recorder := httptest.NewRecorder()
+	assert.NoError(t, WriteJSON(recorder, httptest.NewRequest(http.MethodGet, "/", nil), []string{"a"}))
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "[\"a\"]\n", recorder.Body.String())
+	assert.Error(t, WriteJSON(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), func() {}))
+}
+
+func TestBindJSON(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	status, body := do(t, server, http.MethodPost, "/user", "self", `{"id": "2", "name": "Al", "email": "al@example.com", "address": {"city": "Rome"}}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"id": "1", "name": "Al", "email": "al@example.com", "address": {"city": "Rome"}}`, body)
+
+	status, body = do(t, server, http.MethodPost, "/user", "self", `{"name": ""}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "validation failed")
+
+	request := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"name": 1}`))
+	err := BindJSON(request.WithContext(ContextWithRoles(request.Context(), []string{"admin"})), &httpUser{})
+	var fieldErrs *struccy.FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.True(t, errors.Is(err, struccy.ErrInvalidFieldValue))
+
+	assert.Equal(t, ErrEmptyBody, BindJSON(httptest.NewRequest(http.MethodPost, "/user", nil), &httpUser{}))
+}
+
+func TestHeaderRoles(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Add("X-Roles", "admin, self,")
+	request.Header.Add("X-Roles", "guest")
+	roles, err := HeaderRoles("X-Roles")(request)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin", "self", "guest"}, roles)
+	assert.Nil(t, RolesFromContext(request.Context()))
+}
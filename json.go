@@ -0,0 +1,25 @@
+package struccy
+
+import "encoding/json"
+
+// DecodeJSONWithWriteXS decodes a JSON object into the target struct pointer, setting only the
+// fields with write access allowed based on the provided xsList; other keys are ignored, as are
+// keys without a matching field. Keys are matched like encoding/json does (json tag names, embedded
+// struct promotion, case-insensitive fallback), unless another naming is selected with WithFieldNaming.
+// Nested values are decoded into the existing values, as encoding/json does.
+//
+// Fields that cannot be decoded do not abort the decoding; they are returned together as a
+// *FieldErrors with ErrInvalidFieldValue as cause, along with the failures of the `validate` tags.
+func DecodeJSONWithWriteXS(data []byte, targetStruct any, xsList []string, opts ...ConvertOption) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	naming := JSONFieldNames
+	if options := newConvertOptions(opts); options.namingSet {
+		naming = options.naming
+	}
+	return decodeFieldsWithWriteXS(targetStruct, xsList, tagKeyJSON, naming, true, sortedMapKeys(doc), func(key string, target any) error {
+		return json.Unmarshal(doc[key], target)
+	})
+}
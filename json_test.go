@@ -0,0 +1,40 @@
+package struccy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeJSONWithWriteXS(t *testing.T) {
+	data := []byte(`{"name": "Joe", "EMAIL": "joe@example.com", "address": {"city": "Oslo"}, "created_at": "2020-01-01T00:00:00Z", "unknown": 1}`)
+	user := newMaskUser()
+	err := DecodeJSONWithWriteXS(data, user, []string{"self"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Joe", user.Name)
+	assert.Equal(t, "joe@example.com", user.Email)
+	// nested values are decoded into the existing ones, without checking their writexs tags
+	assert.Equal(t, "Oslo", user.Address.City)
+	assert.Equal(t, 13.4, user.Address.Geo.Lng)
+	assert.Equal(t, 2024, user.CreatedAt.Year())
+
+	err = DecodeJSONWithWriteXS([]byte(`{"Name": "Ann", "Email": "ann@example.com"}`), user, []string{"user"}, WithFieldNaming(GoFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, "Ann", user.Name)
+	assert.Equal(t, "joe@example.com", user.Email)
+}
+
+func TestDecodeJSONWithWriteXS_Errors(t *testing.T) {
+	user := newMaskUser()
+	err := DecodeJSONWithWriteXS([]byte(`{"name": 1, "labels": {"team": "ops"}}`), user, []string{"user"})
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 1, fieldErrs.Len())
+	assert.Equal(t, "name", fieldErrs.Errors[0].JSONName)
+	assert.True(t, errors.Is(err, ErrInvalidFieldValue))
+	assert.Equal(t, map[string]string{"team": "ops", "tier": "gold"}, user.Labels)
+
+	assert.Error(t, DecodeJSONWithWriteXS([]byte(`[1]`), user, nil))
+	assert.Equal(t, ErrInvalidStructPointer, DecodeJSONWithWriteXS([]byte(`{}`), *user, nil))
+}
@@ -0,0 +1,178 @@
+package struccy
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrAccessRulesRequired is returned by FilterMapFieldsByRole, as a plain map carries no access tags.
+var ErrAccessRulesRequired = errors.New("a map has no access tags, use FilterMapByRules with AccessRules")
+
+// AccessRule holds the access rules for a single map key, in the same syntax as the
+// `readxs` and `writexs` struct tags. Fields optionally holds the rules for the keys of a
+// nested map (or of the maps inside a nested []any); if it is nil, nested values are kept as a whole.
+type AccessRule struct {
+	ReadXS  string
+	WriteXS string
+	Fields  AccessRules
+}
+
+// AccessRules maps keys to their AccessRule. The key "*" is used for all keys without an own rule.
+// Keys without a rule (and without a "*" rule) are denied.
+type AccessRules map[string]AccessRule
+
+// rule returns the rule for the key, falling back to the "*" rule.
+func (rules AccessRules) rule(key string) (AccessRule, bool) {
+	if rule, ok := rules[key]; ok {
+		return rule, true
+	}
+	rule, ok := rules["*"]
+	return rule, ok
+}
+
+// AccessRulesFromStruct builds AccessRules from the `readxs`/`writexs` tags of a reference struct
+// pointer, keyed by JSON names (or Go names if useJsonFieldNames is false). Fields of embedded structs
+// are promoted like encoding/json does; if the embedded struct has its own access tag, its promoted fields
+// get that tag when their own is "*" and are denied when both tags differ otherwise, as a rule cannot
+// combine two tags. Nested structs, pointers to structs and slices of structs get nested Fields; maps of
+// structs get a "*" rule with the struct's rules, so dynamic keys are filtered by the same schema.
+// The `readif`/`writeif` conditions depend on the struct's values and are not part of the rules.
+func AccessRulesFromStruct(referenceStructPointer any, useJsonFieldNames bool) (AccessRules, error) {
+	refVal := reflect.ValueOf(referenceStructPointer)
+	if refVal.Kind() != reflect.Ptr || refVal.Elem().Kind() != reflect.Struct {
+		return nil, ErrMustBeStructPointer
+	}
+	return accessRulesFromType(refVal.Elem().Type(), useJsonFieldNames, make(map[reflect.Type]AccessRules)), nil
+}
+
+func accessRulesFromType(structType reflect.Type, useJsonFieldNames bool, built map[reflect.Type]AccessRules) AccessRules {
+	if rules, ok := built[structType]; ok {
+		// recursive types share the same rules
+		return rules
+	}
+	rules := make(AccessRules)
+	built[structType] = rules
+	plans := fieldPlans(structType, JSONFieldNames)
+	for i := range plans {
+		plan := &plans[i]
+		key := plan.name
+		if !useJsonFieldNames {
+			key = plan.field.Name
+		}
+		rule := AccessRule{
+			ReadXS:  plan.readXS,
+			WriteXS: plan.writeXS,
+			Fields:  nestedAccessRules(plan.field.Type, useJsonFieldNames, built),
+		}
+		for _, parent := range plan.parents {
+			if xs, ok := parent.field.Tag.Lookup(tagNameReadXS); ok {
+				rule.ReadXS = restrictedXS(rule.ReadXS, xs)
+			}
+			if xs, ok := parent.field.Tag.Lookup(tagNameWriteXS); ok {
+				rule.WriteXS = restrictedXS(rule.WriteXS, xs)
+			}
+		}
+		rules[key] = rule
+	}
+	return rules
+}
+
+// restrictedXS returns an access tag granting at most what both tags grant: the other tag if one is "*",
+// otherwise the tag if both are equal and an empty tag, which grants nothing, if they differ.
+func restrictedXS(xs string, parentXS string) string {
+	switch {
+	case parentXS == "*" || xs == parentXS:
+		return xs
+	case xs == "*":
+		return parentXS
+	}
+	return ""
+}
+
+// nestedAccessRules returns the rules for the keys of values of the given type, or nil if it has no struct fields.
+func nestedAccessRules(typ reflect.Type, useJsonFieldNames bool, built map[reflect.Type]AccessRules) AccessRules {
+	typ = indirectType(typ)
+	switch typ.Kind() {
+	case reflect.Struct:
+		if isOpaqueStruct(typ) {
+			return nil
+		}
+		return accessRulesFromType(typ, useJsonFieldNames, built)
+	case reflect.Slice, reflect.Array:
+		return nestedAccessRules(typ.Elem(), useJsonFieldNames, built)
+	case reflect.Map:
+		elemRules := nestedAccessRules(typ.Elem(), useJsonFieldNames, built)
+		if elemRules == nil {
+			return nil
+		}
+		return AccessRules{"*": AccessRule{ReadXS: "*", WriteXS: "*", Fields: elemRules}}
+	}
+	return nil
+}
+
+// FilterMapByRules filters a dynamic map (e.g. a decoded JSON document) by role, without a Go struct.
+// Each key is checked against its AccessRule for the operation: `ReadXS` for OpRead, `WriteXS` for OpWrite.
+// Keys without a rule are dropped. Nested maps and maps inside []any are filtered recursively with the
+// rule's Fields. Use AccessRulesFromStruct to derive the rules from a reference struct.
+//
+// The source map is not modified.
+func FilterMapByRules(source map[string]any, rules AccessRules, xsList []string, op Operation) (filtered map[string]any, err error) {
+	if source == nil || rules == nil {
+		return nil, ErrNilArguments
+	}
+	return filterMapByRules(source, rules, xsList, op), nil
+}
+
+// FilterMapFieldsByRole was meant to return the keys of the source map the roles in xsList may write.
+// A plain map has no `writexs` tags to decide that, so it always returns ErrAccessRulesRequired
+// (or ErrNilArguments for a nil map).
+//
+// Deprecated: use FilterMapByRules, which takes the access rules of the keys, e.g. from AccessRulesFromStruct.
+func FilterMapFieldsByRole(source map[string]any, xsList []string) (filtered map[string]any, err error) {
+	if source == nil {
+		return nil, ErrNilArguments
+	}
+	return nil, ErrAccessRulesRequired
+}
+
+func filterMapByRules(source map[string]any, rules AccessRules, xsList []string, op Operation) map[string]any {
+	filtered := make(map[string]any)
+	for key, value := range source {
+		rule, ok := rules.rule(key)
+		if !ok {
+			continue
+		}
+		xs := rule.ReadXS
+		if op == OpWrite {
+			xs = rule.WriteXS
+		}
+		if !IsFieldAccessAllowed(xsList, xs) {
+			continue
+		}
+		filtered[key] = filterValueByRules(value, rule.Fields, xsList, op)
+	}
+	return filtered
+}
+
+func filterValueByRules(value any, rules AccessRules, xsList []string, op Operation) any {
+	if rules == nil {
+		return value
+	}
+	switch typed := value.(type) {
+	case map[string]any:
+		return filterMapByRules(typed, rules, xsList, op)
+	case []any:
+		filtered := make([]any, len(typed))
+		for i, elem := range typed {
+			filtered[i] = filterValueByRules(elem, rules, xsList, op)
+		}
+		return filtered
+	case []map[string]any:
+		filtered := make([]map[string]any, len(typed))
+		for i, elem := range typed {
+			filtered[i] = filterMapByRules(elem, rules, xsList, op)
+		}
+		return filtered
+	}
+	return value
+}
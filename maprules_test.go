@@ -0,0 +1,160 @@
+package struccy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterMapByRules(t *testing.T) {
+	rules := AccessRules{
+		"name":   {ReadXS: "*", WriteXS: "admin"},
+		"secret": {ReadXS: "admin", WriteXS: "admin"},
+		"flags": {ReadXS: "*", WriteXS: "admin", Fields: AccessRules{
+			"beta": {ReadXS: "admin"},
+			"*":    {ReadXS: "*"},
+		}},
+		"rollouts": {ReadXS: "*", Fields: AccessRules{
+			"id":      {ReadXS: "*"},
+			"percent": {ReadXS: "!guest"},
+		}},
+		"meta": {ReadXS: "*"},
+	}
+	payload := map[string]any{
+		"name":    "checkout",
+		"secret":  "s3cr3t",
+		"unknown": 1,
+		"flags":   map[string]any{"beta": true, "dark_mode": false},
+		"rollouts": []any{
+			map[string]any{"id": "a", "percent": 10},
+			"not-a-map",
+		},
+		"meta": map[string]any{"owner": "team-a"},
+	}
+
+	filtered, err := FilterMapByRules(payload, rules, []string{"user"}, OpRead)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"name":  "checkout",
+		"flags": map[string]any{"dark_mode": false},
+		"rollouts": []any{
+			map[string]any{"id": "a", "percent": 10},
+			"not-a-map",
+		},
+		"meta": map[string]any{"owner": "team-a"},
+	}, filtered)
+
+	filtered, err = FilterMapByRules(payload, rules, []string{"guest"}, OpRead)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{map[string]any{"id": "a"}, "not-a-map"}, filtered["rollouts"])
+
+	filtered, err = FilterMapByRules(payload, rules, []string{"user"}, OpWrite)
+	assert.NoError(t, err)
+	assert.Empty(t, filtered)
+
+	filtered, err = FilterMapByRules(payload, rules, []string{"admin"}, OpWrite)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"flags", "name", "secret"}, sortedMapKeys(filtered))
+
+	// the source is left untouched
+	assert.Equal(t, true, payload["flags"].(map[string]any)["beta"])
+
+	_, err = FilterMapByRules(nil, rules, nil, OpRead)
+	assert.Equal(t, ErrNilArguments, err)
+}
+
+func TestFilterMapFieldsByRole(t *testing.T) {
+	// a map has no access tags, so the rules must be given to FilterMapByRules
+	filtered, err := FilterMapFieldsByRole(map[string]any{"name": "checkout"}, []string{"admin"})
+	assert.Nil(t, filtered)
+	assert.Equal(t, ErrAccessRulesRequired, err)
+
+	_, err = FilterMapFieldsByRole(nil, []string{"admin"})
+	assert.Equal(t, ErrNilArguments, err)
+}
+
+type ruleAddress struct {
+	City   string `json:"city" readxs:"*"`
+	Street string `json:"street" readxs:"admin"`
+}
+
+type ruleUser struct {
+	Name      string                 `json:"name" readxs:"*" writexs:"*"`
+	Email     string                 `json:"email" readxs:"admin,self" writexs:"self"`
+	Hidden    string                 `json:"-" readxs:"*"`
+	Address   *ruleAddress           `json:"address" readxs:"*"`
+	Addresses []ruleAddress          `json:"addresses" readxs:"*"`
+	ByLabel   map[string]ruleAddress `json:"by_label" readxs:"*"`
+	Manager   *ruleUser              `json:"manager" readxs:"*"`
+}
+
+func TestAccessRulesFromStruct(t *testing.T) {
+	rules, err := AccessRulesFromStruct(&ruleUser{}, true)
+	assert.NoError(t, err)
+	assert.NotContains(t, rules, "Hidden")
+	assert.Equal(t, "admin,self", rules["email"].ReadXS)
+	assert.Equal(t, "self", rules["email"].WriteXS)
+
+	payload := map[string]any{
+		"name":    "Ada",
+		"email":   "ada@example.com",
+		"address": map[string]any{"city": "Berlin", "street": "Main St"},
+		"addresses": []any{
+			map[string]any{"city": "Paris", "street": "Rue 1"},
+		},
+		"by_label": map[string]any{
+			"home": map[string]any{"city": "Rome", "street": "Via 2"},
+		},
+		"manager": map[string]any{"name": "Grace", "email": "grace@example.com"},
+	}
+	filtered, err := FilterMapByRules(payload, rules, []string{"user"}, OpRead)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"name":      "Ada",
+		"address":   map[string]any{"city": "Berlin"},
+		"addresses": []any{map[string]any{"city": "Paris"}},
+		"by_label":  map[string]any{"home": map[string]any{"city": "Rome"}},
+		"manager":   map[string]any{"name": "Grace"},
+	}, filtered)
+
+	goRules, err := AccessRulesFromStruct(&ruleUser{}, false)
+	assert.NoError(t, err)
+	assert.Contains(t, goRules, "Email")
+	assert.Contains(t, goRules["Address"].Fields, "City")
+
+	_, err = AccessRulesFromStruct(ruleUser{}, true)
+	assert.Equal(t, ErrMustBeStructPointer, err)
+}
+
+func TestAccessRulesFromStruct_EmbeddedStructs(t *testing.T) {
+	type ruleIdentity struct {
+		ID    string `json:"id" readxs:"*" writexs:"*"`
+		Owner string `json:"owner" readxs:"*" writexs:"self"`
+	}
+	type ruleAudit struct {
+		CreatedBy string `json:"created_by" readxs:"*"`
+	}
+	type ruleDocument struct {
+		ruleIdentity
+		ruleAudit `readxs:"admin"`
+		Title     string `json:"title" readxs:"*"`
+	}
+
+	// the fields of embedded structs are promoted, restricted by the embedded struct's tag
+	rules, err := AccessRulesFromStruct(&ruleDocument{}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, AccessRules{
+		"id":         {ReadXS: "*", WriteXS: "*"},
+		"owner":      {ReadXS: "*", WriteXS: "self"},
+		"created_by": {ReadXS: "admin"},
+		"title":      {ReadXS: "*"},
+	}, rules)
+
+	goRules, err := AccessRulesFromStruct(&ruleDocument{}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CreatedBy", "ID", "Owner", "Title"}, sortedMapKeys(goRules))
+
+	filtered, err := FilterMapByRules(map[string]any{"id": "1", "created_by": "eve", "title": "t"}, rules, []string{"user"}, OpRead)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": "1", "title": "t"}, filtered)
+}
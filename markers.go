@@ -0,0 +1,177 @@
+package struccy
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Markers in `writexs` tags, next to or instead of the roles.
+const (
+	markerImmutable = "@immutable" // writable by the roles only while the field is zero
+	markerSystem    = "@system"    // only writable through SetSystemField
+	markerReadOnly  = "@readonly"  // never writable
+)
+
+var (
+	ErrFieldImmutable     = errors.New("field is immutable")
+	ErrFieldSystemManaged = errors.New("field is system-managed")
+	ErrFieldReadOnly      = errors.New("field is read-only")
+)
+
+// writeMarkers are the markers of a `writexs` tag value and the remaining role entries.
+type writeMarkers struct {
+	roles     string
+	immutable bool
+	system    bool
+	readOnly  bool
+}
+
+// parsedWriteMarkers caches the writeMarkers per tag value.
+var parsedWriteMarkers sync.Map // map[string]writeMarkers
+
+// parseWriteMarkers splits the markers off a `writexs` tag value. A tag value holding nothing but
+// markers grants no roles, so `@immutable` alone is only writable through SetSystemField; use
+// `*,@immutable` to let all roles set the field once. Unknown markers are kept as role entries,
+// which match no role.
+func parseWriteMarkers(tagValue string) writeMarkers {
+	if !strings.Contains(tagValue, "@") {
+		return writeMarkers{roles: tagValue}
+	}
+	if cached, ok := parsedWriteMarkers.Load(tagValue); ok {
+		return cached.(writeMarkers)
+	}
+	markers := writeMarkers{}
+	roles := make([]string, 0)
+	for _, entry := range strings.Split(tagValue, ",") {
+		switch entry {
+		case markerImmutable:
+			markers.immutable = true
+		case markerSystem:
+			markers.system = true
+		case markerReadOnly:
+			markers.readOnly = true
+		default:
+			roles = append(roles, entry)
+		}
+	}
+	markers.roles = strings.Join(roles, ",")
+	parsedWriteMarkers.Store(tagValue, markers)
+	return markers
+}
+
+// IsWriteAccessAllowed is IsFieldAccessAllowed for `writexs` tag values: the markers are split off
+// before the roles are checked, and fields with a `@system` or `@readonly` marker are not writable by
+// any role. The `@immutable` marker depends on the field value, which is checked by the write functions.
+func IsWriteAccessAllowed(roles []string, tagValue string) bool {
+	markers := parseWriteMarkers(tagValue)
+	return !markers.system && !markers.readOnly && IsFieldAccessAllowed(roles, markers.roles)
+}
+
+// markerDenial returns the error of the `writexs` marker that keeps the field of structValue from being
+// written: ErrFieldReadOnly, ErrFieldSystemManaged (unless system is set) or ErrFieldImmutable if the
+// field is not zero anymore. The field is looked up by name, so it may come from another struct type.
+func markerDenial(structValue reflect.Value, field reflect.StructField, system bool) error {
+	markers := parseWriteMarkers(field.Tag.Get(tagNameWriteXS))
+	switch {
+	case markers.readOnly:
+		return ErrFieldReadOnly
+	case markers.system && !system:
+		return ErrFieldSystemManaged
+	case markers.immutable && !isZeroField(structValue, field.Name):
+		return ErrFieldImmutable
+	}
+	return nil
+}
+
+// writeDenial returns why the roles may not write the field of structValue: ErrFieldReadOnly or
+// ErrFieldSystemManaged for these markers, ErrUnauthorizedFieldSet if the roles or the `writeif`
+// condition deny it, ErrFieldImmutable if it is immutable and set already, or nil if it is writable.
+func writeDenial(structValue reflect.Value, field reflect.StructField, roles []string) error {
+	markers := parseWriteMarkers(field.Tag.Get(tagNameWriteXS))
+	if markers.readOnly || markers.system {
+		return markerDenial(structValue, field, false)
+	}
+	if !IsFieldAccessAllowed(roles, markers.roles) || !conditionHolds(structValue, field.Tag.Get(tagNameWriteIf), roles) {
+		return ErrUnauthorizedFieldSet
+	}
+	if markers.immutable {
+		return markerDenial(structValue, field, false)
+	}
+	return nil
+}
+
+// mergeDenial is the writeDenial of a field of the update struct merged into the field of the same name
+// of structValue. The update field's `writexs` grants the roles, while the markers and the `writeif`
+// condition of the target field apply as well, so an update struct of another type cannot lift them.
+func mergeDenial(structValue reflect.Value, field reflect.StructField, roles []string) error {
+	if denial := writeDenial(structValue, field, roles); denial != nil {
+		return denial
+	}
+	targetField, ok := structValue.Type().FieldByName(field.Name)
+	if !ok {
+		return nil
+	}
+	markers := parseWriteMarkers(targetField.Tag.Get(tagNameWriteXS))
+	if markers.readOnly || markers.system {
+		return markerDenial(structValue, targetField, false)
+	}
+	if !conditionHolds(structValue, targetField.Tag.Get(tagNameWriteIf), roles) {
+		return ErrUnauthorizedFieldSet
+	}
+	return markerDenial(structValue, targetField, false)
+}
+
+// isMarkerDenial reports whether the error of writeDenial comes from a `writexs` marker.
+func isMarkerDenial(err error) bool {
+	return errors.Is(err, ErrFieldReadOnly) || errors.Is(err, ErrFieldSystemManaged) || errors.Is(err, ErrFieldImmutable)
+}
+
+// isZeroField reports whether the named field of structValue is zero. Fields promoted through a nil
+// embedded pointer are zero.
+func isZeroField(structValue reflect.Value, name string) bool {
+	field, ok := structValue.Type().FieldByName(name)
+	if !ok {
+		return true
+	}
+	value, err := structValue.FieldByIndexErr(field.Index)
+	return err != nil || value.IsZero()
+}
+
+// changesField reports whether assign would change the field value (or fails to assign it). It is used
+// to report marker violations only for updates that would modify a protected field.
+func changesField(field reflect.Value, assign func(attempt reflect.Value) error) bool {
+	attempt := reflect.New(field.Type()).Elem()
+	attempt.Set(field)
+	if err := assign(attempt); err != nil {
+		return true
+	}
+	return !reflect.DeepEqual(attempt.Interface(), field.Interface())
+}
+
+// SetSystemField sets a field of the struct pointer entity on behalf of the system, e.g. a timestamp or a
+// counter maintained by the application. It ignores the roles and the `writeif` conditions and may set
+// `@system` fields, but returns ErrFieldReadOnly for `@readonly` fields and ErrFieldImmutable for
+// `@immutable` fields that are set already to another value. Values are converted like in ApplyMapUpdate.
+func SetSystemField(entity any, fieldName string, value any) error {
+	rv := reflect.ValueOf(entity)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	structField, ok := rv.Elem().Type().FieldByName(fieldName)
+	if !ok || !structField.IsExported() {
+		return ErrInvalidFieldName
+	}
+	field := fieldByIndexAlloc(rv.Elem(), structField.Index)
+	assign := func(target reflect.Value) error {
+		return assignFieldValue(target, value)
+	}
+	if err := markerDenial(rv.Elem(), structField, true); err != nil {
+		if errors.Is(err, ErrFieldImmutable) && !changesField(field, assign) {
+			return nil
+		}
+		return err
+	}
+	return assign(field)
+}
@@ -0,0 +1,166 @@
+package struccy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type markedAccount struct {
+	ID        string `json:"id" readxs:"*" writexs:"*,@immutable"`
+	Handle    string `json:"handle" readxs:"*" writexs:"admin,@immutable"`
+	CreatedBy string `json:"created_by" readxs:"*" writexs:"@system"`
+	Kind      string `json:"kind" readxs:"*" writexs:"admin,@readonly"`
+	Name      string `json:"name" readxs:"*" writexs:"*"`
+	Secret    string `json:"secret" readxs:"admin" writexs:"admin"`
+}
+
+func TestWriteMarkers(t *testing.T) {
+	assert.True(t, IsWriteAccessAllowed([]string{"user"}, "*,@immutable"))
+	assert.False(t, IsWriteAccessAllowed([]string{"user"}, "@immutable"), "markers alone grant no roles")
+	assert.True(t, IsWriteAccessAllowed([]string{"admin"}, "admin,@immutable"))
+	assert.False(t, IsWriteAccessAllowed([]string{"user"}, "admin,@immutable"))
+	assert.False(t, IsWriteAccessAllowed([]string{"admin"}, "@system"))
+	assert.False(t, IsWriteAccessAllowed([]string{"admin"}, "admin,@readonly"))
+	assert.False(t, IsWriteAccessAllowed([]string{"admin"}, "@unknown"), "unknown markers match no role")
+	// IsFieldAccessAllowed does not know about markers
+	assert.False(t, IsFieldAccessAllowed([]string{"user"}, "@immutable"))
+	assert.True(t, IsFieldAccessAllowed([]string{"admin"}, "admin,@readonly"))
+
+	account := &markedAccount{ID: "1"}
+	assert.False(t, IsAllowedToSetField(account, "ID", []string{"user"}))
+	assert.True(t, IsAllowedToSetField(account, "Handle", []string{"admin"}))
+	assert.False(t, IsAllowedToSetField(account, "CreatedBy", []string{"admin"}))
+
+	assert.True(t, errors.Is(SetField(account, "ID", "2", false, nil), ErrFieldImmutable))
+	assert.True(t, errors.Is(SetField(account, "Handle", "h", false, []string{"user"}), ErrUnauthorizedFieldSet))
+	assert.NoError(t, SetField(account, "Handle", "h", false, []string{"admin"}))
+	assert.True(t, errors.Is(SetField(account, "Handle", "g", false, []string{"admin"}), ErrFieldImmutable))
+	assert.True(t, errors.Is(SetField(account, "CreatedBy", "me", false, []string{"admin"}), ErrFieldSystemManaged))
+	assert.True(t, errors.Is(SetPath(account, "kind", "x", []string{"admin"}), ErrFieldReadOnly))
+	assert.Equal(t, "h", account.Handle)
+}
+
+func TestMergeStructUpdateToMarkers(t *testing.T) {
+	target := &markedAccount{ID: "1", CreatedBy: "system", Kind: "user"}
+	admin := []string{"admin"}
+
+	// unchanged protected fields are no violation, an unset immutable field can be set once
+	merged, err := MergeStructUpdateTo(target, &markedAccount{ID: "1", Handle: "h", CreatedBy: "system", Kind: "user", Name: "n"}, admin)
+	assert.NoError(t, err)
+	assert.Equal(t, &markedAccount{ID: "1", Handle: "h", CreatedBy: "system", Kind: "user", Name: "n"}, merged)
+
+	_, err = MergeStructUpdateTo(target, &markedAccount{ID: "2", CreatedBy: "eve", Kind: "admin", Name: "n"}, admin)
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 3, fieldErrs.Len())
+	assert.True(t, errors.Is(fieldErrs.Errors[0], ErrFieldImmutable))
+	assert.Equal(t, "id", fieldErrs.Errors[0].JSONName)
+	assert.True(t, errors.Is(fieldErrs.Errors[1], ErrFieldSystemManaged))
+	assert.True(t, errors.Is(fieldErrs.Errors[2], ErrFieldReadOnly))
+	assert.False(t, errors.Is(err, ErrUnauthorizedFieldSet))
+
+	// fields the roles may not write are still skipped silently
+	merged, err = MergeStructUpdateTo(target, &markedAccount{ID: "1", CreatedBy: "system", Kind: "user", Secret: "s"}, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", merged.(*markedAccount).Secret)
+
+	// the markers of the target apply to update structs of another type
+	type accountUpdate struct {
+		ID   string `writexs:"*"`
+		Kind string `writexs:"*"`
+		Name string `writexs:"*"`
+	}
+	_, err = MergeStructUpdateTo(target, &accountUpdate{ID: "2", Kind: "admin", Name: "n"}, admin)
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 2, fieldErrs.Len())
+	assert.True(t, errors.Is(fieldErrs.Errors[0], ErrFieldImmutable))
+	assert.True(t, errors.Is(fieldErrs.Errors[1], ErrFieldReadOnly))
+	merged, err = MergeStructUpdateTo(target, &accountUpdate{ID: "1", Kind: "user", Name: "n"}, admin)
+	assert.NoError(t, err)
+	assert.Equal(t, &markedAccount{ID: "1", CreatedBy: "system", Kind: "user", Name: "n"}, merged)
+	assert.Equal(t, &markedAccount{ID: "1", CreatedBy: "system", Kind: "user"}, target)
+}
+
+func TestMergeMapStringFieldsToStructMarkers(t *testing.T) {
+	target := &markedAccount{ID: "1", Kind: "user"}
+	_, err := MergeMapStringFieldsToStruct(target, map[string]any{"ID": "1", "Handle": "h", "Name": "n"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "h", target.Handle)
+
+	_, err = MergeMapStringFieldsToStruct(target, map[string]any{"ID": "2", "Handle": "g", "CreatedBy": "eve", "Kind": nil, "Name": "m"}, nil)
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 4, fieldErrs.Len())
+	assert.True(t, errors.Is(err, ErrFieldImmutable))
+	assert.True(t, errors.Is(err, ErrFieldSystemManaged))
+	assert.True(t, errors.Is(err, ErrFieldReadOnly))
+	assert.Equal(t, &markedAccount{ID: "1", Handle: "h", Kind: "user", Name: "n"}, target, "a failed merge leaves the struct unchanged")
+}
+
+func TestUpdateStructFieldsMarkers(t *testing.T) {
+	entity := &markedAccount{ID: "1", Kind: "user"}
+	updated, unsettable, err := UpdateStructFields(entity, &markedAccount{ID: "2", Handle: "h", CreatedBy: "eve", Name: "n"}, []string{"admin"}, false, false)
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Nil(t, updated)
+	assert.Equal(t, map[string]any{"ID": "2", "CreatedBy": "eve"}, unsettable)
+	assert.True(t, errors.Is(fieldErrs.Errors[0], ErrFieldImmutable))
+	assert.True(t, errors.Is(fieldErrs.Errors[1], ErrFieldSystemManaged))
+	assert.Equal(t, "", entity.Handle, "a failed update leaves the entity unchanged")
+	assert.Equal(t, "1", entity.ID)
+
+	// zero values do not change fields, so the readonly Kind is no violation
+	updated, _, err = UpdateStructFields(entity, &markedAccount{ID: "1", Name: "m"}, []string{"admin"}, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "m", updated["Name"])
+	assert.NotContains(t, updated, "ID")
+	assert.NotContains(t, updated, "Kind")
+}
+
+func TestWriteMarkersInDecoders(t *testing.T) {
+	account := &markedAccount{ID: "1"}
+	assert.NoError(t, DecodeJSONWithWriteXS([]byte(`{"id":"2","handle":"h","created_by":"eve","kind":"admin","name":"n"}`), account, []string{"admin"}))
+	assert.Equal(t, &markedAccount{ID: "1", Handle: "h", Name: "n"}, account)
+	assert.NoError(t, ApplyMapUpdate(account, map[string]any{"Handle": "g", "CreatedBy": "eve"}, []string{"admin"}))
+	assert.Equal(t, "h", account.Handle)
+	assert.Equal(t, "", account.CreatedBy)
+}
+
+func TestSetSystemField(t *testing.T) {
+	account := &markedAccount{ID: "1", Kind: "user"}
+	assert.NoError(t, SetSystemField(account, "CreatedBy", "system"))
+	assert.NoError(t, SetSystemField(account, "Secret", "s"), "roles do not apply")
+	assert.NoError(t, SetSystemField(account, "Handle", "h"))
+	assert.NoError(t, SetSystemField(account, "ID", "1"), "setting the same value is no change")
+	assert.True(t, errors.Is(SetSystemField(account, "ID", "2"), ErrFieldImmutable))
+	assert.True(t, errors.Is(SetSystemField(account, "Kind", "admin"), ErrFieldReadOnly))
+	assert.True(t, errors.Is(SetSystemField(account, "Missing", "x"), ErrInvalidFieldName))
+	assert.True(t, errors.Is(SetSystemField(*account, "Name", "x"), ErrInvalidStructPointer))
+	assert.Equal(t, &markedAccount{ID: "1", Handle: "h", CreatedBy: "system", Kind: "user", Secret: "s"}, account)
+}
+
+func TestWriteMarkersInEnvAndDefaults(t *testing.T) {
+	type record struct {
+		ID        string `writexs:"*,@immutable"`
+		Region    string `writexs:"*,@immutable" default:"eu"`
+		CreatedBy string `writexs:"@system" default:"system"`
+		Name      string `writexs:"*"`
+	}
+	vars := map[string]string{"APP_ID": "hacked", "APP_REGION": "us", "APP_CREATED_BY": "eve", "APP_NAME": "n"}
+
+	r := &record{ID: "orig"}
+	assert.NoError(t, LoadEnv(r, "APP_", nil, envLookup(vars)))
+	assert.Equal(t, &record{ID: "orig", Region: "us", Name: "n"}, r, "unset immutable fields can be loaded once")
+	assert.NoError(t, LoadEnv(r, "APP_", nil, envLookup(map[string]string{"APP_REGION": "ap"})))
+	assert.Equal(t, "us", r.Region)
+
+	// fields that are set keep their values, zero fields get their default
+	r = &record{Region: "us", CreatedBy: "eve"}
+	assert.NoError(t, ApplyDefaults(r, nil))
+	assert.Equal(t, &record{Region: "us", CreatedBy: "eve"}, r)
+	r = &record{}
+	assert.NoError(t, ApplyDefaults(r, nil))
+	assert.Equal(t, &record{Region: "eu", CreatedBy: "system"}, r)
+}
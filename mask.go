@@ -0,0 +1,521 @@
+package struccy
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// FieldMask is a list of field paths in the dot notation accepted by SetPath, e.g.
+// `name` or `address.city`, like a Google `google.protobuf.FieldMask`.
+type FieldMask []string
+
+// ParseFieldMask parses a comma separated mask as sent in `field_mask` or `?fields=` parameters,
+// e.g. "name,address.city". Empty entries are ignored.
+func ParseFieldMask(mask string) FieldMask {
+	paths := make(FieldMask, 0)
+	for _, path := range strings.Split(mask, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// MaskOption configures ProjectWithMask and UpdateWithMask.
+type MaskOption func(*maskOptions)
+
+type maskOptions struct {
+	dropUnknownPaths bool
+}
+
+// WithDropUnknownPaths silently ignores mask paths that do not exist on the type,
+// instead of reporting them as ErrFieldNotFound.
+func WithDropUnknownPaths() MaskOption {
+	return func(options *maskOptions) {
+		options.dropUnknownPaths = true
+	}
+}
+
+// maskNode is a node of the tree built from the mask paths. A node without children selects
+// everything below it.
+type maskNode struct {
+	children map[string]*maskNode
+}
+
+func newMaskTree(mask FieldMask) (*maskNode, error) {
+	root := &maskNode{}
+	for _, path := range mask {
+		segments, err := parsePath(path)
+		if err != nil {
+			return nil, err
+		}
+		node := root
+		created := true
+		for i, segment := range segments {
+			if node.children == nil {
+				if !created {
+					// a shorter path already selects everything below
+					break
+				}
+				node.children = make(map[string]*maskNode)
+			}
+			child, ok := node.children[segment]
+			created = !ok
+			if !ok {
+				child = &maskNode{}
+				node.children[segment] = child
+			} else if i == len(segments)-1 {
+				// the full path selects everything below
+				child.children = nil
+			}
+			node = child
+		}
+	}
+	return root, nil
+}
+
+// ProjectWithMask converts the struct (pointer) v into a map containing only the fields selected
+// by the mask that the roles are allowed to read. Keys are JSON field names and nested structs
+// become nested maps; slices apply the rest of the path to each element and maps treat the
+// next segment as a key. Selected nested structs are projected with `readxs` as well. The fields of
+// embedded structs are promoted as with encoding/json, and the embedded structs' own access tags apply
+// to them. An empty mask selects all readable fields.
+//
+// Mask paths the roles cannot read are dropped. Paths that do not exist on the type are reported
+// together as a *FieldErrors with ErrFieldNotFound as cause, unless WithDropUnknownPaths is given.
+func ProjectWithMask(v any, mask FieldMask, roles []string, opts ...MaskOption) (map[string]any, error) {
+	options := &maskOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, ErrInvalidStructPointer
+	}
+	tree, err := newMaskTree(mask)
+	if err != nil {
+		return nil, err
+	}
+	fieldErrs := &FieldErrors{}
+	if !options.dropUnknownPaths {
+		checkMaskPaths(rv.Type(), tree, &resolvedPath{}, fieldErrs)
+	}
+	if err := fieldErrs.errOrNil(); err != nil {
+		return nil, err
+	}
+	projected, _ := projectMasked(rv, tree, roles).(map[string]any)
+	return projected, nil
+}
+
+// ProjectWithReadXS projects any value like ProjectWithMask with an empty mask: structs become maps
+// of the fields the roles may read, keyed by JSON names, also when nested in pointers, slices, arrays,
+// maps and other structs. Other values, like a []string or time.Time, are returned as they are.
+func ProjectWithReadXS(v any, roles []string) any {
+	return projectMasked(reflect.ValueOf(v), &maskNode{}, roles)
+}
+
+// UpdateWithMask copies the fields selected by the mask from src to target (both pointers to the
+// same struct type) where the roles are allowed to write, following field mask update semantics:
+// a selected field that is zero in src is cleared in target, including nil pointers to structs.
+// Selected nested structs are copied field by field, so their `writexs` tags apply as well. So are
+// the structs in slices, arrays and maps, which are updated element by element from the target
+// element at the same index or key; paths into slices apply to every element, while paths into
+// maps select the entries to update. An empty mask selects all fields.
+//
+// It returns the Go paths of the fields that were written. Mask paths the roles cannot write are
+// dropped. Unknown paths are handled as in ProjectWithMask.
+func UpdateWithMask(target any, src any, mask FieldMask, roles []string, opts ...MaskOption) ([]string, error) {
+	options := &maskOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	targetValue := reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Ptr || targetValue.Elem().Kind() != reflect.Struct {
+		return nil, ErrTargetStructMustBePointer
+	}
+	srcValue := reflect.ValueOf(src)
+	if srcValue.Kind() != reflect.Ptr || srcValue.Elem().Kind() != reflect.Struct {
+		return nil, ErrSourceStructMustBePointer
+	}
+	if srcValue.Elem().Type() != targetValue.Elem().Type() {
+		return nil, ErrDifferentStructType
+	}
+	tree, err := newMaskTree(mask)
+	if err != nil {
+		return nil, err
+	}
+	fieldErrs := &FieldErrors{}
+	if !options.dropUnknownPaths {
+		checkMaskPaths(targetValue.Elem().Type(), tree, &resolvedPath{}, fieldErrs)
+	}
+	if err := fieldErrs.errOrNil(); err != nil {
+		return nil, err
+	}
+
+	updated := make([]string, 0)
+	updateMasked(targetValue.Elem(), srcValue.Elem(), tree, roles, "", &updated)
+	sort.Strings(updated)
+	return updated, nil
+}
+
+// checkMaskPaths reports every mask path that cannot be resolved on the type.
+func checkMaskPaths(typ reflect.Type, node *maskNode, resolved *resolvedPath, fieldErrs *FieldErrors) {
+	typ = indirectType(typ)
+	for _, segment := range sortedMapKeys(node.children) {
+		child := node.children[segment]
+		nested := &resolvedPath{goPath: append([]string{}, resolved.goPath...), jsonPath: append([]string{}, resolved.jsonPath...)}
+		switch typ.Kind() {
+		case reflect.Struct:
+			plan := maskPlan(fieldPlans(typ, JSONFieldNames), segment)
+			if plan == nil {
+				fieldErrs.Add(nested.fieldError(segment, segment, ErrFieldNotFound))
+				continue
+			}
+			nested.push(plan.field.Name, plan.name)
+			checkMaskPaths(plan.field.Type, child, nested, fieldErrs)
+		case reflect.Slice, reflect.Array:
+			// the segment applies to the elements
+			checkMaskPaths(typ.Elem(), &maskNode{children: map[string]*maskNode{segment: child}}, resolved, fieldErrs)
+		case reflect.Map:
+			if _, err := mapKey(segment, typ.Key()); err != nil {
+				fieldErrs.Add(nested.fieldError(segment, segment, ErrFieldNotFound))
+				continue
+			}
+			nested.push(segment, segment)
+			checkMaskPaths(typ.Elem(), child, nested, fieldErrs)
+		case reflect.Interface:
+			// dynamic content cannot be checked
+		default:
+			fieldErrs.Add(nested.fieldError(segment, segment, ErrFieldNotFound))
+		}
+	}
+}
+
+// projectMasked projects the value along the mask node, applying `readxs` to all struct fields.
+func projectMasked(value reflect.Value, node *maskNode, roles []string) any {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		if isOpaqueStruct(value.Type()) {
+			return valueInterface(value)
+		}
+		result := make(map[string]any)
+		plans := fieldPlans(value.Type(), JSONFieldNames)
+		if len(node.children) == 0 {
+			for i := range plans {
+				plan := &plans[i]
+				fieldValue, ok := plan.value(value)
+				if !ok || !plan.allowed(value, roles, OpRead) {
+					continue
+				}
+				result[plan.name] = projectMasked(fieldValue, node, roles)
+			}
+			return result
+		}
+		for _, segment := range sortedMapKeys(node.children) {
+			plan := maskPlan(plans, segment)
+			if plan == nil || !plan.allowed(value, roles, OpRead) {
+				continue
+			}
+			fieldValue, ok := plan.value(value)
+			if !ok {
+				// promoted through a nil embedded pointer
+				continue
+			}
+			projected := projectMasked(fieldValue, node.children[segment], roles)
+			// several paths through the same nested struct are merged
+			if existing, ok := result[plan.name].(map[string]any); ok {
+				if projectedMap, ok := projected.(map[string]any); ok {
+					for key, val := range projectedMap {
+						existing[key] = val
+					}
+					continue
+				}
+			}
+			result[plan.name] = projected
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil
+		}
+		if len(node.children) == 0 && !hasNestedStructs(value.Type().Elem()) {
+			return valueInterface(value)
+		}
+		result := make([]any, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			result[i] = projectMasked(value.Index(i), node, roles)
+		}
+		return result
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		if len(node.children) == 0 {
+			if !hasNestedStructs(value.Type().Elem()) {
+				return valueInterface(value)
+			}
+			result := make(map[string]any, value.Len())
+			iter := value.MapRange()
+			for iter.Next() {
+				result[mapKeyString(iter.Key())] = projectMasked(iter.Value(), node, roles)
+			}
+			return result
+		}
+		result := make(map[string]any)
+		for _, segment := range sortedMapKeys(node.children) {
+			key, err := mapKey(segment, value.Type().Key())
+			if err != nil {
+				continue
+			}
+			if entry := value.MapIndex(key); entry.IsValid() {
+				result[segment] = projectMasked(entry, node.children[segment], roles)
+			}
+		}
+		return result
+	}
+	return valueInterface(value)
+}
+
+// updateMasked copies the masked fields from src to target, applying `writexs` to all struct fields.
+func updateMasked(target reflect.Value, src reflect.Value, node *maskNode, roles []string, pathPrefix string, updated *[]string) {
+	structType := target.Type()
+	// the `writeif` conditions are evaluated on the target as it was before the update
+	original := reflect.New(structType).Elem()
+	original.Set(target)
+	plans := fieldPlans(structType, JSONFieldNames)
+	if len(node.children) == 0 {
+		for i := range plans {
+			updateMaskedField(target, src, original, &plans[i], &maskNode{}, roles, pathPrefix, updated)
+		}
+		return
+	}
+	for _, segment := range sortedMapKeys(node.children) {
+		if plan := maskPlan(plans, segment); plan != nil {
+			updateMaskedField(target, src, original, plan, node.children[segment], roles, pathPrefix, updated)
+		}
+	}
+}
+
+// maskPlan returns the plan of the field a mask segment names by its JSON or Go name, or nil.
+func maskPlan(plans []fieldPlan, segment string) *fieldPlan {
+	if plan := findFieldPlan(plans, segment, false); plan != nil {
+		return plan
+	}
+	for i := range plans {
+		if plans[i].field.Name == segment {
+			return &plans[i]
+		}
+	}
+	return nil
+}
+
+func updateMaskedField(target reflect.Value, src reflect.Value, original reflect.Value, plan *fieldPlan, node *maskNode, roles []string, pathPrefix string, updated *[]string) {
+	if !plan.allowed(original, roles, OpWrite) {
+		return
+	}
+	field := plan.field
+	path := pathPrefix + field.Name
+	srcField, ok := plan.value(src)
+	if !ok {
+		// promoted through a nil embedded pointer
+		srcField = reflect.Zero(field.Type)
+	}
+	targetField := fieldByIndexAlloc(target, plan.index)
+
+	nestedType := indirectType(field.Type)
+	if nestedType.Kind() == reflect.Struct && !isOpaqueStruct(nestedType) {
+		if srcField.Kind() == reflect.Ptr && srcField.IsNil() {
+			if len(node.children) == 0 {
+				// the whole struct is selected, so it is cleared like any other zero field
+				targetField.Set(srcField)
+				*updated = append(*updated, path)
+				return
+			}
+			if targetField.IsNil() {
+				// the selected fields are zero already
+				return
+			}
+			srcField = reflect.New(nestedType)
+		}
+		// nested structs are copied field by field, so their access tags apply
+		if targetField.Kind() == reflect.Ptr {
+			if targetField.IsNil() {
+				targetField.Set(reflect.New(nestedType))
+			}
+			targetField = targetField.Elem()
+		}
+		if srcField.Kind() == reflect.Ptr {
+			srcField = srcField.Elem()
+		}
+		updateMasked(targetField, srcField, node, roles, path+".", updated)
+		return
+	}
+	if isStructCollection(field.Type) {
+		targetField.Set(updateMaskedElements(targetField, srcField, node, roles))
+		*updated = append(*updated, path)
+		return
+	}
+	targetField.Set(srcField)
+	*updated = append(*updated, path)
+}
+
+// isStructCollection reports whether the type is a slice, array or map with (non-opaque) structs as elements.
+func isStructCollection(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return typ.Elem().Kind() != reflect.Interface && hasNestedStructs(typ.Elem())
+	}
+	return false
+}
+
+// updateMaskedElements returns a copy of the src slice, array or map whose elements are updated from src
+// like nested structs, starting from the target element at the same index or key, so the `writexs` tags
+// of the elements apply. The rest of the mask path applies to each slice element, while for maps the
+// next segment selects the entries to update and all other entries are kept.
+func updateMaskedElements(target reflect.Value, src reflect.Value, node *maskNode, roles []string) reflect.Value {
+	switch src.Kind() {
+	case reflect.Slice, reflect.Array:
+		if src.Kind() == reflect.Slice && src.IsNil() {
+			return src
+		}
+		result := reflect.New(src.Type()).Elem()
+		if src.Kind() == reflect.Slice {
+			result.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		}
+		for i := 0; i < src.Len(); i++ {
+			var existing reflect.Value
+			if i < target.Len() {
+				existing = target.Index(i)
+			}
+			result.Index(i).Set(updateMaskedElement(existing, src.Index(i), node, roles))
+		}
+		return result
+	case reflect.Map:
+		if len(node.children) == 0 {
+			if src.IsNil() {
+				return src
+			}
+			result := reflect.MakeMapWithSize(src.Type(), src.Len())
+			iter := src.MapRange()
+			for iter.Next() {
+				result.SetMapIndex(iter.Key(), updateMaskedElement(target.MapIndex(iter.Key()), iter.Value(), node, roles))
+			}
+			return result
+		}
+		result := reflect.MakeMapWithSize(src.Type(), target.Len())
+		iter := target.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(iter.Key(), iter.Value())
+		}
+		for _, segment := range sortedMapKeys(node.children) {
+			key, err := mapKey(segment, src.Type().Key())
+			if err != nil {
+				continue
+			}
+			entry := src.MapIndex(key)
+			if !entry.IsValid() {
+				// entries missing in src are cleared
+				result.SetMapIndex(key, reflect.Value{})
+				continue
+			}
+			result.SetMapIndex(key, updateMaskedElement(target.MapIndex(key), entry, node.children[segment], roles))
+		}
+		if target.IsNil() && result.Len() == 0 {
+			return target
+		}
+		return result
+	}
+	return src
+}
+
+// updateMaskedElement returns the element existing (which may be invalid for new elements) updated from src.
+func updateMaskedElement(existing reflect.Value, src reflect.Value, node *maskNode, roles []string) reflect.Value {
+	result := reflect.New(src.Type()).Elem()
+	if existing.IsValid() {
+		result.Set(existing)
+	}
+	elemType := indirectType(src.Type())
+	switch {
+	case elemType.Kind() == reflect.Struct && !isOpaqueStruct(elemType):
+		discarded := make([]string, 0)
+		if src.Kind() == reflect.Ptr {
+			if src.IsNil() {
+				return src
+			}
+			// the element is copied, as the target may share it
+			element := reflect.New(elemType)
+			if !result.IsNil() {
+				element.Elem().Set(result.Elem())
+			}
+			result.Set(element)
+			updateMasked(result.Elem(), src.Elem(), node, roles, "", &discarded)
+			return result
+		}
+		updateMasked(result, src, node, roles, "", &discarded)
+		return result
+	case isStructCollection(src.Type()):
+		result.Set(updateMaskedElements(result, src, node, roles))
+		return result
+	}
+	return src
+}
+
+// isOpaqueStruct reports whether a struct type is treated as a single value instead of a set of fields,
+// e.g. time.Time or types with their own JSON or text marshaling.
+func isOpaqueStruct(structType reflect.Type) bool {
+	if structType == timeType || structType.Implements(jsonMarshalerType) || reflect.PointerTo(structType).Implements(jsonMarshalerType) ||
+		structType.Implements(textMarshalerType) || reflect.PointerTo(structType).Implements(textMarshalerType) {
+		return true
+	}
+	for i := 0; i < structType.NumField(); i++ {
+		if structType.Field(i).IsExported() {
+			return false
+		}
+	}
+	return true
+}
+
+// hasNestedStructs reports whether values of the type can contain (non-opaque) structs that need projecting.
+func hasNestedStructs(typ reflect.Type) bool {
+	typ = indirectType(typ)
+	switch typ.Kind() {
+	case reflect.Struct:
+		return !isOpaqueStruct(typ)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return hasNestedStructs(typ.Elem())
+	case reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// mapKeyString formats a map key for use as a map[string]any key.
+func mapKeyString(key reflect.Value) string {
+	if key.Kind() == reflect.String {
+		return key.String()
+	}
+	if marshaler, ok := key.Interface().(encoding.TextMarshaler); ok {
+		if text, err := marshaler.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(key.Interface())
+}
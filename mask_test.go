@@ -0,0 +1,235 @@
+package struccy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type maskGeo struct {
+	Lat float64 `json:"lat" readxs:"*" writexs:"*"`
+	Lng float64 `json:"lng" readxs:"admin" writexs:"admin"`
+}
+
+type maskAddress struct {
+	City string   `json:"city" readxs:"*" writexs:"*"`
+	Zip  string   `json:"zip" readxs:"*" writexs:"admin"`
+	Geo  *maskGeo `json:"geo" readxs:"*" writexs:"*"`
+}
+
+type maskUser struct {
+	Name      string            `json:"name" readxs:"*" writexs:"*"`
+	Email     string            `json:"email" readxs:"admin,self" writexs:"self"`
+	Address   *maskAddress      `json:"address" readxs:"*" writexs:"*"`
+	Friends   []maskAddress     `json:"friends" readxs:"*" writexs:"*"`
+	Labels    map[string]string `json:"labels" readxs:"*" writexs:"*"`
+	CreatedAt time.Time         `json:"created_at" readxs:"*" writexs:"system"`
+}
+
+func newMaskUser() *maskUser {
+	return &maskUser{
+		Name:      "Jane",
+		Email:     "jane@example.com",
+		Address:   &maskAddress{City: "Berlin", Zip: "10115", Geo: &maskGeo{Lat: 52.5, Lng: 13.4}},
+		Friends:   []maskAddress{{City: "Paris", Zip: "75001"}, {City: "Rome"}},
+		Labels:    map[string]string{"team": "core", "tier": "gold"},
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestParseFieldMask(t *testing.T) {
+	assert.Equal(t, FieldMask{"name", "address.city"}, ParseFieldMask(" name, ,address.city,"))
+	assert.Equal(t, FieldMask{}, ParseFieldMask(""))
+}
+
+func TestProjectWithMask(t *testing.T) {
+	user := newMaskUser()
+
+	projected, err := ProjectWithMask(user, ParseFieldMask("name,email,address.city,address.geo,labels.team,friends.city"), []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"name": "Jane",
+		"address": map[string]any{
+			"city": "Berlin",
+			"geo":  map[string]any{"lat": 52.5},
+		},
+		"labels":  map[string]any{"team": "core"},
+		"friends": []any{map[string]any{"city": "Paris"}, map[string]any{"city": "Rome"}},
+	}, projected)
+
+	projected, err = ProjectWithMask(user, FieldMask{"Email", "CreatedAt"}, []string{"self"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"email": "jane@example.com", "created_at": user.CreatedAt}, projected)
+
+	// an empty mask selects all readable fields
+	projected, err = ProjectWithMask(user, nil, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Len(t, projected, 6)
+	assert.Equal(t, map[string]any{"lat": 52.5, "lng": 13.4}, projected["address"].(map[string]any)["geo"])
+
+	// nil pointers stay nil
+	projected, err = ProjectWithMask(&maskUser{}, FieldMask{"address.city"}, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"address": nil}, projected)
+}
+
+func TestProjectWithMask_UnknownPaths(t *testing.T) {
+	user := newMaskUser()
+	_, err := ProjectWithMask(user, FieldMask{"name", "nickname", "address.street"}, []string{"user"})
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 2, fieldErrs.Len())
+	assert.Equal(t, "Address.street", fieldErrs.Errors[0].Path)
+	assert.Equal(t, "address.street", fieldErrs.Errors[0].JSONName)
+	assert.Equal(t, "nickname", fieldErrs.Errors[1].Path)
+	assert.True(t, errors.Is(err, ErrFieldNotFound))
+
+	projected, err := ProjectWithMask(user, FieldMask{"name", "nickname"}, []string{"user"}, WithDropUnknownPaths())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "Jane"}, projected)
+
+	_, err = ProjectWithMask(user, FieldMask{"address..city"}, []string{"user"})
+	assert.True(t, errors.Is(err, ErrInvalidPath))
+	_, err = ProjectWithMask([]string{}, nil, nil)
+	assert.Equal(t, ErrInvalidStructPointer, err)
+}
+
+func TestUpdateWithMask(t *testing.T) {
+	target := newMaskUser()
+	src := &maskUser{
+		Name:      "Janet",
+		Email:     "janet@example.com",
+		Address:   &maskAddress{City: "Munich", Zip: "80331", Geo: &maskGeo{Lat: 48.1, Lng: 11.6}},
+		CreatedAt: time.Now(),
+	}
+
+	updated, err := UpdateWithMask(target, src, ParseFieldMask("name,email,address,labels,created_at"), []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Address.City", "Address.Geo.Lat", "Labels", "Name"}, updated)
+
+	assert.Equal(t, "Janet", target.Name)
+	assert.Equal(t, "jane@example.com", target.Email)
+	assert.Equal(t, "Munich", target.Address.City)
+	assert.Equal(t, "10115", target.Address.Zip)
+	assert.Equal(t, 48.1, target.Address.Geo.Lat)
+	assert.Equal(t, 13.4, target.Address.Geo.Lng)
+	assert.Nil(t, target.Labels) // cleared, as it is zero in src
+	assert.Equal(t, 2024, target.CreatedAt.Year())
+	assert.Len(t, target.Friends, 2) // not in mask
+}
+
+func TestUpdateWithMask_NilAndCollections(t *testing.T) {
+	// a selected nil pointer clears the target pointer, a selected field below it is cleared
+	target := newMaskUser()
+	updated, err := UpdateWithMask(target, &maskUser{}, FieldMask{"address"}, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Address"}, updated)
+	assert.Nil(t, target.Address)
+	target = newMaskUser()
+	_, err = UpdateWithMask(target, &maskUser{}, FieldMask{"address.city"}, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, &maskAddress{Zip: "10115", Geo: &maskGeo{Lat: 52.5, Lng: 13.4}}, target.Address)
+	target = &maskUser{}
+	_, err = UpdateWithMask(target, &maskUser{}, FieldMask{"address.city"}, []string{"user"})
+	assert.NoError(t, err)
+	assert.Nil(t, target.Address)
+
+	// the elements of struct slices are updated with their `writexs` tags
+	target = newMaskUser()
+	src := &maskUser{Friends: []maskAddress{{City: "Lyon", Zip: "69001"}, {City: "Milan", Zip: "20121"}, {City: "Oslo", Zip: "0150"}}}
+	updated, err = UpdateWithMask(target, src, FieldMask{"friends"}, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Friends"}, updated)
+	assert.Equal(t, []maskAddress{{City: "Lyon", Zip: "75001"}, {City: "Milan"}, {City: "Oslo"}}, target.Friends)
+	target = newMaskUser()
+	_, err = UpdateWithMask(target, src, FieldMask{"friends.zip"}, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, []maskAddress{{City: "Paris", Zip: "69001"}, {City: "Rome", Zip: "20121"}, {Zip: "0150"}}, target.Friends)
+
+	// paths into maps select the entries
+	type directory struct {
+		Offices map[string]*maskAddress `json:"offices" writexs:"*"`
+	}
+	office := &maskAddress{City: "Berlin", Zip: "10115"}
+	dir := &directory{Offices: map[string]*maskAddress{"hq": office, "lab": {City: "Jena"}}}
+	_, err = UpdateWithMask(dir, &directory{Offices: map[string]*maskAddress{"hq": {City: "Bonn", Zip: "53111"}, "new": {City: "Kiel"}}}, FieldMask{"offices.hq", "offices.lab"}, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*maskAddress{"hq": {City: "Bonn", Zip: "10115"}}, dir.Offices)
+	assert.Equal(t, "Berlin", office.City) // the target elements are copied
+}
+
+type maskBase struct {
+	ID string `json:"id" readxs:"*" writexs:"*"`
+}
+
+type maskAccess struct {
+	Secret string `json:"secret" readxs:"*" writexs:"*"`
+}
+
+type maskAccount struct {
+	maskBase
+	maskAccess `readxs:"admin" writexs:"admin"`
+	Name       string `json:"name" readxs:"*" writexs:"*"`
+}
+
+func TestMaskEmbeddedStructs(t *testing.T) {
+	account := &maskAccount{maskBase: maskBase{ID: "a-1"}, maskAccess: maskAccess{Secret: "s"}, Name: "n"}
+	user := []string{"user"}
+
+	// promoted fields are flattened like with encoding/json, and the embedded structs' tags apply
+	projected := ProjectWithReadXS(account, user)
+	assert.Equal(t, map[string]any{"id": "a-1", "name": "n"}, projected)
+	jsonStr, err := StructToJSONFieldsWithReadXS(account, user, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"a-1","name":"n"}`, jsonStr)
+	assert.Equal(t, map[string]any{"id": "a-1", "name": "n", "secret": "s"}, ProjectWithReadXS(account, []string{"admin"}))
+
+	projected, err = ProjectWithMask(account, FieldMask{"secret", "id"}, user)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": "a-1"}, projected)
+
+	updated, err := UpdateWithMask(account, &maskAccount{maskBase: maskBase{ID: "a-2"}, maskAccess: maskAccess{Secret: "x"}}, FieldMask{"secret", "id"}, user)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ID"}, updated)
+	assert.Equal(t, "s", account.Secret)
+	assert.Equal(t, "a-2", account.ID)
+}
+
+func TestUpdateWithMask_Errors(t *testing.T) {
+	target := newMaskUser()
+	_, err := UpdateWithMask(target, &maskUser{}, FieldMask{"address.street"}, []string{"user"})
+	assert.True(t, errors.Is(err, ErrFieldNotFound))
+
+	updated, err := UpdateWithMask(target, &maskUser{Name: "x"}, FieldMask{"address.street", "name"}, []string{"user"}, WithDropUnknownPaths())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Name"}, updated)
+
+	_, err = UpdateWithMask(target, &maskAddress{}, FieldMask{"name"}, nil)
+	assert.Equal(t, ErrDifferentStructType, err)
+	_, err = UpdateWithMask(*target, &maskUser{}, FieldMask{"name"}, nil)
+	assert.Equal(t, ErrTargetStructMustBePointer, err)
+	_, err = UpdateWithMask(target, maskUser{}, FieldMask{"name"}, nil)
+	assert.Equal(t, ErrSourceStructMustBePointer, err)
+}
+
+func TestProjectWithReadXS(t *testing.T) {
+	users := []*maskUser{newMaskUser(), nil}
+	projected := ProjectWithReadXS(map[string]any{"users": users, "total": 2}, []string{"user"})
+	assert.Equal(t, map[string]any{
+		"users": []any{
+			map[string]any{
+				"name":       "Jane",
+				"address":    map[string]any{"city": "Berlin", "zip": "10115", "geo": map[string]any{"lat": 52.5}},
+				"friends":    []any{map[string]any{"city": "Paris", "zip": "75001", "geo": nil}, map[string]any{"city": "Rome", "zip": "", "geo": nil}},
+				"labels":     map[string]string{"team": "core", "tier": "gold"},
+				"created_at": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			nil,
+		},
+		"total": 2,
+	}, projected)
+	assert.Nil(t, ProjectWithReadXS(nil, nil))
+	assert.Equal(t, []string{"a"}, ProjectWithReadXS([]string{"a"}, nil))
+}
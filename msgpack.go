@@ -0,0 +1,921 @@
+package struccy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// ErrInvalidMsgPack is returned for malformed MessagePack data and values that do not fit the target field.
+var ErrInvalidMsgPack = errors.New("invalid msgpack data")
+
+var errMsgPackDepth = fmt.Errorf("%w: exceeded max depth of %d", ErrInvalidMsgPack, msgpackMaxDepth)
+
+// msgpackTimestampExt is the extension type of the MessagePack timestamp.
+const msgpackTimestampExt = -1
+
+// msgpackPreallocLimit limits how many elements are allocated up front for a length read from
+// the input, so a forged length cannot make the decoder allocate huge buffers.
+const msgpackPreallocLimit = 1 << 16
+
+// msgpackMaxDepth limits how deeply arrays and maps may be nested in the input, so deeply nested
+// data cannot overflow the stack of the decoder. encoding/json uses the same limit.
+const msgpackMaxDepth = 10000
+
+// MsgPackEncoder writes MessagePack encoded structs to an io.Writer.
+type MsgPackEncoder struct {
+	w io.Writer
+}
+
+// NewMsgPackEncoder returns an encoder writing to w. Every encoded value is written with a single Write call.
+func NewMsgPackEncoder(w io.Writer) *MsgPackEncoder {
+	return &MsgPackEncoder{w: w}
+}
+
+// EncodeWithReadXS writes the struct pointer as a MessagePack map with exactly the entries
+// StructToMapFieldsWithReadXS returns for the same arguments, in field order.
+// Nested structs are encoded as maps with the same naming; time.Time values use the
+// MessagePack timestamp extension and encoding.TextMarshaler types are encoded as strings.
+//
+// If the provided `structPtr` is not a pointer to a struct, the function returns
+// an error (`ErrInvalidStructPointer`).
+func (e *MsgPackEncoder) EncodeWithReadXS(structPtr any, xsList []string, opts ...ConvertOption) error {
+	structValue := reflect.ValueOf(structPtr)
+	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	options := newConvertOptions(opts)
+	// the map header needs the number of entries, so they are encoded first
+	entries := &msgpackEncoder{naming: options.naming}
+	count := 0
+	include := func(plan *fieldPlan) bool {
+		return plan.allowed(structValue.Elem(), xsList, OpRead)
+	}
+	var err error
+	eachIncludedField(structValue.Elem(), tagKeyJSON, options.naming, false, include, func(plan *fieldPlan, value reflect.Value) {
+		if err != nil {
+			return
+		}
+		count++
+		entries.writeString(plan.name)
+		err = entries.encode(reflect.ValueOf(plan.output(value)))
+	})
+	if err != nil {
+		return err
+	}
+	enc := &msgpackEncoder{naming: options.naming}
+	enc.writeMapHeader(count)
+	enc.buf.Write(entries.buf.Bytes())
+	_, err = e.w.Write(enc.buf.Bytes())
+	return err
+}
+
+// MsgPackDecoder reads MessagePack encoded structs from an io.Reader.
+type MsgPackDecoder struct {
+	r msgpackReader
+}
+
+type msgpackReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// NewMsgPackDecoder returns a decoder reading from r. The decoder may read ahead,
+// so consecutive values of one stream must be read with the same decoder.
+func NewMsgPackDecoder(r io.Reader) *MsgPackDecoder {
+	if br, ok := r.(msgpackReader); ok {
+		return &MsgPackDecoder{r: br}
+	}
+	return &MsgPackDecoder{r: bufio.NewReader(r)}
+}
+
+// DecodeWithWriteXS reads the next MessagePack map from the stream into the target struct pointer,
+// setting only the fields with write access allowed based on the provided xsList; other keys are
+// skipped, as are keys without a matching field. Keys are matched like in EncodeWithReadXS.
+// Every decoded field is replaced as a whole.
+//
+// Fields that cannot be decoded do not abort the decoding; they are returned together as a
+// *FieldErrors with ErrInvalidFieldValue as cause, along with the failures of the `validate` tags.
+// io.EOF is returned when the stream has no more values.
+func (d *MsgPackDecoder) DecodeWithWriteXS(targetStruct any, xsList []string, opts ...ConvertOption) error {
+	targetValue := reflect.ValueOf(targetStruct)
+	if targetValue.Kind() != reflect.Ptr || targetValue.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	structValue := targetValue.Elem()
+	options := newConvertOptions(opts)
+	plans := fieldPlans(structValue.Type(), options.naming)
+	writable := allowedPlans(structValue, plans, xsList, OpWrite)
+
+	head, err := d.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	count, ok, err := readMsgPackMapLen(d.r, head)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: expected a map, got format 0x%02x", ErrInvalidMsgPack, head)
+	}
+
+	fieldErrs := &FieldErrors{}
+	for i := 0; i < count; i++ {
+		var key string
+		if err := decodeMsgPack(d.r, reflect.ValueOf(&key).Elem(), options.naming, 1); err != nil {
+			return err
+		}
+		var raw bytes.Buffer
+		if err := readMsgPackRaw(d.r, &raw, 1); err != nil {
+			return err
+		}
+		plan := findFieldPlan(plans, key, false)
+		if plan == nil || !writable[plan] {
+			continue
+		}
+		decoded := reflect.New(plan.field.Type).Elem()
+		if err := decodeMsgPack(bytes.NewReader(raw.Bytes()), decoded, options.naming, 1); err != nil {
+			fieldErrs.Add(&FieldError{
+				Path:     plan.field.Name,
+				JSONName: jsonFieldName(plan.field),
+				Cause:    fmt.Errorf("%w: %v", ErrInvalidFieldValue, err),
+				Expected: plan.field.Type,
+			})
+			continue
+		}
+		fieldByIndexAlloc(structValue, plan.index).Set(decoded)
+	}
+	validateStructValue(structValue, xsList, "", "", fieldErrs)
+	return fieldErrs.errOrNil()
+}
+
+// EncodeMsgPackWithReadXS writes the struct pointer to w as MessagePack, see MsgPackEncoder.EncodeWithReadXS.
+func EncodeMsgPackWithReadXS(w io.Writer, structPtr any, xsList []string, opts ...ConvertOption) error {
+	return NewMsgPackEncoder(w).EncodeWithReadXS(structPtr, xsList, opts...)
+}
+
+// DecodeMsgPackWithWriteXS reads a single MessagePack map from r into the target struct pointer,
+// see MsgPackDecoder.DecodeWithWriteXS. Use a MsgPackDecoder to read several values from one stream.
+func DecodeMsgPackWithWriteXS(r io.Reader, targetStruct any, xsList []string, opts ...ConvertOption) error {
+	return NewMsgPackDecoder(r).DecodeWithWriteXS(targetStruct, xsList, opts...)
+}
+
+// msgpackEncoder encodes values into buf.
+type msgpackEncoder struct {
+	naming FieldNaming
+	buf    bytes.Buffer
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf.WriteByte(0xc0)
+		return nil
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			e.buf.WriteByte(0xc0)
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		e.writeTimestamp(v.Interface().(time.Time))
+		return nil
+	}
+	if marshaler, ok := v.Interface().(encoding.TextMarshaler); ok && v.Kind() != reflect.String {
+		text, err := marshaler.MarshalText()
+		if err != nil {
+			return err
+		}
+		e.writeString(string(text))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf.WriteByte(0xc3)
+		} else {
+			e.buf.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.writeUint(v.Uint())
+	case reflect.Float32:
+		e.buf.WriteByte(0xca)
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(v.Float()))))
+	case reflect.Float64:
+		e.buf.WriteByte(0xcb)
+		e.buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v.Float())))
+	case reflect.String:
+		e.writeString(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			bin := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(bin), v)
+			e.writeBinary(bin)
+			return nil
+		}
+		e.writeLen(v.Len(), 0x90, 16, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		e.writeMapHeader(v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := e.encode(iter.Key()); err != nil {
+				return err
+			}
+			if err := e.encode(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		var entries msgpackEncoder
+		entries.naming = e.naming
+		count := 0
+		var err error
+		eachIncludedField(v, tagKeyJSON, e.naming, false, func(*fieldPlan) bool { return err == nil }, func(plan *fieldPlan, value reflect.Value) {
+			count++
+			entries.writeString(plan.name)
+			err = entries.encode(reflect.ValueOf(plan.output(value)))
+		})
+		if err != nil {
+			return err
+		}
+		e.writeMapHeader(count)
+		e.buf.Write(entries.buf.Bytes())
+	default:
+		return fmt.Errorf("%w: %v", ErrUnsupportedFieldType, v.Type())
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) writeInt(i int64) {
+	switch {
+	case i >= 0:
+		e.writeUint(uint64(i))
+	case i >= -32:
+		e.buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8:
+		e.buf.WriteByte(0xd0)
+		e.buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16:
+		e.buf.WriteByte(0xd1)
+		e.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(int16(i))))
+	case i >= math.MinInt32:
+		e.buf.WriteByte(0xd2)
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(int32(i))))
+	default:
+		e.buf.WriteByte(0xd3)
+		e.buf.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	}
+}
+
+func (e *msgpackEncoder) writeUint(u uint64) {
+	switch {
+	case u <= 0x7f:
+		e.buf.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		e.buf.WriteByte(0xcc)
+		e.buf.WriteByte(byte(u))
+	case u <= math.MaxUint16:
+		e.buf.WriteByte(0xcd)
+		e.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(u)))
+	case u <= math.MaxUint32:
+		e.buf.WriteByte(0xce)
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(u)))
+	default:
+		e.buf.WriteByte(0xcf)
+		e.buf.Write(binary.BigEndian.AppendUint64(nil, u))
+	}
+}
+
+func (e *msgpackEncoder) writeString(s string) {
+	if len(s) < 32 {
+		e.buf.WriteByte(0xa0 | byte(len(s)))
+	} else {
+		e.writeLen(len(s), 0, 0, 0xda, 0xdb)
+	}
+	e.buf.WriteString(s)
+}
+
+func (e *msgpackEncoder) writeBinary(b []byte) {
+	if len(b) <= math.MaxUint8 {
+		e.buf.WriteByte(0xc4)
+		e.buf.WriteByte(byte(len(b)))
+	} else {
+		e.writeLen(len(b), 0, 0, 0xc5, 0xc6)
+	}
+	e.buf.Write(b)
+}
+
+func (e *msgpackEncoder) writeMapHeader(n int) {
+	e.writeLen(n, 0x80, 16, 0xde, 0xdf)
+}
+
+// writeLen writes a length with the fix format (if n < fixLimit), the 16 bit or the 32 bit format.
+func (e *msgpackEncoder) writeLen(n int, fixFormat byte, fixLimit int, format16 byte, format32 byte) {
+	switch {
+	case n < fixLimit:
+		e.buf.WriteByte(fixFormat | byte(n))
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(format16)
+		e.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		e.buf.WriteByte(format32)
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+// writeTimestamp writes the timestamp extension in its shortest form.
+func (e *msgpackEncoder) writeTimestamp(t time.Time) {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case sec >= 0 && sec>>34 == 0 && nsec == 0 && sec <= math.MaxUint32:
+		e.buf.Write([]byte{0xd6, 0xff})
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(sec)))
+	case sec >= 0 && sec>>34 == 0:
+		e.buf.Write([]byte{0xd7, 0xff})
+		e.buf.Write(binary.BigEndian.AppendUint64(nil, nsec<<34|uint64(sec)))
+	default:
+		e.buf.Write([]byte{0xc7, 12, 0xff})
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(nsec)))
+		e.buf.Write(binary.BigEndian.AppendUint64(nil, uint64(sec)))
+	}
+}
+
+// decodeMsgPack decodes the next value of r, nested depth arrays and maps deep, into v, which must be settable.
+func decodeMsgPack(r msgpackReader, v reflect.Value, naming FieldNaming, depth int) error {
+	if depth > msgpackMaxDepth {
+		return errMsgPackDepth
+	}
+	head, err := r.ReadByte()
+	if err != nil {
+		return noEOF(err)
+	}
+	if head == 0xc0 {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	return decodeMsgPackHead(r, head, v, naming, depth)
+}
+
+// decodeMsgPackHead decodes the value starting with the already read format byte head into v.
+func decodeMsgPackHead(r msgpackReader, head byte, v reflect.Value, naming FieldNaming, depth int) error {
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeMsgPackHead(r, head, elem.Elem(), naming, depth); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		generic, err := decodeMsgPackAny(r, head, depth)
+		if err != nil {
+			return err
+		}
+		if generic != nil {
+			v.Set(reflect.ValueOf(generic))
+		}
+		return nil
+	}
+	if v.Type() == timeType {
+		if s, ok, err := readMsgPackString(r, head); ok || err != nil {
+			if err != nil {
+				return err
+			}
+			parsed, err := parseStringValue(s, timeType)
+			if err != nil {
+				return err
+			}
+			v.Set(parsed)
+			return nil
+		}
+		t, err := readMsgPackTimestamp(r, head)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if v.Kind() != reflect.String && reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		s, ok, err := readMsgPackString(r, head)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return msgpackMismatch(head, v.Type())
+		}
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if head != 0xc2 && head != 0xc3 {
+			return msgpackMismatch(head, v.Type())
+		}
+		v.SetBool(head == 0xc3)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, err := readMsgPackNumber(r, head)
+		if err != nil {
+			return err
+		}
+		var i int64
+		switch n := number.(type) {
+		case int64:
+			i = n
+		case uint64:
+			if n > math.MaxInt64 {
+				return fmt.Errorf("%w: %d overflows %v", ErrInvalidMsgPack, n, v.Type())
+			}
+			i = int64(n)
+		default:
+			return msgpackMismatch(head, v.Type())
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("%w: %d overflows %v", ErrInvalidMsgPack, i, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		number, err := readMsgPackNumber(r, head)
+		if err != nil {
+			return err
+		}
+		var u uint64
+		switch n := number.(type) {
+		case uint64:
+			u = n
+		case int64:
+			if n < 0 {
+				return fmt.Errorf("%w: %d overflows %v", ErrInvalidMsgPack, n, v.Type())
+			}
+			u = uint64(n)
+		default:
+			return msgpackMismatch(head, v.Type())
+		}
+		if v.OverflowUint(u) {
+			return fmt.Errorf("%w: %d overflows %v", ErrInvalidMsgPack, u, v.Type())
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		number, err := readMsgPackNumber(r, head)
+		if err != nil {
+			return err
+		}
+		switch n := number.(type) {
+		case float64:
+			v.SetFloat(n)
+		case int64:
+			v.SetFloat(float64(n))
+		case uint64:
+			v.SetFloat(float64(n))
+		}
+	case reflect.String:
+		s, ok, err := readMsgPackString(r, head)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return msgpackMismatch(head, v.Type())
+		}
+		v.SetString(s)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if b, ok, err := readMsgPackBinary(r, head); ok || err != nil {
+				if err == nil {
+					v.SetBytes(b)
+				}
+				return err
+			}
+		}
+		n, ok, err := readMsgPackArrayLen(r, head)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return msgpackMismatch(head, v.Type())
+		}
+		slice := reflect.MakeSlice(v.Type(), 0, min(n, msgpackPreallocLimit))
+		for i := 0; i < n; i++ {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeMsgPack(r, elem, naming, depth+1); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		v.Set(slice)
+	case reflect.Array:
+		n, ok, err := readMsgPackArrayLen(r, head)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return msgpackMismatch(head, v.Type())
+		}
+		for i := 0; i < n; i++ {
+			if i >= v.Len() {
+				if err := readMsgPackRaw(r, io.Discard, depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := decodeMsgPack(r, v.Index(i), naming, depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		n, ok, err := readMsgPackMapLen(r, head)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return msgpackMismatch(head, v.Type())
+		}
+		m := reflect.MakeMapWithSize(v.Type(), min(n, msgpackPreallocLimit))
+		for i := 0; i < n; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := decodeMsgPack(r, key, naming, depth+1); err != nil {
+				return err
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeMsgPack(r, elem, naming, depth+1); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, elem)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		n, ok, err := readMsgPackMapLen(r, head)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return msgpackMismatch(head, v.Type())
+		}
+		plans := fieldPlans(v.Type(), naming)
+		for i := 0; i < n; i++ {
+			var key string
+			if err := decodeMsgPack(r, reflect.ValueOf(&key).Elem(), naming, depth+1); err != nil {
+				return err
+			}
+			plan := findFieldPlan(plans, key, false)
+			if plan == nil {
+				if err := readMsgPackRaw(r, io.Discard, depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := decodeMsgPack(r, fieldByIndexAlloc(v, plan.index), naming, depth+1); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: %v", ErrUnsupportedFieldType, v.Type())
+	}
+	return nil
+}
+
+// decodeMsgPackAny decodes a value without a target type: maps become map[string]any (other keys
+// are formatted with fmt), arrays []any, integers int64 (or uint64 above math.MaxInt64), floats
+// float64, strings string, binaries []byte and timestamps time.Time.
+func decodeMsgPackAny(r msgpackReader, head byte, depth int) (any, error) {
+	switch {
+	case head == 0xc0:
+		return nil, nil
+	case head == 0xc2 || head == 0xc3:
+		return head == 0xc3, nil
+	}
+	if s, ok, err := readMsgPackString(r, head); ok || err != nil {
+		return s, err
+	}
+	if b, ok, err := readMsgPackBinary(r, head); ok || err != nil {
+		return b, err
+	}
+	if n, ok, err := readMsgPackArrayLen(r, head); ok || err != nil {
+		if err != nil {
+			return nil, err
+		}
+		slice := make([]any, 0, min(n, msgpackPreallocLimit))
+		for i := 0; i < n; i++ {
+			var elem any
+			if err := decodeMsgPack(r, reflect.ValueOf(&elem).Elem(), GoFieldNames, depth+1); err != nil {
+				return nil, err
+			}
+			slice = append(slice, elem)
+		}
+		return slice, nil
+	}
+	if n, ok, err := readMsgPackMapLen(r, head); ok || err != nil {
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]any, min(n, msgpackPreallocLimit))
+		for i := 0; i < n; i++ {
+			var key, elem any
+			if err := decodeMsgPack(r, reflect.ValueOf(&key).Elem(), GoFieldNames, depth+1); err != nil {
+				return nil, err
+			}
+			if err := decodeMsgPack(r, reflect.ValueOf(&elem).Elem(), GoFieldNames, depth+1); err != nil {
+				return nil, err
+			}
+			keyString, ok := key.(string)
+			if !ok {
+				keyString = fmt.Sprint(key)
+			}
+			m[keyString] = elem
+		}
+		return m, nil
+	}
+	if isMsgPackExt(head) {
+		return readMsgPackTimestamp(r, head)
+	}
+	return readMsgPackNumber(r, head)
+}
+
+func readMsgPackNumber(r msgpackReader, head byte) (any, error) {
+	switch {
+	case head <= 0x7f:
+		return int64(head), nil
+	case head >= 0xe0:
+		return int64(int8(head)), nil
+	}
+	switch head {
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		b, err := readMsgPackBytes(r, 1<<(head-0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return readBigEndian(b), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (head - 0xd0)
+		b, err := readMsgPackBytes(r, size)
+		if err != nil {
+			return nil, err
+		}
+		// sign-extend
+		shift := 64 - 8*size
+		return int64(readBigEndian(b)<<shift) >> shift, nil
+	case 0xca:
+		b, err := readMsgPackBytes(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb:
+		b, err := readMsgPackBytes(r, 8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	}
+	return nil, fmt.Errorf("%w: unexpected format 0x%02x", ErrInvalidMsgPack, head)
+}
+
+func readMsgPackString(r msgpackReader, head byte) (string, bool, error) {
+	var n int
+	var err error
+	switch {
+	case head >= 0xa0 && head <= 0xbf:
+		n = int(head & 0x1f)
+	case head == 0xd9 || head == 0xda || head == 0xdb:
+		n, err = readMsgPackLen(r, 1<<(head-0xd9))
+	default:
+		return "", false, nil
+	}
+	if err != nil {
+		return "", true, err
+	}
+	b, err := readMsgPackBytes(r, n)
+	return string(b), true, err
+}
+
+func readMsgPackBinary(r msgpackReader, head byte) ([]byte, bool, error) {
+	if head < 0xc4 || head > 0xc6 {
+		return nil, false, nil
+	}
+	n, err := readMsgPackLen(r, 1<<(head-0xc4))
+	if err != nil {
+		return nil, true, err
+	}
+	b, err := readMsgPackBytes(r, n)
+	return b, true, err
+}
+
+func readMsgPackArrayLen(r msgpackReader, head byte) (int, bool, error) {
+	switch {
+	case head >= 0x90 && head <= 0x9f:
+		return int(head & 0x0f), true, nil
+	case head == 0xdc:
+		n, err := readMsgPackLen(r, 2)
+		return n, true, err
+	case head == 0xdd:
+		n, err := readMsgPackLen(r, 4)
+		return n, true, err
+	}
+	return 0, false, nil
+}
+
+func readMsgPackMapLen(r msgpackReader, head byte) (int, bool, error) {
+	switch {
+	case head >= 0x80 && head <= 0x8f:
+		return int(head & 0x0f), true, nil
+	case head == 0xde:
+		n, err := readMsgPackLen(r, 2)
+		return n, true, err
+	case head == 0xdf:
+		n, err := readMsgPackLen(r, 4)
+		return n, true, err
+	}
+	return 0, false, nil
+}
+
+func isMsgPackExt(head byte) bool {
+	return (head >= 0xd4 && head <= 0xd8) || (head >= 0xc7 && head <= 0xc9)
+}
+
+// readMsgPackExt reads an extension value and returns its type and data.
+func readMsgPackExt(r msgpackReader, head byte) (int8, []byte, error) {
+	var n int
+	var err error
+	switch {
+	case head >= 0xd4 && head <= 0xd8:
+		n = 1 << (head - 0xd4)
+	case head >= 0xc7 && head <= 0xc9:
+		n, err = readMsgPackLen(r, 1<<(head-0xc7))
+	default:
+		return 0, nil, fmt.Errorf("%w: expected an extension, got format 0x%02x", ErrInvalidMsgPack, head)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	extType, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, noEOF(err)
+	}
+	data, err := readMsgPackBytes(r, n)
+	return int8(extType), data, err
+}
+
+func readMsgPackTimestamp(r msgpackReader, head byte) (time.Time, error) {
+	extType, data, err := readMsgPackExt(r, head)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if extType != msgpackTimestampExt {
+		return time.Time{}, fmt.Errorf("%w: unsupported extension type %d", ErrInvalidMsgPack, extType)
+	}
+	switch len(data) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)).UTC(), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data[:4])
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		return time.Unix(sec, int64(nsec)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("%w: invalid timestamp length %d", ErrInvalidMsgPack, len(data))
+}
+
+// readMsgPackRaw copies the next complete value of r, nested depth arrays and maps deep, to w.
+func readMsgPackRaw(r msgpackReader, w io.Writer, depth int) error {
+	if depth > msgpackMaxDepth {
+		return errMsgPackDepth
+	}
+	head, err := r.ReadByte()
+	if err != nil {
+		return noEOF(err)
+	}
+	if _, err := w.Write([]byte{head}); err != nil {
+		return err
+	}
+	copyN := func(n int64) error {
+		_, err := io.CopyN(w, r, n)
+		return noEOF(err)
+	}
+	copyLen := func(size int) (int, error) {
+		b, err := readMsgPackBytes(r, size)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := w.Write(b); err != nil {
+			return 0, err
+		}
+		return int(readBigEndian(b)), nil
+	}
+	copyValues := func(n int) error {
+		for i := 0; i < n; i++ {
+			if err := readMsgPackRaw(r, w, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch {
+	case head <= 0x7f || head >= 0xe0 || head == 0xc0 || head == 0xc2 || head == 0xc3:
+		return nil
+	case head >= 0x80 && head <= 0x8f:
+		return copyValues(2 * int(head&0x0f))
+	case head >= 0x90 && head <= 0x9f:
+		return copyValues(int(head & 0x0f))
+	case head >= 0xa0 && head <= 0xbf:
+		return copyN(int64(head & 0x1f))
+	}
+	switch head {
+	case 0xcc, 0xd0:
+		return copyN(1)
+	case 0xcd, 0xd1:
+		return copyN(2)
+	case 0xca, 0xce, 0xd2:
+		return copyN(4)
+	case 0xcb, 0xcf, 0xd3:
+		return copyN(8)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return copyN(1 + int64(1)<<(head-0xd4))
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb:
+		size := 1 << (head - 0xc4)
+		if head >= 0xd9 {
+			size = 1 << (head - 0xd9)
+		}
+		n, err := copyLen(size)
+		if err != nil {
+			return err
+		}
+		return copyN(int64(n))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := copyLen(1 << (head - 0xc7))
+		if err != nil {
+			return err
+		}
+		return copyN(int64(n) + 1)
+	case 0xdc, 0xdd:
+		n, err := copyLen(2 << (head - 0xdc))
+		if err != nil {
+			return err
+		}
+		return copyValues(n)
+	case 0xde, 0xdf:
+		n, err := copyLen(2 << (head - 0xde))
+		if err != nil {
+			return err
+		}
+		return copyValues(2 * n)
+	}
+	return fmt.Errorf("%w: unexpected format 0x%02x", ErrInvalidMsgPack, head)
+}
+
+func readMsgPackLen(r msgpackReader, size int) (int, error) {
+	b, err := readMsgPackBytes(r, size)
+	if err != nil {
+		return 0, err
+	}
+	return int(readBigEndian(b)), nil
+}
+
+// readMsgPackBytes reads n bytes, growing the buffer as data arrives for large n.
+func readMsgPackBytes(r msgpackReader, n int) ([]byte, error) {
+	if n <= msgpackPreallocLimit {
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		return b, noEOF(err)
+	}
+	var buf bytes.Buffer
+	_, err := io.CopyN(&buf, r, int64(n))
+	return buf.Bytes(), noEOF(err)
+}
+
+func readBigEndian(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func msgpackMismatch(head byte, t reflect.Type) error {
+	return fmt.Errorf("%w: cannot decode format 0x%02x into %v", ErrInvalidMsgPack, head, t)
+}
+
+// noEOF turns io.EOF in the middle of a value into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
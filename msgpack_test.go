@@ -0,0 +1,169 @@
+package struccy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type msgpackDevice struct {
+	ID       string            `json:"id" readxs:"*" writexs:"admin"`
+	Name     string            `json:"name" readxs:"*" writexs:"owner,admin" validate:"required"`
+	Port     uint16            `json:"port" readxs:"*" writexs:"owner"`
+	Offset   int64             `json:"offset" readxs:"*" writexs:"owner"`
+	Load     float64           `json:"load" readxs:"*" writexs:"owner"`
+	Enabled  bool              `json:"enabled" readxs:"*" writexs:"owner"`
+	Tags     []string          `json:"tags" readxs:"*" writexs:"owner"`
+	Labels   map[string]string `json:"labels" readxs:"*" writexs:"owner"`
+	Firmware []byte            `json:"firmware" readxs:"*" writexs:"owner"`
+	SeenAt   time.Time         `json:"seenAt" readxs:"*" writexs:"owner"`
+	Location *msgpackLocation  `json:"location" readxs:"*" writexs:"owner"`
+	Secret   string            `json:"secret,omitempty" readxs:"admin" writexs:"admin"`
+}
+
+type msgpackLocation struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+func newMsgPackDevice() *msgpackDevice {
+	return &msgpackDevice{
+		ID:       "d-1",
+		Name:     "sensor",
+		Port:     8080,
+		Offset:   -40000,
+		Load:     0.75,
+		Enabled:  true,
+		Tags:     []string{"a", "b"},
+		Labels:   map[string]string{"room": "lab"},
+		Firmware: []byte{1, 2, 3},
+		SeenAt:   time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC),
+		Location: &msgpackLocation{Lat: 52.5, Lng: 13.4},
+		Secret:   "s3cr3t",
+	}
+}
+
+func TestEncodeMsgPackWithReadXS(t *testing.T) {
+	var buf bytes.Buffer
+	err := EncodeMsgPackWithReadXS(&buf, &msgpackDevice{ID: "x", Port: 300, Offset: -1}, []string{"owner"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	data := buf.Bytes()
+	// fixmap with the 11 readable fields, starting with "id": "x" and ending with "location": nil
+	assert.Equal(t, byte(0x8b), data[0])
+	assert.Equal(t, []byte{0xa2, 'i', 'd', 0xa1, 'x'}, data[1:6])
+	assert.Equal(t, []byte{0xa4, 'p', 'o', 'r', 't', 0xcd, 0x01, 0x2c}, data[12:20])
+	assert.Equal(t, []byte{0xa6, 'o', 'f', 'f', 's', 'e', 't', 0xff}, data[20:28])
+	assert.Equal(t, byte(0xc0), data[len(data)-1])
+
+	// the same field set as StructToMapFieldsWithReadXS
+	device := newMsgPackDevice()
+	for _, roles := range [][]string{{"owner"}, {"admin"}} {
+		buf.Reset()
+		assert.NoError(t, EncodeMsgPackWithReadXS(&buf, device, roles))
+		var decoded map[string]any
+		assert.NoError(t, decodeMsgPack(bytes.NewReader(buf.Bytes()), reflect.ValueOf(&decoded).Elem(), GoFieldNames, 0))
+		fields, err := StructToMapFieldsWithReadXS(device, roles)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, sortedMapKeys(fields), sortedMapKeys(decoded))
+		assert.Equal(t, map[string]any{"Lat": 52.5, "Lng": 13.4}, decoded["Location"])
+		assert.Equal(t, device.SeenAt, decoded["SeenAt"])
+	}
+
+	err = EncodeMsgPackWithReadXS(&buf, *device, nil)
+	assert.Equal(t, ErrInvalidStructPointer, err)
+
+	err = EncodeMsgPackWithReadXS(&buf, &struct {
+		C chan int `readxs:"*"`
+	}{}, nil)
+	assert.ErrorIs(t, err, ErrUnsupportedFieldType)
+}
+
+func TestDecodeMsgPackWithWriteXS(t *testing.T) {
+	var buf bytes.Buffer
+	source := newMsgPackDevice()
+	source.ID = "hacked"
+	assert.NoError(t, EncodeMsgPackWithReadXS(&buf, source, []string{"admin"}, WithFieldNaming(JSONFieldNames)))
+
+	device := &msgpackDevice{ID: "d-9", Name: "old", Secret: "keep"}
+	err := DecodeMsgPackWithWriteXS(&buf, device, []string{"owner"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	expected := newMsgPackDevice()
+	expected.ID = "d-9"
+	expected.Secret = "keep"
+	assert.Equal(t, expected, device)
+
+	// values that do not fit are reported without stopping the decoding
+	buf.Reset()
+	assert.NoError(t, EncodeMsgPackWithReadXS(&buf, &struct {
+		Port    int64  `readxs:"*"`
+		Enabled string `readxs:"*"`
+		Name    string `readxs:"*"`
+	}{Port: 70000, Enabled: "yes", Name: ""}, nil))
+	device = &msgpackDevice{Name: "old"}
+	err = DecodeMsgPackWithWriteXS(&buf, device, []string{"owner"})
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 3, fieldErrs.Len())
+	assert.Equal(t, "Port", fieldErrs.Errors[0].Path)
+	assert.ErrorIs(t, fieldErrs.Errors[0], ErrInvalidFieldValue)
+	assert.Equal(t, "Enabled", fieldErrs.Errors[1].Path)
+	assert.ErrorIs(t, err, ErrValidationFailed)
+	assert.Equal(t, uint16(0), device.Port)
+
+	err = DecodeMsgPackWithWriteXS(bytes.NewReader([]byte{0x92, 0x01, 0x02}), device, nil)
+	assert.ErrorIs(t, err, ErrInvalidMsgPack)
+	err = DecodeMsgPackWithWriteXS(bytes.NewReader([]byte{0x81, 0xa2, 'i'}), device, nil)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	err = DecodeMsgPackWithWriteXS(bytes.NewReader(nil), device, nil)
+	assert.Equal(t, io.EOF, err)
+	// a forged length does not allocate before the data arrives
+	err = DecodeMsgPackWithWriteXS(bytes.NewReader([]byte{0x81, 0xa1, 'x', 0xc6, 0xff, 0xff, 0xff, 0xff}), device, nil)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestDecodeMsgPackMaxDepth(t *testing.T) {
+	nested := func(depth int) []byte {
+		data := append([]byte{0x81, 0xa4, 'd', 'a', 't', 'a'}, bytes.Repeat([]byte{0x91}, depth)...)
+		return append(data, 0x01)
+	}
+	type document struct {
+		Data any `json:"data" writexs:"*"`
+	}
+
+	doc := &document{}
+	assert.NoError(t, DecodeMsgPackWithWriteXS(bytes.NewReader(nested(100)), doc, nil, WithFieldNaming(JSONFieldNames)))
+	assert.IsType(t, []any{}, doc.Data)
+
+	// too deeply nested values fail instead of overflowing the stack, whether they are decoded or skipped
+	err := DecodeMsgPackWithWriteXS(bytes.NewReader(nested(msgpackMaxDepth+1)), doc, nil, WithFieldNaming(JSONFieldNames))
+	assert.ErrorIs(t, err, ErrInvalidMsgPack)
+	err = DecodeMsgPackWithWriteXS(bytes.NewReader(nested(msgpackMaxDepth+1)), &msgpackDevice{}, nil)
+	assert.ErrorIs(t, err, ErrInvalidMsgPack)
+}
+
+func TestMsgPackStream(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := NewMsgPackEncoder(&buf)
+	for _, name := range []string{"one", "two"} {
+		assert.NoError(t, encoder.EncodeWithReadXS(&msgpackDevice{Name: name, SeenAt: time.Unix(1<<35, 0).UTC()}, []string{"owner"}))
+	}
+
+	decoder := NewMsgPackDecoder(io.MultiReader(&buf))
+	var names []string
+	for {
+		device := &msgpackDevice{}
+		err := decoder.DecodeWithWriteXS(device, []string{"owner"})
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, time.Unix(1<<35, 0).UTC(), device.SeenAt)
+		names = append(names, device.Name)
+	}
+	assert.Equal(t, []string{"one", "two"}, names)
+}
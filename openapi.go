@@ -0,0 +1,119 @@
+package struccy
+
+import "reflect"
+
+const openAPISchemaRefPrefix = "#/components/schemas/"
+
+// OpenAPIAudience is a group of callers sharing the same roles, e.g. admins, the public or the owner
+// of an entity. The registry generates one schema variant per registered type, audience and operation.
+type OpenAPIAudience struct {
+	Name  string      // used in the schema names, e.g. "Admin" for `UserAdminRead`
+	Roles []string    // the roles the readxs/writexs tags are checked against
+	Ops   []Operation // the generated variants, OpRead and OpWrite if empty
+}
+
+// OpenAPIComponents is the `components` object of an OpenAPI 3.1 document.
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// OpenAPIRegistry generates OpenAPI 3.1 component schemas for the registered struct types, with one
+// variant per audience and operation named after the type, the audience and the operation
+// (`UserAdminRead`, `UserPublicRead`, `UserSelfWrite`), so they always follow the struct tags.
+//
+// The variants have the properties JSONSchema returns for the audience's roles; nested structs are
+// shared components named by their Go type name (their role-specific `validate` rules are evaluated
+// for the first audience using them). OpenAPI 3.1 uses JSON Schema Draft 2020-12, so the schemas
+// are used as they are.
+type OpenAPIRegistry struct {
+	audiences []OpenAPIAudience
+	types     []openAPIType
+}
+
+type openAPIType struct {
+	name       string
+	structType reflect.Type
+}
+
+// NewOpenAPIRegistry returns a registry generating variants for the given audiences.
+func NewOpenAPIRegistry(audiences ...OpenAPIAudience) *OpenAPIRegistry {
+	return &OpenAPIRegistry{audiences: audiences}
+}
+
+// Register adds the type of the struct pointer, named by its Go type name.
+//
+// If the provided `structPtr` is not a pointer to a struct, the function returns
+// an error (`ErrInvalidStructPointer`).
+func (r *OpenAPIRegistry) Register(structPtr any) error {
+	structValue := reflect.ValueOf(structPtr)
+	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	return r.RegisterAs(structValue.Elem().Type().Name(), structPtr)
+}
+
+// RegisterAs adds the type of the struct pointer under the given name, e.g. to register
+// anonymous types or to resolve clashing type names of different packages.
+//
+// If the provided `structPtr` is not a pointer to a struct, the function returns
+// an error (`ErrInvalidStructPointer`).
+func (r *OpenAPIRegistry) RegisterAs(name string, structPtr any) error {
+	structValue := reflect.ValueOf(structPtr)
+	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	r.types = append(r.types, openAPIType{name: name, structType: structValue.Elem().Type()})
+	return nil
+}
+
+// SchemaName returns the component name of the variant of a registered type, e.g. `UserAdminRead`.
+func (r *OpenAPIRegistry) SchemaName(typeName string, audience string, op Operation) string {
+	suffix := "Read"
+	if op == OpWrite {
+		suffix = "Write"
+	}
+	return typeName + audience + suffix
+}
+
+// SchemaRef returns the reference to the variant of a registered type, e.g. `#/components/schemas/UserAdminRead`,
+// to be used in the request bodies and responses of the paths.
+func (r *OpenAPIRegistry) SchemaRef(typeName string, audience string, op Operation) string {
+	return openAPISchemaRefPrefix + r.SchemaName(typeName, audience, op)
+}
+
+// Components generates the component schemas of all variants and the nested structs they use.
+func (r *OpenAPIRegistry) Components() (*OpenAPIComponents, error) {
+	// all generators share the nested struct schemas; the variant names are reserved first,
+	// so nested structs of the same name are numbered instead of replacing a variant
+	defs := make(map[string]*Schema)
+	names := make(map[reflect.Type]string)
+	for _, registered := range r.types {
+		for _, audience := range r.audiences {
+			for _, op := range audienceOps(audience) {
+				defs[r.SchemaName(registered.name, audience.Name, op)] = &Schema{}
+			}
+		}
+	}
+
+	for _, registered := range r.types {
+		for _, audience := range r.audiences {
+			generator := newSchemaGenerator(audience.Roles, openAPISchemaRefPrefix)
+			generator.defs, generator.names = defs, names
+			for _, op := range audienceOps(audience) {
+				schema, err := generator.accessSchema(registered.structType, op)
+				if err != nil {
+					return nil, err
+				}
+				defs[r.SchemaName(registered.name, audience.Name, op)] = schema
+			}
+		}
+	}
+	return &OpenAPIComponents{Schemas: defs}, nil
+}
+
+func audienceOps(audience OpenAPIAudience) []Operation {
+	if len(audience.Ops) == 0 {
+		return []Operation{OpRead, OpWrite}
+	}
+	return audience.Ops
+}
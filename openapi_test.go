@@ -0,0 +1,55 @@
+package struccy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type openAPIUser struct {
+	ID       string          `json:"id" readxs:"*" writexs:"admin"`
+	Name     string          `json:"name" readxs:"*" writexs:"self,admin" validate:"required"`
+	Email    string          `json:"email" readxs:"self,admin" writexs:"self,admin" validate:"email"`
+	Role     string          `json:"role" readxs:"admin" writexs:"admin"`
+	Address  *schemaAddress  `json:"address" readxs:"self,admin" writexs:"self"`
+	Category *schemaCategory `json:"category" readxs:"admin" writexs:"admin"`
+}
+
+func TestOpenAPIRegistry(t *testing.T) {
+	registry := NewOpenAPIRegistry(
+		OpenAPIAudience{Name: "Admin", Roles: []string{"admin"}},
+		OpenAPIAudience{Name: "Public", Roles: []string{"guest"}, Ops: []Operation{OpRead}},
+		OpenAPIAudience{Name: "Self", Roles: []string{"self"}, Ops: []Operation{OpWrite}},
+	)
+	assert.NoError(t, registry.Register(&openAPIUser{}))
+	assert.NoError(t, registry.RegisterAs("Product", &schemaProduct{}))
+	assert.Equal(t, ErrInvalidStructPointer, registry.Register(openAPIUser{}))
+
+	components, err := registry.Components()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"ProductAdminRead", "ProductAdminWrite", "ProductPublicRead", "ProductSelfWrite",
+		"openAPIUserAdminRead", "openAPIUserAdminWrite", "openAPIUserPublicRead", "openAPIUserSelfWrite",
+		"schemaAddress", "schemaCategory",
+	}, sortedMapKeys(components.Schemas))
+
+	propertyNames := func(name string) []string {
+		return sortedMapKeys(components.Schemas[name].Properties)
+	}
+	assert.Equal(t, []string{"address", "category", "email", "id", "name", "role"}, propertyNames("openAPIUserAdminRead"))
+	assert.Equal(t, []string{"category", "email", "id", "name", "role"}, propertyNames("openAPIUserAdminWrite"))
+	assert.Equal(t, []string{"id", "name"}, propertyNames("openAPIUserPublicRead"))
+	assert.Equal(t, []string{"address", "email", "name"}, propertyNames("openAPIUserSelfWrite"))
+	assert.Equal(t, []string{"name"}, components.Schemas["openAPIUserSelfWrite"].Required)
+
+	address, err := json.Marshal(components.Schemas["openAPIUserSelfWrite"].Properties["address"])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"anyOf": [{"$ref": "#/components/schemas/schemaAddress"}, {"type": "null"}]}`, string(address))
+	assert.Equal(t, "#/components/schemas/openAPIUserAdminRead", registry.SchemaRef("openAPIUser", "Admin", OpRead))
+
+	// the nested structs of all types share their components
+	assert.Contains(t, propertyNames("ProductAdminWrite"), "cost")
+	assert.Empty(t, propertyNames("ProductSelfWrite"))
+	assert.Equal(t, "#/components/schemas/schemaAddress", components.Schemas["ProductAdminRead"].Properties["warehouse"].Ref)
+}
@@ -0,0 +1,100 @@
+package struccy
+
+import "os"
+
+// MergeOption configures optional behavior of MergeStructUpdateTo, MergeMapStringFieldsToStruct and UpdateStructFields.
+type MergeOption func(*mergeOptions)
+
+type mergeOptions struct {
+	applyDefaults    bool
+	checkVersion     bool
+	incrementVersion bool
+}
+
+func newMergeOptions(opts []MergeOption) *mergeOptions {
+	options := &mergeOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithDefaults fills the `default` tag value into every zero-valued field that the caller
+// either did not send or was not allowed to write (see ApplyDefaults for the tag format).
+func WithDefaults() MergeOption {
+	return func(options *mergeOptions) {
+		options.applyDefaults = true
+	}
+}
+
+// WithVersionCheck requires the update to carry the current value of the field tagged `version`. Otherwise
+// the merge changes nothing and returns a *VersionConflictError listing the fields the update would change.
+func WithVersionCheck() MergeOption {
+	return func(options *mergeOptions) {
+		options.checkVersion = true
+	}
+}
+
+// WithVersionIncrement increments the field tagged `version` after a successful merge.
+func WithVersionIncrement() MergeOption {
+	return func(options *mergeOptions) {
+		options.incrementVersion = true
+	}
+}
+
+// ConvertOption configures how structs are converted to and from maps, JSON and the other supported formats.
+type ConvertOption func(*convertOptions)
+
+type convertOptions struct {
+	naming      FieldNaming
+	namingSet   bool
+	comma       rune
+	parallelism int
+}
+
+func newConvertOptions(opts []ConvertOption) *convertOptions {
+	options := &convertOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithFieldNaming selects the map keys (see FieldNaming). All namings but GoFieldNames
+// also honor the json tag options, so the result matches encoding/json minus the forbidden fields.
+func WithFieldNaming(naming FieldNaming) ConvertOption {
+	return func(options *convertOptions) {
+		options.naming = naming
+		options.namingSet = true
+	}
+}
+
+// WithComma sets the field delimiter used by WriteCSV and ReadCSV (default ',').
+func WithComma(comma rune) ConvertOption {
+	return func(options *convertOptions) {
+		options.comma = comma
+	}
+}
+
+// EnvOption configures optional behavior of LoadEnv.
+type EnvOption func(*envOptions)
+
+type envOptions struct {
+	lookup func(key string) (string, bool)
+}
+
+func newEnvOptions(opts []EnvOption) *envOptions {
+	options := &envOptions{lookup: os.LookupEnv}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithLookupEnv replaces os.LookupEnv as the source of the variables, e.g. for tests
+// or to read from a parsed .env file.
+func WithLookupEnv(lookup func(key string) (string, bool)) EnvOption {
+	return func(options *envOptions) {
+		options.lookup = lookup
+	}
+}
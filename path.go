@@ -0,0 +1,403 @@
+package struccy
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Operation selects whether read (`readxs`) or write (`writexs`) access is checked.
+type Operation int
+
+const (
+	OpRead Operation = iota
+	OpWrite
+)
+
+func (op Operation) String() string {
+	if op == OpWrite {
+		return "write"
+	}
+	return "read"
+}
+
+// tagName returns the access tag checked for the operation.
+func (op Operation) tagName() string {
+	if op == OpWrite {
+		return tagNameWriteXS
+	}
+	return tagNameReadXS
+}
+
+// accessAllowed checks the access tag value of the operation, splitting off the `writexs` markers for writes.
+func (op Operation) accessAllowed(roles []string, tagValue string) bool {
+	if op == OpWrite {
+		return IsWriteAccessAllowed(roles, tagValue)
+	}
+	return IsFieldAccessAllowed(roles, tagValue)
+}
+
+// conditionTagName returns the condition tag evaluated for the operation.
+func (op Operation) conditionTagName() string {
+	if op == OpWrite {
+		return tagNameWriteIf
+	}
+	return tagNameReadIf
+}
+
+var (
+	ErrInvalidPath          = errors.New("invalid path")
+	ErrIndexOutOfRange      = errors.New("index out of range")
+	ErrUnauthorizedFieldGet = errors.New("unauthorized field get")
+)
+
+// SetPath sets the value addressed by path on the struct pointer entity.
+//
+// The path is either dot notation (`address.geo.lat`, `tags.0`, `tags[0]`) or a JSON Pointer
+// (`/address/geo/lat`, with `~1` for `/` and `~0` for `~`). Struct fields can be addressed by
+// their Go name or their JSON name. Nil pointers and maps along the path are allocated,
+// slice elements are addressed by index (`-` appends a new element) and map entries by key.
+// This only happens once the value was set, so the entity is left unchanged if SetPath fails.
+//
+// Every struct field along the path, including the embedded structs fields are promoted through,
+// must allow writing (`writexs`) for the roles, otherwise
+// ErrUnauthorizedFieldSet is returned, or ErrFieldReadOnly, ErrFieldSystemManaged or ErrFieldImmutable
+// for fields with these markers. The value is converted with the same rules as SetField.
+// Errors are returned as *FieldError carrying the resolved Go and JSON paths.
+func SetPath(entity any, path string, value any, roles []string) error {
+	rv := reflect.ValueOf(entity)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	return setPathValue(rv.Elem(), segments, value, roles, &resolvedPath{})
+}
+
+// GetPath returns the value addressed by path on the struct (pointer) entity.
+// See SetPath for the path syntax. Every struct field along the path must allow reading
+// (`readxs`) for the roles, otherwise ErrUnauthorizedFieldGet is returned.
+// A nil pointer along the path yields a nil value without error.
+func GetPath(entity any, path string, roles []string) (any, error) {
+	rv := reflect.ValueOf(entity)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, ErrInvalidStructPointer
+	}
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &resolvedPath{}
+	current := rv
+	for _, segment := range segments {
+		for current.Kind() == reflect.Ptr || current.Kind() == reflect.Interface {
+			if current.IsNil() {
+				return nil, nil
+			}
+			current = current.Elem()
+		}
+		switch current.Kind() {
+		case reflect.Struct:
+			field, ok := lookupField(current.Type(), segment)
+			if !ok || !field.IsExported() {
+				return nil, resolved.fieldError(segment, segment, ErrFieldNotFound)
+			}
+			resolved.push(field.Name, jsonFieldName(field))
+			if !fieldAccessAllowed(current, field, roles, OpRead) || !promotedAllowed(current, field, roles, OpRead) {
+				return nil, resolved.error(ErrUnauthorizedFieldGet, field.Type, nil, nil)
+			}
+			current, err = current.FieldByIndexErr(field.Index)
+			if err != nil {
+				// promoted through a nil embedded pointer
+				return nil, nil
+			}
+		case reflect.Slice, reflect.Array:
+			index, err := strconv.Atoi(segment)
+			resolved.push(segment, segment)
+			if err != nil {
+				return nil, resolved.error(ErrInvalidPath, nil, nil, segment)
+			}
+			if index < 0 || index >= current.Len() {
+				return nil, resolved.error(ErrIndexOutOfRange, nil, nil, index)
+			}
+			current = current.Index(index)
+		case reflect.Map:
+			key, err := mapKey(segment, current.Type().Key())
+			resolved.push(segment, segment)
+			if err != nil {
+				return nil, resolved.error(ErrInvalidPath, current.Type().Key(), nil, segment)
+			}
+			entry := current.MapIndex(key)
+			if !entry.IsValid() {
+				return nil, resolved.error(ErrFieldNotFound, nil, nil, segment)
+			}
+			current = entry
+		default:
+			return nil, resolved.fieldError(segment, segment, ErrInvalidPath)
+		}
+	}
+	return valueInterface(current), nil
+}
+
+// IsAllowedPath checks whether every struct field along the path allows the operation for the roles.
+// It works on the type of entity only, so nil pointers, missing map keys and slice indices do not matter.
+// Paths that cannot be resolved are not allowed. See SetPath for the path syntax.
+func IsAllowedPath(entity any, path string, roles []string, op Operation) bool {
+	typ := reflect.TypeOf(entity)
+	if typ == nil {
+		return false
+	}
+	segments, err := parsePath(path)
+	if err != nil {
+		return false
+	}
+	for _, segment := range segments {
+		typ = indirectType(typ)
+		switch typ.Kind() {
+		case reflect.Struct:
+			field, ok := lookupField(typ, segment)
+			if !ok || !field.IsExported() || !op.accessAllowed(roles, field.Tag.Get(op.tagName())) {
+				return false
+			}
+			for _, parent := range embeddedParents(typ, field.Index) {
+				if tag, tagged := parent.field.Tag.Lookup(op.tagName()); tagged && !op.accessAllowed(roles, tag) {
+					return false
+				}
+			}
+			typ = field.Type
+		case reflect.Slice, reflect.Array:
+			if _, err := strconv.Atoi(segment); err != nil && !(segment == "-" && op == OpWrite) {
+				return false
+			}
+			typ = typ.Elem()
+		case reflect.Map:
+			if _, err := mapKey(segment, typ.Key()); err != nil {
+				return false
+			}
+			typ = typ.Elem()
+		case reflect.Interface:
+			// the dynamic type is unknown, so there are no further tags to check
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func setPathValue(current reflect.Value, segments []string, value any, roles []string, resolved *resolvedPath) error {
+	if len(segments) == 0 {
+		if value == nil {
+			current.Set(reflect.Zero(current.Type()))
+			return nil
+		}
+		if current.Kind() == reflect.Ptr && !reflect.TypeOf(value).AssignableTo(current.Type()) {
+			target := reflect.New(current.Type().Elem())
+			if err := setPathValue(target.Elem(), nil, value, roles, resolved); err != nil {
+				return err
+			}
+			current.Set(target)
+			return nil
+		}
+		if err := setReflectField(current, value); err != nil {
+			return resolved.error(err, current.Type(), reflect.TypeOf(value), value)
+		}
+		return nil
+	}
+
+	// values along the path are only allocated or appended once the rest of the path was set,
+	// so a failing path leaves the entity unchanged
+	if current.Kind() == reflect.Ptr {
+		if current.IsNil() {
+			target := reflect.New(current.Type().Elem())
+			if err := setPathValue(target.Elem(), segments, value, roles, resolved); err != nil {
+				return err
+			}
+			current.Set(target)
+			return nil
+		}
+		return setPathValue(current.Elem(), segments, value, roles, resolved)
+	}
+
+	segment := segments[0]
+	switch current.Kind() {
+	case reflect.Struct:
+		field, ok := lookupField(current.Type(), segment)
+		if !ok || !field.IsExported() {
+			return resolved.fieldError(segment, segment, ErrFieldNotFound)
+		}
+		resolved.push(field.Name, jsonFieldName(field))
+		denial := writeDenial(current, field, roles)
+		if denial == nil && !promotedAllowed(current, field, roles, OpWrite) {
+			denial = ErrUnauthorizedFieldSet
+		}
+		if denial != nil {
+			return resolved.error(denial, field.Type, reflect.TypeOf(value), value)
+		}
+		return setPathField(current, field.Index, func(fieldValue reflect.Value) error {
+			return setPathValue(fieldValue, segments[1:], value, roles, resolved)
+		})
+	case reflect.Slice, reflect.Array:
+		resolved.push(segment, segment)
+		if segment == "-" && current.Kind() == reflect.Slice {
+			elem := reflect.New(current.Type().Elem()).Elem()
+			if err := setPathValue(elem, segments[1:], value, roles, resolved); err != nil {
+				return err
+			}
+			current.Set(reflect.Append(current, elem))
+			return nil
+		}
+		index, err := strconv.Atoi(segment)
+		if err != nil {
+			return resolved.error(ErrInvalidPath, nil, nil, segment)
+		}
+		if index < 0 || index >= current.Len() {
+			return resolved.error(ErrIndexOutOfRange, nil, nil, index)
+		}
+		return setPathValue(current.Index(index), segments[1:], value, roles, resolved)
+	case reflect.Map:
+		resolved.push(segment, segment)
+		key, err := mapKey(segment, current.Type().Key())
+		if err != nil {
+			return resolved.error(ErrInvalidPath, current.Type().Key(), nil, segment)
+		}
+		// map entries are not addressable, so the entry is modified on a copy and stored again
+		entry := reflect.New(current.Type().Elem()).Elem()
+		if existing := current.MapIndex(key); existing.IsValid() {
+			entry.Set(existing)
+		}
+		if err := setPathValue(entry, segments[1:], value, roles, resolved); err != nil {
+			return err
+		}
+		if current.IsNil() {
+			current.Set(reflect.MakeMap(current.Type()))
+		}
+		current.SetMapIndex(key, entry)
+		return nil
+	}
+	return resolved.fieldError(segment, segment, ErrInvalidPath)
+}
+
+// parsePath splits a dot path (`a.b.0`, `a.b[0]`) or a JSON Pointer (`/a/b/0`) into its segments.
+func parsePath(path string) ([]string, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: empty path", ErrInvalidPath)
+	}
+	if strings.HasPrefix(path, "/") {
+		segments := strings.Split(path[1:], "/")
+		for i, segment := range segments {
+			segments[i] = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+		}
+		return segments, nil
+	}
+	path = strings.ReplaceAll(strings.ReplaceAll(path, "[", "."), "]", "")
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPath, path)
+		}
+	}
+	return segments, nil
+}
+
+// lookupField finds a struct field by its Go name or its JSON name, including fields promoted from embedded structs.
+func lookupField(structType reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.Name == name || (jsonFieldName(field) == name && field.Tag.Get("json") != "-") {
+			return field, true
+		}
+	}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		embeddedType := indirectType(field.Type)
+		if !field.Anonymous || embeddedType.Kind() != reflect.Struct || strings.Split(field.Tag.Get("json"), ",")[0] != "" {
+			continue
+		}
+		if promoted, ok := lookupField(embeddedType, name); ok {
+			promoted.Index = append(append([]int{}, field.Index...), promoted.Index...)
+			return promoted, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// setPathField calls set with the (possibly promoted) field of structValue at index. Nil embedded
+// struct pointers on the way are only allocated if set succeeds.
+func setPathField(structValue reflect.Value, index []int, set func(reflect.Value) error) error {
+	field := structValue.Field(index[0])
+	if len(index) == 1 {
+		return set(field)
+	}
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			embedded := reflect.New(field.Type().Elem())
+			if err := setPathField(embedded.Elem(), index[1:], set); err != nil {
+				return err
+			}
+			field.Set(embedded)
+			return nil
+		}
+		field = field.Elem()
+	}
+	return setPathField(field, index[1:], set)
+}
+
+// fieldByIndexAlloc is like reflect.Value.FieldByIndex, but allocates nil embedded struct pointers on the way.
+func fieldByIndexAlloc(structValue reflect.Value, index []int) reflect.Value {
+	current := structValue
+	for i, fieldIndex := range index {
+		if i > 0 && current.Kind() == reflect.Ptr {
+			if current.IsNil() {
+				current.Set(reflect.New(current.Type().Elem()))
+			}
+			current = current.Elem()
+		}
+		current = current.Field(fieldIndex)
+	}
+	return current
+}
+
+// mapKey converts a path segment into a map key of the given type.
+func mapKey(segment string, keyType reflect.Type) (reflect.Value, error) {
+	if keyType.Kind() == reflect.String {
+		return reflect.ValueOf(segment).Convert(keyType), nil
+	}
+	return parseStringValue(segment, keyType)
+}
+
+// resolvedPath collects the Go and JSON names of the segments resolved so far, for error reporting.
+type resolvedPath struct {
+	goPath   []string
+	jsonPath []string
+}
+
+func (r *resolvedPath) push(goName string, jsonName string) {
+	r.goPath = append(r.goPath, goName)
+	r.jsonPath = append(r.jsonPath, jsonName)
+}
+
+func (r *resolvedPath) error(cause error, expected reflect.Type, actual reflect.Type, value any) *FieldError {
+	return &FieldError{
+		Path:     strings.Join(r.goPath, "."),
+		JSONName: strings.Join(r.jsonPath, "."),
+		Cause:    cause,
+		Expected: expected,
+		Actual:   actual,
+		Value:    value,
+	}
+}
+
+// fieldError reports an error for a segment that could not be resolved.
+func (r *resolvedPath) fieldError(goName string, jsonName string, cause error) *FieldError {
+	r.push(goName, jsonName)
+	return r.error(cause, nil, nil, nil)
+}
@@ -0,0 +1,186 @@
+package struccy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pathGeo struct {
+	Lat float64 `json:"lat" readxs:"*" writexs:"admin"`
+	Lng float64 `json:"lng" readxs:"admin" writexs:"admin"`
+}
+
+type pathAddress struct {
+	City string   `json:"city" readxs:"*" writexs:"*"`
+	Geo  *pathGeo `json:"geo" readxs:"*" writexs:"*"`
+}
+
+type PathAudit struct {
+	CreatedBy string `json:"created_by" readxs:"*" writexs:"system"`
+}
+
+type pathUser struct {
+	PathAudit
+	Name     string                 `json:"name" readxs:"*" writexs:"*"`
+	Address  *pathAddress           `json:"address" readxs:"*" writexs:"*"`
+	Tags     []string               `json:"tags" readxs:"*" writexs:"*"`
+	Labels   map[string]string      `json:"labels" readxs:"*" writexs:"*"`
+	Contacts map[string]pathAddress `json:"contacts" readxs:"*" writexs:"*"`
+	Secret   string                 `json:"secret" readxs:"admin" writexs:"admin"`
+	Scores   map[int]int            `json:"scores" readxs:"*" writexs:"*"`
+	Extra    map[string]any         `json:"extra" readxs:"*" writexs:"*"`
+	Groups   []map[string][]pathGeo `json:"groups" readxs:"*" writexs:"*"`
+}
+
+func TestSetPath(t *testing.T) {
+	user := &pathUser{}
+	admin := []string{"admin"}
+
+	assert.NoError(t, SetPath(user, "address.geo.lat", 52.5, admin))
+	assert.Equal(t, 52.5, user.Address.Geo.Lat)
+	assert.NoError(t, SetPath(user, "/Address/Geo/Lng", "13.4", admin))
+	assert.Equal(t, 13.4, user.Address.Geo.Lng)
+	assert.NoError(t, SetPath(user, "Address.City", "Berlin", admin))
+	assert.Equal(t, "Berlin", user.Address.City)
+
+	assert.NoError(t, SetPath(user, "/tags/-", "first", admin))
+	assert.NoError(t, SetPath(user, "/tags/-", "second", admin))
+	assert.NoError(t, SetPath(user, "tags[1]", "2nd", admin))
+	assert.Equal(t, []string{"first", "2nd"}, user.Tags)
+
+	assert.NoError(t, SetPath(user, "/labels/a~1b", "slash", admin))
+	assert.Equal(t, "slash", user.Labels["a/b"])
+	assert.NoError(t, SetPath(user, "contacts.home.city", "Hamburg", admin))
+	assert.Equal(t, "Hamburg", user.Contacts["home"].City)
+	assert.NoError(t, SetPath(user, "scores.7", 10, admin))
+	assert.Equal(t, 10, user.Scores[7])
+
+	assert.NoError(t, SetPath(user, "created_by", "root", []string{"system"}))
+	assert.Equal(t, "root", user.CreatedBy)
+}
+
+func TestSetPath_Errors(t *testing.T) {
+	user := &pathUser{Tags: []string{"a"}}
+
+	err := SetPath(user, "address.geo.lat", 1.0, []string{"user"})
+	var fieldErr *FieldError
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, ErrUnauthorizedFieldSet, fieldErr.Cause)
+	assert.Equal(t, "Address.Geo.Lat", fieldErr.Path)
+	assert.Equal(t, "address.geo.lat", fieldErr.JSONName)
+
+	assert.True(t, errors.Is(SetPath(user, "address.zip", "1", []string{"user"}), ErrFieldNotFound))
+	assert.True(t, errors.Is(SetPath(user, "tags.5", "x", []string{"user"}), ErrIndexOutOfRange))
+	assert.True(t, errors.Is(SetPath(user, "tags.x", "x", []string{"user"}), ErrInvalidPath))
+	assert.True(t, errors.Is(SetPath(user, "scores.x", 1, []string{"user"}), ErrInvalidPath))
+	assert.True(t, errors.Is(SetPath(user, "name", []int{1}, []string{"user"}), ErrInvalidFieldType))
+	assert.True(t, errors.Is(SetPath(user, "secret", "x", []string{"user"}), ErrUnauthorizedFieldSet))
+	assert.True(t, errors.Is(SetPath(user, "name.first", "x", []string{"user"}), ErrInvalidPath))
+	assert.True(t, errors.Is(SetPath(user, "address..city", "x", []string{"user"}), ErrInvalidPath))
+	assert.Equal(t, ErrInvalidStructPointer, SetPath(*user, "name", "x", nil))
+
+	// failing paths leave the entity unchanged
+	assert.Equal(t, &pathUser{Tags: []string{"a"}}, user)
+	assert.True(t, errors.Is(SetPath(user, "contacts.home.geo.lng", 1.0, []string{"user"}), ErrUnauthorizedFieldSet))
+	assert.True(t, errors.Is(SetPath(user, "groups.-.north.-.lat", 1.0, []string{"user"}), ErrUnauthorizedFieldSet))
+	assert.True(t, errors.Is(SetPath(user, "tags.-", []int{1}, []string{"user"}), ErrInvalidFieldType))
+	assert.Equal(t, &pathUser{Tags: []string{"a"}}, user)
+}
+
+func TestGetPath(t *testing.T) {
+	user := &pathUser{
+		PathAudit: PathAudit{CreatedBy: "root"},
+		Name:      "Jane",
+		Address:   &pathAddress{City: "Berlin", Geo: &pathGeo{Lat: 52.5, Lng: 13.4}},
+		Tags:      []string{"a", "b"},
+		Contacts:  map[string]pathAddress{"home": {City: "Hamburg"}},
+		Extra:     map[string]any{"nested": map[string]any{"deep": 1}},
+		Groups:    []map[string][]pathGeo{{"north": {{Lat: 1}}}},
+	}
+	roles := []string{"user"}
+
+	value, err := GetPath(user, "address.geo.lat", roles)
+	assert.NoError(t, err)
+	assert.Equal(t, 52.5, value)
+	value, err = GetPath(user, "/tags/1", roles)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", value)
+	value, err = GetPath(user, "contacts.home.city", roles)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hamburg", value)
+	value, err = GetPath(user, "extra.nested.deep", roles)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	value, err = GetPath(user, "groups[0].north[0].lat", roles)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, value)
+	value, err = GetPath(user, "CreatedBy", roles)
+	assert.NoError(t, err)
+	assert.Equal(t, "root", value)
+
+	_, err = GetPath(user, "address.geo.lng", roles)
+	assert.True(t, errors.Is(err, ErrUnauthorizedFieldGet))
+	_, err = GetPath(user, "contacts.work", roles)
+	assert.True(t, errors.Is(err, ErrFieldNotFound))
+	_, err = GetPath(user, "secret", roles)
+	assert.True(t, errors.Is(err, ErrUnauthorizedFieldGet))
+
+	value, err = GetPath(&pathUser{}, "address.city", roles)
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+type pathAccess struct {
+	Secret string `json:"secret" readxs:"*" writexs:"*"`
+}
+
+type pathAccount struct {
+	pathAccess `readxs:"admin" writexs:"admin"`
+	Name       string `json:"name" readxs:"*" writexs:"*"`
+}
+
+func TestPathEmbeddedAccess(t *testing.T) {
+	account := &pathAccount{pathAccess: pathAccess{Secret: "s"}}
+	user := []string{"user"}
+
+	// the tags of the embedded struct apply to its promoted fields
+	_, err := GetPath(account, "secret", user)
+	assert.True(t, errors.Is(err, ErrUnauthorizedFieldGet))
+	assert.False(t, IsAllowedPath(account, "secret", user, OpRead))
+	assert.False(t, IsAllowedPath(account, "Secret", user, OpWrite))
+	assert.True(t, errors.Is(SetPath(account, "secret", "x", user), ErrUnauthorizedFieldSet))
+	assert.Equal(t, "s", account.Secret)
+
+	admin := []string{"admin"}
+	value, err := GetPath(account, "secret", admin)
+	assert.NoError(t, err)
+	assert.Equal(t, "s", value)
+	assert.True(t, IsAllowedPath(account, "secret", admin, OpWrite))
+	assert.NoError(t, SetPath(account, "secret", "x", admin))
+	assert.Equal(t, "x", account.Secret)
+	assert.NoError(t, SetPath(account, "name", "n", user))
+}
+
+func TestIsAllowedPath(t *testing.T) {
+	user := &pathUser{}
+	assert.True(t, IsAllowedPath(user, "address.geo.lat", []string{"user"}, OpRead))
+	assert.False(t, IsAllowedPath(user, "address.geo.lat", []string{"user"}, OpWrite))
+	assert.True(t, IsAllowedPath(user, "/address/geo/lat", []string{"admin"}, OpWrite))
+	assert.False(t, IsAllowedPath(user, "address.geo.lng", []string{"user"}, OpRead))
+	assert.True(t, IsAllowedPath(user, "tags.-", []string{"user"}, OpWrite))
+	assert.False(t, IsAllowedPath(user, "tags.-", []string{"user"}, OpRead))
+	assert.True(t, IsAllowedPath(user, "contacts.home.city", []string{"user"}, OpWrite))
+	assert.True(t, IsAllowedPath(user, "extra.anything.goes", []string{"user"}, OpRead))
+	assert.False(t, IsAllowedPath(user, "unknown", []string{"user"}, OpRead))
+	assert.False(t, IsAllowedPath(user, "created_by", []string{"user"}, OpWrite))
+	assert.False(t, IsAllowedPath(nil, "name", []string{"user"}, OpRead))
+
+	// unexported fields cannot be set or read through paths
+	type tagged struct {
+		hidden string `readxs:"*" writexs:"*"`
+	}
+	assert.False(t, IsAllowedPath(&tagged{}, "hidden", []string{"user"}, OpWrite))
+	assert.True(t, errors.Is(SetPath(&tagged{}, "hidden", "x", []string{"user"}), ErrFieldNotFound))
+}
@@ -0,0 +1,341 @@
+package struccy
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSONSchemaDraft is the `$schema` URI of the documents generated by JSONSchema.
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema (Draft 2020-12) as generated by JSONSchema. Marshal it with encoding/json.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 any                `json:"type,omitempty"` // a type name, or a []string for nullable types
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// JSONSchema generates a JSON Schema (Draft 2020-12) of the given struct pointer with exactly the
+// properties StructToJSONFieldsWithReadXS returns with JSONFieldNames (for OpRead), or the properties
+// the roles may write (for OpWrite). Nested values are documented as a whole, like they are encoded.
+//
+// Go types are mapped to the schema types of their encoding/json form: time.Time is a `date-time`
+// string, []byte a base64 string, maps are objects and types implementing encoding.TextMarshaler are
+// strings. Pointers are nullable (`"type": ["string", "null"]`), and nested structs are referenced
+// from `$defs`, named by their Go type name.
+//
+// The `validate` rules that apply to the roles become `required`, `minimum`/`maximum`,
+// `minLength`/`maxLength`, `minItems`/`maxItems`, `pattern`, `enum` and the `email`/`uri` formats
+// (after `dive`, of the items); `default` tags become `default`.
+//
+// If the provided `structPtr` is not a pointer to a struct, the function returns
+// an error (`ErrInvalidStructPointer`).
+func JSONSchema(structPtr any, roles []string, op Operation) (*Schema, error) {
+	structValue := reflect.ValueOf(structPtr)
+	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
+		return nil, ErrInvalidStructPointer
+	}
+	generator := newSchemaGenerator(roles, "#/$defs/")
+	structType := structValue.Elem().Type()
+	schema, err := generator.accessSchema(structType, op)
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = JSONSchemaDraft
+	schema.Title = structType.Name()
+	if len(generator.defs) > 0 {
+		schema.Defs = generator.defs
+	}
+	return schema, nil
+}
+
+// schemaGenerator builds schemas, collecting the schemas of nested structs in defs.
+type schemaGenerator struct {
+	roles     []string
+	refPrefix string
+	defs      map[string]*Schema
+	names     map[reflect.Type]string
+}
+
+func newSchemaGenerator(roles []string, refPrefix string) *schemaGenerator {
+	return &schemaGenerator{
+		roles:     roles,
+		refPrefix: refPrefix,
+		defs:      make(map[string]*Schema),
+		names:     make(map[reflect.Type]string),
+	}
+}
+
+// accessSchema returns the object schema of the fields of structType the roles may access with the operation.
+func (g *schemaGenerator) accessSchema(structType reflect.Type, op Operation) (*Schema, error) {
+	return g.structSchema(structType, func(plan *fieldPlan) bool {
+		return plan.xsAllowed(g.roles, op)
+	})
+}
+
+// structSchema returns the object schema of the fields of structType for which include returns true.
+func (g *schemaGenerator) structSchema(structType reflect.Type, include func(plan *fieldPlan) bool) (*Schema, error) {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	plans := fieldPlans(structType, JSONFieldNames)
+	for i := range plans {
+		plan := &plans[i]
+		if !include(plan) {
+			continue
+		}
+		property, err := g.fieldSchema(plan)
+		if err != nil {
+			return nil, err
+		}
+		schema.Properties[plan.name] = property
+		if g.isRequired(plan.field) {
+			schema.Required = append(schema.Required, plan.name)
+		}
+	}
+	return schema, nil
+}
+
+func (g *schemaGenerator) fieldSchema(plan *fieldPlan) (*Schema, error) {
+	var schema *Schema
+	var err error
+	if plan.asString {
+		schema = nullableSchema(&Schema{Type: "string"}, plan.field.Type.Kind() == reflect.Ptr)
+	} else if schema, err = g.typeSchema(plan.field.Type); err != nil {
+		return nil, err
+	}
+	if tag := plan.field.Tag.Get(tagNameValidate); tag != "" {
+		g.applyRules(schema, indirectType(plan.field.Type), parseValidationRules(tag))
+	}
+	if defaultValue, ok := plan.field.Tag.Lookup(tagNameDefault); ok {
+		parsed, err := parseStringValue(defaultValue, indirectType(plan.field.Type))
+		if err != nil {
+			return nil, newFieldError(plan.field, ErrInvalidDefaultValue, defaultValue)
+		}
+		schema.Default = parsed.Interface()
+	}
+	return schema, nil
+}
+
+// typeSchema returns the schema of the encoding/json form of the type.
+func (g *schemaGenerator) typeSchema(t reflect.Type) (*Schema, error) {
+	if t.Kind() == reflect.Ptr {
+		schema, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullableSchema(schema, true), nil
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// the encoding is up to the type
+		return &Schema{}, nil
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Minimum: ptrTo(0.0)}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentEncoding: "base64"}, nil
+		}
+		items, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		schema := &Schema{Type: "array", Items: items}
+		if t.Kind() == reflect.Array {
+			schema.MinItems, schema.MaxItems = ptrTo(t.Len()), ptrTo(t.Len())
+		}
+		return schema, nil
+	case reflect.Map:
+		values, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return g.structRef(t)
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnsupportedFieldType, t)
+}
+
+// structRef returns a reference to the schema of the struct type in defs, adding it if needed.
+// Anonymous struct types are inlined.
+func (g *schemaGenerator) structRef(t reflect.Type) (*Schema, error) {
+	includeAll := func(*fieldPlan) bool { return true }
+	if t.Name() == "" {
+		return g.structSchema(t, includeAll)
+	}
+	if name, ok := g.names[t]; ok {
+		return &Schema{Ref: g.refPrefix + name}, nil
+	}
+	name := g.defName(t)
+	g.names[t] = name
+	// registered before building, so recursive types reference themselves
+	g.defs[name] = &Schema{}
+	schema, err := g.structSchema(t, includeAll)
+	if err != nil {
+		return nil, err
+	}
+	g.defs[name] = schema
+	return &Schema{Ref: g.refPrefix + name}, nil
+}
+
+// defName returns the Go type name, numbered if another type with the same name is already in defs.
+func (g *schemaGenerator) defName(t reflect.Type) string {
+	name := t.Name()
+	for i := 2; ; i++ {
+		if _, taken := g.defs[name]; !taken {
+			return name
+		}
+		name = t.Name() + strconv.Itoa(i)
+	}
+}
+
+func (g *schemaGenerator) isRequired(field reflect.StructField) bool {
+	for _, rule := range parseValidationRules(field.Tag.Get(tagNameValidate)) {
+		if rule.name == "dive" {
+			return false
+		}
+		if rule.name == "required" && (rule.xs == "" || IsFieldAccessAllowed(g.roles, rule.xs)) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRules adds the constraints of the validation rules that apply to the roles.
+// t is the type behind the pointer of the validated value.
+func (g *schemaGenerator) applyRules(schema *Schema, t reflect.Type, rules []validationRule) {
+	for i, rule := range rules {
+		if rule.xs != "" && !IsFieldAccessAllowed(g.roles, rule.xs) {
+			continue
+		}
+		switch rule.name {
+		case "min", "max", "len":
+			limit, err := strconv.ParseFloat(rule.param, 64)
+			if err != nil {
+				continue
+			}
+			if rule.name != "max" {
+				setSchemaBound(schema, t, limit, true)
+			}
+			if rule.name != "min" {
+				setSchemaBound(schema, t, limit, false)
+			}
+		case "regex":
+			schema.Pattern = rule.param
+		case "oneof":
+			for _, option := range strings.Fields(rule.param) {
+				if parsed, err := parseStringValue(option, t); err == nil {
+					schema.Enum = append(schema.Enum, parsed.Interface())
+				}
+			}
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "dive":
+			switch {
+			case schema.Items != nil:
+				g.applyRules(schema.Items, indirectType(t.Elem()), rules[i+1:])
+			case schema.AdditionalProperties != nil:
+				g.applyRules(schema.AdditionalProperties, indirectType(t.Elem()), rules[i+1:])
+			}
+			return
+		}
+	}
+}
+
+// setSchemaBound sets the lower or upper bound matching the kind of t, like measureValue measures it.
+func setSchemaBound(schema *Schema, t reflect.Type, limit float64, lower bool) {
+	length := ptrTo(int(limit))
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if lower {
+			schema.Minimum = ptrTo(limit)
+		} else {
+			schema.Maximum = ptrTo(limit)
+		}
+	case reflect.String:
+		if lower {
+			schema.MinLength = length
+		} else {
+			schema.MaxLength = length
+		}
+	case reflect.Slice, reflect.Array:
+		if lower {
+			schema.MinItems = length
+		} else {
+			schema.MaxItems = length
+		}
+	case reflect.Map:
+		if lower {
+			schema.MinProperties = length
+		} else {
+			schema.MaxProperties = length
+		}
+	}
+}
+
+// nullableSchema allows null in addition to the schema if nullable is true.
+func nullableSchema(schema *Schema, nullable bool) *Schema {
+	if !nullable {
+		return schema
+	}
+	switch typeName := schema.Type.(type) {
+	case string:
+		schema.Type = []string{typeName, "null"}
+		return schema
+	case nil:
+		if schema.Ref == "" && schema.Properties == nil {
+			// the empty schema already allows null
+			return schema
+		}
+	default:
+		return schema
+	}
+	return &Schema{AnyOf: []*Schema{schema, {Type: "null"}}}
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,109 @@
+package struccy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip,omitempty" validate:"len=5"`
+}
+
+type schemaCategory struct {
+	Name   string          `json:"name"`
+	Parent *schemaCategory `json:"parent"`
+}
+
+type schemaProduct struct {
+	ID        string            `json:"id" readxs:"*" writexs:"admin"`
+	Name      string            `json:"name" readxs:"*" writexs:"editor" validate:"required,min=3,max=80"`
+	Price     float64           `json:"price" readxs:"*" writexs:"editor" validate:"min=0"`
+	Stock     *uint             `json:"stock" readxs:"*" writexs:"editor"`
+	Status    string            `json:"status" readxs:"*" writexs:"editor" validate:"oneof=draft live" default:"draft"`
+	Tags      []string          `json:"tags" readxs:"*" writexs:"editor" validate:"max=5,dive,min=2"`
+	Attrs     map[string]int    `json:"attrs" readxs:"*" writexs:"editor"`
+	Contact   string            `json:"contact" readxs:"*" writexs:"editor" validate:"email,required@!admin"`
+	Image     []byte            `json:"image" readxs:"*" writexs:"editor"`
+	Origin    *schemaAddress    `json:"origin" readxs:"*" writexs:"editor"`
+	Warehouse schemaAddress     `json:"warehouse" readxs:"*" writexs:"editor"`
+	Category  schemaCategory    `json:"category" readxs:"*" writexs:"editor"`
+	Revision  int64             `json:"revision,string" readxs:"*"`
+	CreatedAt time.Time         `json:"createdAt" readxs:"*"`
+	Extra     any               `json:"extra" readxs:"admin" writexs:"admin"`
+	Cost      float64           `json:"cost" readxs:"admin" writexs:"admin"`
+	Notes     map[string]string `json:"-" readxs:"*" writexs:"*"`
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := JSONSchema(&schemaProduct{}, []string{"editor"}, OpWrite)
+	assert.NoError(t, err)
+	schemaJSON, err := json.Marshal(schema)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "schemaProduct",
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 3, "maxLength": 80},
+			"price": {"type": "number", "minimum": 0},
+			"stock": {"type": ["integer", "null"], "minimum": 0},
+			"status": {"type": "string", "enum": ["draft", "live"], "default": "draft"},
+			"tags": {"type": "array", "maxItems": 5, "items": {"type": "string", "minLength": 2}},
+			"attrs": {"type": "object", "additionalProperties": {"type": "integer"}},
+			"contact": {"type": "string", "format": "email"},
+			"image": {"type": "string", "contentEncoding": "base64"},
+			"origin": {"anyOf": [{"$ref": "#/$defs/schemaAddress"}, {"type": "null"}]},
+			"warehouse": {"$ref": "#/$defs/schemaAddress"},
+			"category": {"$ref": "#/$defs/schemaCategory"}
+		},
+		"required": ["name", "contact"],
+		"$defs": {
+			"schemaAddress": {
+				"type": "object",
+				"properties": {
+					"city": {"type": "string"},
+					"zip": {"type": "string", "minLength": 5, "maxLength": 5}
+				},
+				"required": ["city"]
+			},
+			"schemaCategory": {
+				"type": "object",
+				"properties": {
+					"name": {"type": "string"},
+					"parent": {"anyOf": [{"$ref": "#/$defs/schemaCategory"}, {"type": "null"}]}
+				}
+			}
+		}
+	}`, string(schemaJSON))
+
+	// the read schema has the properties of StructToJSONFieldsWithReadXS
+	schema, err = JSONSchema(&schemaProduct{}, []string{"admin"}, OpRead)
+	assert.NoError(t, err)
+	fields, err := StructToJSONFieldsWithReadXS(&schemaProduct{}, []string{"admin"}, WithFieldNaming(JSONFieldNames))
+	assert.NoError(t, err)
+	var fieldMap map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(fields), &fieldMap))
+	assert.ElementsMatch(t, sortedMapKeys(fieldMap), sortedMapKeys(schema.Properties))
+	assert.Equal(t, &Schema{Type: "string"}, schema.Properties["revision"])
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, schema.Properties["createdAt"])
+	assert.Equal(t, &Schema{}, schema.Properties["extra"])
+	// required@!admin does not apply
+	assert.Equal(t, []string{"name"}, schema.Required)
+
+	_, err = JSONSchema(schemaProduct{}, nil, OpRead)
+	assert.Equal(t, ErrInvalidStructPointer, err)
+
+	_, err = JSONSchema(&struct {
+		Limit int `json:"limit" readxs:"*" default:"many"`
+	}{}, nil, OpRead)
+	assert.ErrorIs(t, err, ErrInvalidDefaultValue)
+
+	_, err = JSONSchema(&struct {
+		Done chan bool `json:"done" readxs:"*"`
+	}{}, nil, OpRead)
+	assert.ErrorIs(t, err, ErrUnsupportedFieldType)
+}
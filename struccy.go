@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -58,11 +59,23 @@ var (
 //     to the dereferenced value of the destination field.
 //   - If a field in the source struct is a pointer and it is nil, the corresponding field in the destination struct
 //     is set to its zero value.
+//   - Unexported fields are not taken from the source struct and keep the destination's values.
+//   - Fields with a `@readonly`, `@system` or (once set) `@immutable` marker in their `writexs` tag keep their
+//     values; updates that would change them are reported with ErrFieldReadOnly, ErrFieldSystemManaged or
+//     ErrFieldImmutable, while fields the roles may not write are skipped silently. The markers and `writeif`
+//     conditions of the destination struct apply even if the source struct is of another type.
+//   - The field tagged `version` is never taken from the source struct. WithVersionCheck requires it to match
+//     the destination's version and WithVersionIncrement increments it in the merged struct.
 //
 // The function returns an error if:
 // - The source or destination struct is not a pointer to a struct.
 // - The types of the corresponding fields in the source and destination structs do not match.
-func MergeStructUpdateTo(targetStruct any, updateStruct any, xsList []string) (any, error) {
+//
+// Field-level failures do not abort the merge; all of them are collected and returned
+// together as a *FieldErrors. The merged struct is also checked against its `validate` tags
+// (see ValidateStruct), and validation failures are reported in the same *FieldErrors.
+func MergeStructUpdateTo(targetStruct any, updateStruct any, xsList []string, opts ...MergeOption) (any, error) {
+	options := newMergeOptions(opts)
 	targetValue := reflect.ValueOf(targetStruct)
 	updateValue := reflect.ValueOf(updateStruct)
 
@@ -77,55 +90,106 @@ func MergeStructUpdateTo(targetStruct any, updateStruct any, xsList []string) (a
 	targetType := targetValue.Elem().Type()
 	updateType := updateValue.Elem().Type()
 
-	mergedStruct := reflect.New(targetType).Elem()
-
-	for i := 0; i < targetType.NumField(); i++ {
-		targetField := targetValue.Elem().Field(i)
-		mergedStruct.Field(i).Set(targetField)
+	version, versioned, err := versionField(targetType, options)
+	if err != nil {
+		return nil, err
 	}
+	if versioned && options.checkVersion {
+		err := checkVersion(targetValue.Elem(), version, updateValue.Elem().FieldByName(version.Name), func() []string {
+			return conflictingStructFields(targetValue.Elem(), updateValue.Elem(), version, xsList)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mergedStruct := reflect.New(targetType).Elem()
+	mergedStruct.Set(targetValue.Elem())
 
+	fieldErrs := &FieldErrors{}
 	for i := 0; i < updateType.NumField(); i++ {
 		field := updateType.Field(i)
+		if !field.IsExported() || (versioned && field.Name == version.Name) {
+			continue
+		}
 		updateField := updateValue.Elem().Field(i)
 
 		targetField := mergedStruct.FieldByName(field.Name)
 		if !targetField.IsValid() {
-			return nil, fmt.Errorf("%w: %s", ErrFieldNotFound, field.Name)
+			fieldErrs.Add(&FieldError{Path: field.Name, JSONName: jsonFieldName(field), Cause: ErrFieldNotFound, Actual: field.Type, Value: valueInterface(updateField)})
+			continue
 		}
 
-		writexs := field.Tag.Get(tagNameWriteXS)
-		if !IsFieldAccessAllowed(xsList, writexs) {
+		// mergedStruct is modified along the way, the conditions and markers apply to the original target
+		if denial := mergeDenial(targetValue.Elem(), field, xsList); denial != nil {
+			if isMarkerDenial(denial) && changesField(targetField, func(attempt reflect.Value) error {
+				return mergeFieldValue(attempt, updateField)
+			}) {
+				fieldErrs.Add(&FieldError{Path: field.Name, JSONName: jsonFieldName(field), Cause: denial, Expected: targetField.Type(), Actual: updateField.Type(), Value: valueInterface(updateField)})
+			}
 			continue
 		}
 
-		if updateField.Kind() == reflect.Ptr {
-			if !updateField.IsNil() {
-				if targetField.Kind() == reflect.Ptr {
-					targetField.Set(updateField)
-				} else {
-					targetField.Set(updateField.Elem())
-				}
-			}
-		} else {
+		if err := mergeFieldValue(targetField, updateField); err != nil {
+			fieldErrs.Add(&FieldError{Path: field.Name, JSONName: jsonFieldName(field), Cause: err, Expected: targetField.Type(), Actual: updateField.Type(), Value: valueInterface(updateField)})
+		}
+	}
+	validateStructValue(mergedStruct, xsList, "", "", fieldErrs)
+
+	if err := fieldErrs.errOrNil(); err != nil {
+		return nil, err
+	}
+	if versioned && options.incrementVersion {
+		incrementVersion(mergedStruct, version)
+	}
+	return mergedStruct.Addr().Interface(), nil
+}
+
+// conflictingStructFields returns the fields that MergeStructUpdateTo would change in the target.
+func conflictingStructFields(targetValue, updateValue reflect.Value, version reflect.StructField, xsList []string) []string {
+	fields := make([]string, 0)
+	for i := 0; i < updateValue.NumField(); i++ {
+		field := updateValue.Type().Field(i)
+		targetField := targetValue.FieldByName(field.Name)
+		if !field.IsExported() || field.Name == version.Name || !targetField.IsValid() || mergeDenial(targetValue, field, xsList) != nil {
+			continue
+		}
+		if changesField(targetField, func(attempt reflect.Value) error {
+			return mergeFieldValue(attempt, updateValue.Field(i))
+		}) {
+			fields = append(fields, field.Name)
+		}
+	}
+	return fields
+}
+
+// mergeFieldValue sets targetField to updateField like MergeStructUpdateTo: nil pointers leave it
+// unchanged and pointers are (de)referenced as needed.
+func mergeFieldValue(targetField reflect.Value, updateField reflect.Value) error {
+	if updateField.Kind() == reflect.Ptr {
+		if !updateField.IsNil() {
 			if targetField.Kind() == reflect.Ptr {
-				targetField.Set(reflect.New(targetField.Type().Elem()))
-				targetField.Elem().Set(updateField)
+				targetField.Set(updateField)
 			} else {
-				if updateField.Type().AssignableTo(targetField.Type()) {
-					targetField.Set(updateField)
-				} else {
-					return nil, fmt.Errorf("%w: %s, expected %v, got %v", ErrFieldTypeMismatch, field.Name, targetField.Type(), updateField.Type())
-				}
+				targetField.Set(updateField.Elem())
 			}
 		}
-
-		switch updateField.Kind() {
-		case reflect.Chan, reflect.Func, reflect.Interface:
-			return nil, fmt.Errorf("%w: %s, type %v", ErrUnsupportedFieldType, field.Name, updateField.Type())
-		}
+		return nil
+	}
+	if targetField.Kind() == reflect.Ptr {
+		targetField.Set(reflect.New(targetField.Type().Elem()))
+		targetField.Elem().Set(updateField)
+	} else if updateField.Type().AssignableTo(targetField.Type()) {
+		targetField.Set(updateField)
+	} else {
+		return ErrFieldTypeMismatch
 	}
 
-	return mergedStruct.Addr().Interface(), nil
+	switch updateField.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface:
+		return ErrUnsupportedFieldType
+	}
+	return nil
 }
 
 // MergeMapStringFieldsToStruct merges the fields from a map[string]any into a target struct.
@@ -145,63 +209,177 @@ func MergeStructUpdateTo(targetStruct any, updateStruct any, xsList []string) (a
 //     the function creates a new pointer with the updateMap value.
 //   - If the struct field is not a pointer and the updateMap value is a pointer,
 //     the function dereferences the updateMap value.
+//   - If the updateMap value is nil or a nil pointer, pointer, slice, map and interface fields are set to nil.
 //   - If a field is not allowed based on the xsList, it is skipped.
+//   - Fields with a `@readonly`, `@system` or (once set) `@immutable` marker in their `writexs` tag keep their
+//     values; values that would change them are reported with ErrFieldReadOnly, ErrFieldSystemManaged or ErrFieldImmutable.
+//   - If the WithDefaults option is given, zero-valued fields that were not sent (or are not writable)
+//     are filled from their `default` tag.
+//   - The field tagged `version` is never taken from the updateMap. WithVersionCheck requires its entry to match
+//     the struct's version and WithVersionIncrement increments it after a successful merge.
 //
 // The function returns the updated struct and an error if any of the following conditions are met:
 // - The target struct is not a pointer to a struct.
-// - An error occurs during the merging process.
+// - An error occurs during the merging process. All failing fields are reported together as a *FieldErrors.
+// - The merged struct violates its `validate` tags (see ValidateStruct); these are reported in the same *FieldErrors.
+// The target struct is only changed if the merge succeeds.
 
 // MergeMapStringFieldsToStruct merges the fields from a map[string]any into a target struct.
-func MergeMapStringFieldsToStruct(targetStruct any, updateMap map[string]any, xsList []string) (any, error) {
+func MergeMapStringFieldsToStruct(targetStruct any, updateMap map[string]any, xsList []string, opts ...MergeOption) (any, error) {
+	options := newMergeOptions(opts)
 	targetValue := reflect.ValueOf(targetStruct)
 	if targetValue.Kind() != reflect.Ptr || targetValue.Elem().Kind() != reflect.Struct {
 		return nil, ErrTargetStructMustBePointer
 	}
 
 	structElem := targetValue.Elem()
-	for key, updateValue := range updateMap {
-		targetField := structElem.FieldByName(key)
+	version, versioned, err := versionField(structElem.Type(), options)
+	if err != nil {
+		return nil, err
+	}
+	if versioned && options.checkVersion {
+		err := checkVersion(structElem, version, reflect.ValueOf(updateMap[version.Name]), func() []string {
+			return conflictingMapFields(structElem, updateMap, version)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the merge works on a copy that replaces the struct only if it succeeds
+	mergedStruct := detachedCopy(structElem)
+	fieldErrs := &FieldErrors{}
+	sent := make(map[string]bool)
+	for _, key := range sortedMapKeys(updateMap) {
+		updateValue := updateMap[key]
+		targetField := mergedStruct.FieldByName(key)
 		if !targetField.IsValid() {
 			continue // Field not found in the struct
 		}
 		if !targetField.CanSet() {
 			continue // Cannot set unexported fields
 		}
+		if versioned && key == version.Name {
+			continue // The version is only checked and incremented
+		}
+		sent[key] = true
 
 		updateValueReflect := reflect.ValueOf(updateValue)
+		structField, _ := structElem.Type().FieldByName(key)
+		if err := markerDenial(structElem, structField, false); err != nil {
+			// every key sets another field, so the field still has its original value
+			if changesField(targetField, func(attempt reflect.Value) error {
+				return assignValueToField(attempt, updateValueReflect)
+			}) {
+				fieldErrs.Add(newFieldError(structField, err, updateValue))
+			}
+			continue
+		}
 		if err := assignValueToField(targetField, updateValueReflect); err != nil {
-			return nil, fmt.Errorf("error assigning field '%s': %w", key, err)
+			fieldErrs.Add(newFieldError(structField, err, updateValue))
 		}
 	}
+	if options.applyDefaults {
+		applyMergeDefaults(mergedStruct, xsList, sent, fieldErrs)
+	}
+	validateStructValue(mergedStruct, xsList, "", "", fieldErrs)
 
+	if err := fieldErrs.errOrNil(); err != nil {
+		return nil, err
+	}
+	structElem.Set(mergedStruct)
+	if versioned && options.incrementVersion {
+		incrementVersion(structElem, version)
+	}
 	return targetStruct, nil
 }
 
+// detachedCopy returns a copy of the struct value whose nested struct pointers point to copies as well,
+// so that changes to the copy do not reach the original struct.
+func detachedCopy(structValue reflect.Value) reflect.Value {
+	copies := make(map[uintptr]reflect.Value)
+	if structValue.CanAddr() {
+		// the copy is written back to the original, so pointers to it stay as they are
+		copies[structValue.Addr().Pointer()] = structValue.Addr()
+	}
+	return detachStruct(structValue, copies)
+}
+
+// detachStruct copies the struct value; copies holds the copies of the struct pointers seen so far.
+func detachStruct(structValue reflect.Value, copies map[uintptr]reflect.Value) reflect.Value {
+	copied := reflect.New(structValue.Type()).Elem()
+	copied.Set(structValue)
+	for i := 0; i < copied.NumField(); i++ {
+		field := copied.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		switch {
+		case field.Kind() == reflect.Struct:
+			field.Set(detachStruct(field, copies))
+		case field.Kind() == reflect.Ptr && !field.IsNil() && field.Elem().Kind() == reflect.Struct:
+			if pointerCopy, ok := copies[field.Pointer()]; ok {
+				field.Set(pointerCopy)
+				continue
+			}
+			pointerCopy := reflect.New(field.Type().Elem())
+			copies[field.Pointer()] = pointerCopy
+			pointerCopy.Elem().Set(detachStruct(field.Elem(), copies))
+			field.Set(pointerCopy)
+		}
+	}
+	return copied
+}
+
+// conflictingMapFields returns the fields that MergeMapStringFieldsToStruct would change in the struct value.
+func conflictingMapFields(structValue reflect.Value, updateMap map[string]any, version reflect.StructField) []string {
+	fields := make([]string, 0)
+	for _, key := range sortedMapKeys(updateMap) {
+		targetField := structValue.FieldByName(key)
+		if !targetField.CanSet() || key == version.Name {
+			continue
+		}
+		structField, _ := structValue.Type().FieldByName(key)
+		if markerDenial(structValue, structField, false) != nil {
+			continue
+		}
+		if changesField(targetField, func(attempt reflect.Value) error {
+			return assignValueToField(attempt, reflect.ValueOf(updateMap[key]))
+		}) {
+			fields = append(fields, key)
+		}
+	}
+	return fields
+}
+
 // This function tries to assign values to struct fields while handling type conversions.
 func assignValueToField(targetField, updateValueReflect reflect.Value) error {
 	// First, check if the update value is valid (not a zero Value)
 	if !updateValueReflect.IsValid() {
-		if targetField.Kind() == reflect.Ptr {
-			// If it's a pointer in the struct, set it to nil
+		switch targetField.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			// If it's a nilable field in the struct, set it to nil
 			targetField.Set(reflect.Zero(targetField.Type()))
 			return nil
-		} else {
-			// If it's not a pointer and we're trying to assign nil, that's an error
-			return fmt.Errorf("cannot assign nil to non-pointer type %s", targetField.Type())
+		default:
+			// If it's not nilable and we're trying to assign nil, that's an error
+			return ErrFieldIsNil
 		}
 	}
 
 	// Handle if the update value is a pointer and the target field is not, or vice versa.
 	if updateValueReflect.Kind() == reflect.Ptr {
+		if updateValueReflect.IsNil() {
+			// A nil pointer is handled like nil
+			return assignValueToField(targetField, reflect.Value{})
+		}
 		updateValueReflect = updateValueReflect.Elem() // Dereference pointers to their base value.
 	}
 
 	// Handle pointer fields in the struct.
 	if targetField.Kind() == reflect.Ptr {
-		// Handle initializing nil pointer fields if needed.
-		if targetField.IsNil() && targetField.CanSet() {
-			targetField.Set(reflect.New(targetField.Type().Elem()))
-		}
+		// Point the field to a new value, the old one may still be referenced by the original struct.
+		targetField.Set(reflect.New(targetField.Type().Elem()))
 		if updateValueReflect.Type().AssignableTo(targetField.Type().Elem()) {
 			targetField.Elem().Set(updateValueReflect) // Assign compatible types directly.
 		} else if checkTypeConvertible(updateValueReflect, targetField.Type().Elem()) {
@@ -320,8 +498,7 @@ func FilterStructTo(sourceStruct any, filteredStruct any, xsList []string, zeroD
 				return fmt.Errorf("%w: %s, expected %v, got %v", ErrFieldTypeMismatch, field.Name, filteredField.Type(), sourceField.Type())
 			}
 		} else {
-			readxs := field.Tag.Get(tagNameReadXS)
-			if !IsFieldAccessAllowed(xsList, readxs) {
+			if !fieldAccessAllowed(sourceValue.Elem(), field, xsList, OpRead) {
 				if zeroDisallowed {
 					filteredField.Set(reflect.Zero(filteredField.Type()))
 				}
@@ -343,22 +520,6 @@ func FilterStructTo(sourceStruct any, filteredStruct any, xsList []string, zeroD
 	return nil
 }
 
-// FilterMapFieldsToStruct filters the fields of a source map and assigns the allowed fields to a destination struct.
-// It takes a map of string field names and values and a list of allowed field names (xsList).
-// The function iterates over the fields of the destination struct and looks for corresponding entries in the source map.
-// If a matching entry is found and the field name is allowed based on the xsList, the value from the source map is assigned
-func FilterMapFieldsByRole(source map[string]any, xsList []string) (filtered map[string]any, err error) {
-	filtered = make(map[string]any)
-	allowedFieldNames, err := GetFieldNamesWithWriteXS(source, xsList)
-	if err != nil {
-		return nil, err
-	}
-	for _, fieldName := range allowedFieldNames {
-		filtered[fieldName] = source[fieldName]
-	}
-	return filtered, nil
-}
-
 // StructToJSONFields takes a pointer to a struct and a slice of field names,
 // and returns a JSON string of the struct fields filtered to the specified field names.
 // If any error occurs during the process, an empty string and the error are returned.
@@ -441,8 +602,7 @@ func GetFieldNamesWithReadXS(structPtr any, xsList []string) ([]string, error) {
 	fieldNames := make([]string, 0)
 	for i := 0; i < numFields; i++ {
 		field := structType.Field(i)
-		readXS := field.Tag.Get(tagNameReadXS)
-		if IsFieldAccessAllowed(xsList, readXS) {
+		if fieldAccessAllowed(structValue.Elem(), field, xsList, OpRead) {
 			fieldNames = append(fieldNames, field.Name)
 		}
 	}
@@ -470,8 +630,7 @@ func GetFieldNamesWithWriteXS(structPtr any, xsList []string) ([]string, error)
 	fieldNames := make([]string, 0)
 	for i := 0; i < numFields; i++ {
 		field := structType.Field(i)
-		writeXS := field.Tag.Get(tagNameWriteXS)
-		if IsFieldAccessAllowed(xsList, writeXS) {
+		if fieldAccessAllowed(structValue.Elem(), field, xsList, OpWrite) {
 			fieldNames = append(fieldNames, field.Name)
 		}
 	}
@@ -484,29 +643,25 @@ func GetFieldNamesWithWriteXS(structPtr any, xsList []string) ([]string, error)
 // It uses reflection to iterate over the fields of the struct and collect the field
 // names and values that have read access allowed.
 //
+// The map is keyed by Go field names unless another naming is selected with WithFieldNaming.
+// Without options, the ToMapForRoles method generated by struccy-gen is used if the type has one (see RoleMapper).
+//
 // If the provided `structPtr` is not a pointer to a struct, the function returns
 // an error (`ErrInvalidStructPointer`).
-func StructToMapFieldsWithReadXS(structPtr any, xsList []string) (map[string]any, error) {
+func StructToMapFieldsWithReadXS(structPtr any, xsList []string, opts ...ConvertOption) (map[string]any, error) {
 	structValue := reflect.ValueOf(structPtr)
 
 	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
 		return nil, ErrInvalidStructPointer
 	}
-
-	structValue = structValue.Elem()
-	structType := structValue.Type()
-	numFields := structType.NumField()
-
-	fieldMap := make(map[string]any)
-	for i := 0; i < numFields; i++ {
-		field := structType.Field(i)
-		readXS := field.Tag.Get(tagNameReadXS)
-		if IsFieldAccessAllowed(xsList, readXS) {
-			fieldMap[field.Name] = structValue.Field(i).Interface()
-		}
+	if mapper, ok := structPtr.(RoleMapper); ok && len(opts) == 0 {
+		return mapper.ToMapForRoles(xsList), nil
 	}
 
-	return fieldMap, nil
+	options := newConvertOptions(opts)
+	return structFieldsToMap(structValue.Elem(), options.naming, false, func(plan *fieldPlan) bool {
+		return plan.allowed(structValue.Elem(), xsList, OpRead)
+	}), nil
 }
 
 // StructToMapFieldsWithWriteXS converts the specified struct pointer to a map,
@@ -514,43 +669,24 @@ func StructToMapFieldsWithReadXS(structPtr any, xsList []string) (map[string]any
 // It uses reflection to iterate over the fields of the struct and collect the field
 // names and values that have write access allowed.
 //
+// useJsonFieldNames is a shorthand for WithFieldNaming(JSONFieldNames); a naming passed as option takes precedence.
+//
 // If the provided `structPtr` is not a pointer to a struct, the function returns
 // an error (`ErrInvalidStructPointer`).
-func StructToMapFieldsWithWriteXS(structPtr any, xsList []string, skipNilValues bool, useJsonFieldNames bool) (map[string]any, error) {
+func StructToMapFieldsWithWriteXS(structPtr any, xsList []string, skipNilValues bool, useJsonFieldNames bool, opts ...ConvertOption) (map[string]any, error) {
 	structValue := reflect.ValueOf(structPtr)
 
 	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
 		return nil, ErrInvalidStructPointer
 	}
 
-	structValue = structValue.Elem()
-	structType := structValue.Type()
-	numFields := structType.NumField()
-
-	fieldMap := make(map[string]any)
-	for i := 0; i < numFields; i++ {
-		field := structType.Field(i)
-		value := structValue.Field(i)
-
-		if skipNilValues && isNil(value) {
-			continue
-		}
-
-		writeXS := field.Tag.Get(tagNameWriteXS)
-		if IsFieldAccessAllowed(xsList, writeXS) {
-
-			if useJsonFieldNames {
-				jsonFieldName := strings.Split(field.Tag.Get("json"), ",")[0] // Get the first part of the JSON tag
-				if jsonFieldName != "" && jsonFieldName != "-" {
-					fieldMap[jsonFieldName] = value.Interface()
-				}
-			} else {
-				fieldMap[field.Name] = value.Interface()
-			}
-		}
+	options := newConvertOptions(opts)
+	if useJsonFieldNames && !options.namingSet {
+		options.naming = JSONFieldNames
 	}
-
-	return fieldMap, nil
+	return structFieldsToMap(structValue.Elem(), options.naming, skipNilValues, func(plan *fieldPlan) bool {
+		return plan.allowed(structValue.Elem(), xsList, OpWrite)
+	}), nil
 }
 
 // StructToJSONFieldsWithReadXS converts the specified struct pointer to a JSON string,
@@ -559,10 +695,12 @@ func StructToMapFieldsWithWriteXS(structPtr any, xsList []string, skipNilValues
 // names and values that have read access allowed, and then marshals the resulting map
 // to a JSON string.
 //
+// The JSON object is keyed by Go field names unless another naming is selected with WithFieldNaming.
+//
 // If the provided `structPtr` is not a pointer to a struct, the function returns
 // an error (`ErrInvalidStructPointer`).
-func StructToJSONFieldsWithReadXS(structPtr any, xsList []string) (string, error) {
-	fieldMap, err := StructToMapFieldsWithReadXS(structPtr, xsList)
+func StructToJSONFieldsWithReadXS(structPtr any, xsList []string, opts ...ConvertOption) (string, error) {
+	fieldMap, err := StructToMapFieldsWithReadXS(structPtr, xsList, opts...)
 	if err != nil {
 		return "", err
 	}
@@ -581,10 +719,12 @@ func StructToJSONFieldsWithReadXS(structPtr any, xsList []string) (string, error
 // names and values that have write access allowed, and then marshals the resulting map
 // to a JSON string.
 //
+// The JSON object is keyed by JSON field names unless another naming is selected with WithFieldNaming.
+//
 // If the provided `structPtr` is not a pointer to a struct, the function returns
 // an error (`ErrInvalidStructPointer`).
-func StructToJSONFieldsWithWriteXS(structPtr any, xsList []string, skipNilValues bool) (string, error) {
-	fieldMap, err := StructToMapFieldsWithWriteXS(structPtr, xsList, skipNilValues, true)
+func StructToJSONFieldsWithWriteXS(structPtr any, xsList []string, skipNilValues bool, opts ...ConvertOption) (string, error) {
+	fieldMap, err := StructToMapFieldsWithWriteXS(structPtr, xsList, skipNilValues, true, opts...)
 	if err != nil {
 		return "", err
 	}
@@ -608,29 +748,39 @@ func StructToJSONFieldsWithWriteXS(structPtr any, xsList []string, skipNilValues
 // an error (`ErrInvalidStructPointer`).
 //
 // If a specified field name does not exist in the struct, it is silently ignored.
-func StructToMapFields(structPtr any, fieldNames []string) (map[string]any, error) {
+// The field names are Go names; the map keys follow the naming selected with WithFieldNaming.
+func StructToMapFields(structPtr any, fieldNames []string, opts ...ConvertOption) (map[string]any, error) {
 	structValue := reflect.ValueOf(structPtr)
 
 	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
 		return nil, ErrInvalidStructPointer
 	}
 
-	structValue = structValue.Elem()
-	structType := structValue.Type()
-
-	fieldMap := make(map[string]any)
-
-	for _, fieldName := range fieldNames {
-		field, ok := structType.FieldByName(fieldName)
-		if !ok {
-			continue
+	options := newConvertOptions(opts)
+	if options.naming == GoFieldNames {
+		// Go names also select the fields promoted from embedded structs
+		structValue = structValue.Elem()
+		fieldMap := make(map[string]any)
+		for _, fieldName := range fieldNames {
+			field, ok := structValue.Type().FieldByName(fieldName)
+			if !ok || !field.IsExported() {
+				continue
+			}
+			fieldValue, err := structValue.FieldByIndexErr(field.Index)
+			if err != nil {
+				continue // promoted through a nil embedded pointer
+			}
+			fieldMap[field.Name] = fieldValue.Interface()
 		}
-
-		fieldValue := structValue.FieldByName(fieldName)
-		fieldMap[field.Name] = fieldValue.Interface()
+		return fieldMap, nil
 	}
-
-	return fieldMap, nil
+	selected := make(map[string]bool, len(fieldNames))
+	for _, fieldName := range fieldNames {
+		selected[fieldName] = true
+	}
+	return structFieldsToMap(structValue.Elem(), options.naming, false, func(plan *fieldPlan) bool {
+		return selected[plan.field.Name]
+	}), nil
 }
 
 // StructToMap takes a pointer to a struct and returns a map of the struct fields.
@@ -641,25 +791,17 @@ func StructToMapFields(structPtr any, fieldNames []string) (map[string]any, erro
 //
 // If the provided `structPtr` is not a pointer to a struct, the function returns
 // an error (`ErrInvalidStructPointer`).
-func StructToMap(structPtr any) (map[string]any, error) {
+func StructToMap(structPtr any, opts ...ConvertOption) (map[string]any, error) {
 	structValue := reflect.ValueOf(structPtr)
 
 	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
 		return nil, ErrInvalidStructPointer
 	}
 
-	structValue = structValue.Elem()
-	structType := structValue.Type()
-
-	fieldMap := make(map[string]any)
-
-	for i := 0; i < structType.NumField(); i++ {
-		field := structType.Field(i)
-		fieldValue := structValue.Field(i)
-		fieldMap[field.Name] = fieldValue.Interface()
-	}
-
-	return fieldMap, nil
+	options := newConvertOptions(opts)
+	return structFieldsToMap(structValue.Elem(), options.naming, false, func(plan *fieldPlan) bool {
+		return true
+	}), nil
 }
 
 func IsFieldAccessAllowed(roles []string, tagValue string) bool {
@@ -892,26 +1034,85 @@ func convertSliceUsingReflection(sliceOfAny []any, targetType reflect.Type, igno
 //   - setterRole: the role of the setter, used for authorization checks
 //   - skipZeroVals: a flag indicating whether zero values should be skipped
 //   - ignoreUnsettables: a flag indicating whether to ignore unsettable fields or throw an error upon attempt
+//   - opts: optional MergeOptions, e.g. WithDefaults to fill defaults into fields that were not updated,
+//     or WithVersionCheck and WithVersionIncrement for the field tagged `version`, which is never set from incomingEntity
 //
 // Returns:
 //   - A map of the updated field names and their corresponding values
-//   - An error if any error occurs during the field setting (except for unauthorized fields)
-func UpdateStructFields(entity any, incomingEntity any, roles []string, skipZeroVals bool, ignoreUnsettables bool) (updatedFields map[string]any, unsettableFields map[string]any, err error) {
+//   - A map of the field names that could not be set and their corresponding values
+//   - A *FieldErrors listing every field that could not be set (except for unauthorized fields),
+//     unless ignoreUnsettables is true, and every violation of the entity's `validate` tags after the update.
+//     Values that would change fields with a `@readonly`, `@system` or (once set) `@immutable` marker in their
+//     `writexs` tag are unsettable and reported with ErrFieldReadOnly, ErrFieldSystemManaged or ErrFieldImmutable.
+//     The entity is only updated if there are no errors.
+//   - ErrInvalidStructPointer or ErrUpdateStructMustBePointer if entity or incomingEntity is not a pointer to a struct
+func UpdateStructFields(entity any, incomingEntity any, roles []string, skipZeroVals bool, ignoreUnsettables bool, opts ...MergeOption) (updatedFields map[string]any, unsettableFields map[string]any, err error) {
+	entityValue := reflect.ValueOf(entity)
+	if entityValue.Kind() != reflect.Ptr || entityValue.Elem().Kind() != reflect.Struct {
+		return nil, nil, ErrInvalidStructPointer
+	}
+	if incoming := reflect.ValueOf(incomingEntity); incoming.Kind() != reflect.Ptr || incoming.Elem().Kind() != reflect.Struct {
+		return nil, nil, ErrUpdateStructMustBePointer
+	}
+	options := newMergeOptions(opts)
 	updatedFields = make(map[string]any)
 	unsettableFields = make(map[string]any)
 	incomingValue := reflect.ValueOf(incomingEntity).Elem()
 	incomingType := reflect.TypeOf(incomingEntity).Elem()
+	entityType := entityValue.Type().Elem()
 
+	// the `writeif` conditions and `@immutable` markers apply to the entity as it was before the update
+	denials := make(map[string]error, incomingType.NumField())
+	for i := 0; i < incomingType.NumField(); i++ {
+		denials[incomingType.Field(i).Name] = setFieldDenial(entity, incomingType.Field(i).Name, roles)
+	}
+
+	version, versioned, err := versionField(entityType, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if versioned && options.checkVersion {
+		err := checkVersion(reflect.ValueOf(entity).Elem(), version, incomingValue.FieldByName(version.Name), func() []string {
+			fields := make([]string, 0)
+			for i := 0; i < incomingType.NumField(); i++ {
+				fieldName := incomingType.Field(i).Name
+				if fieldName == version.Name || denials[fieldName] != nil {
+					continue
+				}
+				if changesField(reflect.ValueOf(entity).Elem().FieldByName(fieldName), func(attempt reflect.Value) error {
+					return setNonZeroField(attempt, incomingValue.Field(i).Interface())
+				}) {
+					fields = append(fields, fieldName)
+				}
+			}
+			return fields
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// the update works on a copy that replaces the entity only if it succeeds
+	updatedEntity := detachedCopy(entityValue.Elem())
+	fieldErrs := &FieldErrors{}
 	for i := 0; i < incomingValue.NumField(); i++ {
 		fieldName := incomingType.Field(i).Name
 		incomingField := incomingValue.FieldByName(fieldName)
-		if !incomingField.IsValid() {
+		if !incomingField.IsValid() || (versioned && fieldName == version.Name) {
 			continue
 		}
-		// Check if the field is settable and authorized
-		if IsAllowedToSetField(entity, fieldName, roles) {
+		// Check if the field is settable and authorized; marker violations are reported if the value would change it
+		denial := denials[fieldName]
+		if denial == nil || isMarkerDenial(denial) {
 			fieldValue := incomingField.Interface()
-			err := SetField(entity, fieldName, fieldValue, skipZeroVals, roles)
+			err := denial
+			if err == nil {
+				err = setNamedField(updatedEntity, fieldName, fieldValue, skipZeroVals)
+			} else if !changesField(reflect.ValueOf(entity).Elem().FieldByName(fieldName), func(attempt reflect.Value) error {
+				return setNonZeroField(attempt, fieldValue)
+			}) {
+				continue
+			}
 			if err == nil {
 				updatedFields[fieldName] = fieldValue
 			} else {
@@ -919,10 +1120,26 @@ func UpdateStructFields(entity any, incomingEntity any, roles []string, skipZero
 				if ignoreUnsettables {
 					continue
 				}
-				return nil, unsettableFields, err
+				structField, _ := entityType.FieldByName(fieldName)
+				fieldErrs.Add(newFieldError(structField, err, fieldValue))
 			}
 		}
 	}
+	if options.applyDefaults {
+		sent := make(map[string]bool, len(updatedFields))
+		for fieldName := range updatedFields {
+			sent[fieldName] = true
+		}
+		applyMergeDefaults(updatedEntity, roles, sent, fieldErrs)
+	}
+	validateStructValue(updatedEntity, roles, "", "", fieldErrs)
+	if err := fieldErrs.errOrNil(); err != nil {
+		return nil, unsettableFields, err
+	}
+	entityValue.Elem().Set(updatedEntity)
+	if versioned && options.incrementVersion {
+		updatedFields[version.Name] = incrementVersion(reflect.ValueOf(entity).Elem(), version)
+	}
 	return updatedFields, unsettableFields, nil
 }
 
@@ -936,21 +1153,34 @@ func UpdateStructFields(entity any, incomingEntity any, roles []string, skipZero
 //
 // Returns:
 //   - An error if the entity is not a pointer to a struct, the field is invalid, the setter is not authorized,
-//     or the value type is not convertible to the field type
+//     or the value type is not convertible to the field type. Fields with a `writexs` marker return
+//     ErrFieldReadOnly, ErrFieldSystemManaged or ErrFieldImmutable instead of ErrUnauthorizedFieldSet.
 func SetField(entity any, fieldName string, value any, skipZeroVals bool, roles []string) error {
 	// fmt.Printf("SetField: FieldName: (%s), Value: (%v)\n", fieldName, value)
 	rv := reflect.ValueOf(entity)
 	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
 		return ErrInvalidStructPointer
 	}
-	rv = rv.Elem()
+	if !rv.Elem().FieldByName(fieldName).IsValid() {
+		return ErrInvalidFieldName
+	}
+	if err := setFieldDenial(entity, fieldName, roles); err != nil {
+		return err
+	}
+	return setNamedField(rv.Elem(), fieldName, value, skipZeroVals)
+}
+
+// setNamedField sets the field of the struct value like SetField, without the authorization check.
+func setNamedField(rv reflect.Value, fieldName string, value any, skipZeroVals bool) error {
 	field := rv.FieldByName(fieldName)
 	if !field.IsValid() {
 		return ErrInvalidFieldName
 	}
-	if !IsAllowedToSetField(entity, fieldName, roles) {
-		return ErrUnauthorizedFieldSet
-	}
+	return setNonZeroField(field, value)
+}
+
+// setNonZeroField sets the field to the value like SetField; nil and zero values leave it unchanged.
+func setNonZeroField(field reflect.Value, value any) error {
 	val := reflect.ValueOf(value)
 	if (val.Kind() == reflect.Ptr && val.IsNil()) || val.IsZero() {
 		// Skip nil assignments without an error
@@ -973,6 +1203,15 @@ func setReflectField(field reflect.Value, value any) error {
 		return nil
 	} else {
 		// fmt.Printf("#notAssignableOuter Field(%s) Type: (%v) vs. Value-Type:(%v)\n", fieldName, fieldType, val.Type())
+		// strings are parsed into numbers, bools, durations, times and slices
+		if val.Kind() == reflect.String && indirectType(fieldType).Kind() != reflect.String && isStringParsable(fieldType) {
+			parsedValue, err := parseStringValue(val.String(), fieldType)
+			if err != nil {
+				return ErrInvalidFieldValue
+			}
+			field.Set(parsedValue)
+			return nil
+		}
 		if val.Kind() == reflect.Ptr && val.Type().Elem().AssignableTo(fieldType) {
 			// fmt.Printf("pointer conversion attempt for field(%s)\n", fieldName)
 			if fieldType.Kind() == reflect.Ptr {
@@ -1110,7 +1349,7 @@ func setReflectField(field reflect.Value, value any) error {
 				// fmt.Printf("#fdgf Field Type: (%v) vs. Value-Type:(%v)\n", fieldType, val.Type())
 				return ErrInvalidFieldValue
 			}
-			return ErrInvalidFieldType
+			return nil
 		}
 	}
 
@@ -1132,7 +1371,9 @@ func setReflectField(field reflect.Value, value any) error {
 	// } else {
 	// 	return ErrInvalidFieldValue
 	// }
-	return nil
+
+	// no conversion applied, so the value cannot be assigned to the field
+	return ErrInvalidFieldType
 }
 
 // IsAllowedToSetField checks if a field can be set based on the setter's role and the field's `writexs` tag.
@@ -1145,15 +1386,29 @@ func setReflectField(field reflect.Value, value any) error {
 // Returns:
 //   - A boolean indicating whether the field can be set by the given setter role
 func IsAllowedToSetField(entity any, fieldName string, roles []string) bool {
+	return setFieldDenial(entity, fieldName, roles) == nil
+}
+
+// setFieldDenial returns why the roles may not set the named field of entity (see writeDenial),
+// ErrInvalidFieldName if there is no such field, or nil.
+func setFieldDenial(entity any, fieldName string, roles []string) error {
 	typ := reflect.TypeOf(entity)
 	if typ.Kind() == reflect.Ptr {
 		typ = typ.Elem()
 	}
 	field, ok := typ.FieldByName(fieldName)
 	if !ok {
-		return false
+		return ErrInvalidFieldName
 	}
-	return IsFieldAccessAllowed(roles, field.Tag.Get(tagNameWriteXS))
+	value := reflect.Indirect(reflect.ValueOf(entity))
+	if value.Kind() != reflect.Struct {
+		// a nil pointer has no state for the `writeif` condition and the `@immutable` marker
+		if IsWriteAccessAllowed(roles, field.Tag.Get(tagNameWriteXS)) && field.Tag.Get(tagNameWriteIf) == "" {
+			return nil
+		}
+		return ErrUnauthorizedFieldSet
+	}
+	return writeDenial(value, field, roles)
 }
 
 // tryConvertInt attempts to convert an integer value from one type to another
@@ -1207,3 +1462,22 @@ func isNil(val reflect.Value) bool {
 	}
 	return false
 }
+
+// valueInterface returns the value as an interface, or nil if it cannot be interfaced (e.g. unexported fields).
+func valueInterface(val reflect.Value) any {
+	if !val.IsValid() || !val.CanInterface() {
+		return nil
+	}
+	return val.Interface()
+}
+
+// sortedMapKeys returns the keys of the map in sorted order, so that map-driven
+// operations behave (and report errors) deterministically.
+func sortedMapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
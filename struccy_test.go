@@ -314,6 +314,19 @@ func TestMergeStructUpdateTo_InvalidInput(t *testing.T) {
 	}
 }
 
+func TestMergeStructUpdateTo_UnexportedFields(t *testing.T) {
+	type Profile struct {
+		Tags   []string `writexs:"*"`
+		secret string
+	}
+	// unexported fields keep the target's values instead of panicking
+	target := &Profile{Tags: []string{"a"}, secret: "s"}
+	merged, err := MergeStructUpdateTo(target, &Profile{Tags: []string{"b"}, secret: "x"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &Profile{Tags: []string{"b"}, secret: "s"}, merged)
+	assert.Equal(t, &Profile{Tags: []string{"a"}, secret: "s"}, target)
+}
+
 func TestFilterStructTo_InvalidInput(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -1076,6 +1089,28 @@ type RoleBasedStruct struct {
 	UserField   string `writexs:"user"`
 }
 
+func TestMergeMapStringFieldsToStruct_NilValues(t *testing.T) {
+	type Profile struct {
+		Name     string            `writexs:"*"`
+		Nickname *string           `writexs:"*"`
+		Tags     []string          `writexs:"*"`
+		Settings map[string]string `writexs:"*"`
+		Extra    any               `writexs:"admin"`
+		secret   string
+	}
+	nickname := "ali"
+	// nil values and nil pointers clear nilable fields
+	target := &Profile{Name: "n", Nickname: &nickname, Tags: []string{"a"}, Settings: map[string]string{}, Extra: 1, secret: "s"}
+	_, err := MergeMapStringFieldsToStruct(target, map[string]any{"Nickname": (*string)(nil), "Tags": nil, "Settings": nil, "Extra": nil}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &Profile{Name: "n", secret: "s"}, target)
+
+	// other fields cannot be nil
+	_, err = MergeMapStringFieldsToStruct(target, map[string]any{"Name": (*string)(nil)}, nil)
+	assert.ErrorIs(t, err, ErrFieldIsNil)
+	assert.Equal(t, "n", target.Name)
+}
+
 func TestUpdateStructFields(t *testing.T) {
 	initial := &RoleBasedStruct{
 		PublicField: "initial",
@@ -1146,6 +1181,16 @@ func TestUpdateStructFieldsPartial(t *testing.T) {
 	assert.Len(t, updatedFields, 1, "Admin field should have been updated")
 }
 
+func TestUpdateStructFields_InvalidArguments(t *testing.T) {
+	updates := &RoleBasedDto{AdminField: "updated admin"}
+	_, _, err := UpdateStructFields(RoleBasedStruct{}, updates, []string{"admin"}, true, false)
+	assert.Equal(t, ErrInvalidStructPointer, err)
+	_, _, err = UpdateStructFields(nil, updates, []string{"admin"}, true, false)
+	assert.Equal(t, ErrInvalidStructPointer, err)
+	_, _, err = UpdateStructFields(&RoleBasedStruct{}, *updates, []string{"admin"}, true, false)
+	assert.Equal(t, ErrUpdateStructMustBePointer, err)
+}
+
 // TestSetField tests the SetField function
 func TestSetField(t *testing.T) {
 	type TestStruct struct {
@@ -0,0 +1,343 @@
+package struccytest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/itsatony/struccy"
+)
+
+// ErrInvariantViolated is the cause of the errors returned by the Check functions.
+var ErrInvariantViolated = errors.New("invariant violated")
+
+// maxGenerateDepth limits the nesting of generated values, so recursive types terminate.
+const maxGenerateDepth = 3
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Generate returns a new T with random values (see Fill). T must be a struct type.
+func Generate[T any](rng *rand.Rand) *T {
+	value := new(T)
+	_ = Fill(value, rng)
+	return value
+}
+
+// Fill sets the exported fields of the struct pointer to random values: numbers, strings, bools,
+// UTC times with second precision, nil or filled pointers, slices and maps with up to three entries,
+// nested structs and strings for `any` fields. Unexported fields, channels, functions and other
+// interfaces are left alone, as are values nested deeper than three levels.
+func Fill(structPtr any, rng *rand.Rand) error {
+	value := reflect.ValueOf(structPtr)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return struccy.ErrInvalidStructPointer
+	}
+	fillValue(value.Elem(), rng, 0)
+	return nil
+}
+
+func fillValue(value reflect.Value, rng *rand.Rand, depth int) {
+	switch value.Kind() {
+	case reflect.Bool:
+		value.SetBool(rng.IntN(2) == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(rng.Int64N(2001) - 1000)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		value.SetUint(rng.Uint64N(1001))
+	case reflect.Float32, reflect.Float64:
+		// finite values only, NaN would never equal itself
+		value.SetFloat(float64(rng.IntN(200001)-100000) / 100)
+	case reflect.Complex64, reflect.Complex128:
+		value.SetComplex(complex(float64(rng.IntN(201)-100), float64(rng.IntN(201)-100)))
+	case reflect.String:
+		value.SetString(randomString(rng))
+	case reflect.Ptr:
+		if depth >= maxGenerateDepth || rng.IntN(4) == 0 {
+			return
+		}
+		pointer := reflect.New(value.Type().Elem())
+		fillValue(pointer.Elem(), rng, depth+1)
+		value.Set(pointer)
+	case reflect.Slice:
+		if depth >= maxGenerateDepth || rng.IntN(4) == 0 {
+			return
+		}
+		slice := reflect.MakeSlice(value.Type(), rng.IntN(4), rng.IntN(4)+3)
+		for i := 0; i < slice.Len(); i++ {
+			fillValue(slice.Index(i), rng, depth+1)
+		}
+		value.Set(slice)
+	case reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			fillValue(value.Index(i), rng, depth+1)
+		}
+	case reflect.Map:
+		if depth >= maxGenerateDepth || rng.IntN(4) == 0 {
+			return
+		}
+		entries := rng.IntN(4)
+		mapValue := reflect.MakeMapWithSize(value.Type(), entries)
+		for i := 0; i < entries; i++ {
+			key := reflect.New(value.Type().Key()).Elem()
+			fillValue(key, rng, depth+1)
+			entry := reflect.New(value.Type().Elem()).Elem()
+			fillValue(entry, rng, depth+1)
+			mapValue.SetMapIndex(key, entry)
+		}
+		value.Set(mapValue)
+	case reflect.Interface:
+		if value.NumMethod() == 0 && rng.IntN(4) > 0 {
+			value.Set(reflect.ValueOf(randomString(rng)))
+		}
+	case reflect.Struct:
+		if value.Type() == timeType {
+			value.Set(reflect.ValueOf(time.Unix(rng.Int64N(4102444800), 0).UTC()))
+			return
+		}
+		if depth > maxGenerateDepth {
+			return
+		}
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).IsExported() {
+				fillValue(value.Field(i), rng, depth+1)
+			}
+		}
+	}
+}
+
+func randomString(rng *rand.Rand) string {
+	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 _-.@"
+	runes := make([]byte, rng.IntN(12))
+	for i := range runes {
+		runes[i] = letters[rng.IntN(len(letters))]
+	}
+	return string(runes)
+}
+
+// CheckReadXS checks that StructToMapFieldsWithReadXS only returns fields the roles may read, honoring
+// the `readif` conditions.
+func CheckReadXS(structPtr any, roles []string) error {
+	fields, err := struccy.StructToMapFieldsWithReadXS(structPtr, roles)
+	if err != nil {
+		return err
+	}
+	structType := reflect.TypeOf(structPtr).Elem()
+	for _, name := range sortedKeys(fields) {
+		field, ok := structType.FieldByName(name)
+		if !ok {
+			return fmt.Errorf("%w: StructToMapFieldsWithReadXS returned unknown field %s of %s", ErrInvariantViolated, name, structType)
+		}
+		if !struccy.IsFieldAccessAllowed(roles, field.Tag.Get("readxs")) || !struccy.FieldConditionHolds(structPtr, field.Tag.Get("readif"), roles) {
+			return fmt.Errorf("%w: StructToMapFieldsWithReadXS returned field %s of %s, which the roles %v may not read", ErrInvariantViolated, name, structType, roles)
+		}
+	}
+	return nil
+}
+
+// CheckMergeStructUpdate checks that MergeStructUpdateTo leaves the target unchanged and that the
+// merged struct keeps the target's values of the fields the roles may not write, including those whose
+// `writeif` condition does not hold for the target. A merge failing for
+// other reasons, e.g. the `validate` tags, only has to leave the target unchanged.
+func CheckMergeStructUpdate(targetStruct any, updateStruct any, roles []string) error {
+	target := reflect.ValueOf(targetStruct)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+		return struccy.ErrInvalidStructPointer
+	}
+	original := deepCopy(target.Elem())
+
+	merged, err := struccy.MergeStructUpdateTo(targetStruct, updateStruct, roles)
+	if !reflect.DeepEqual(original.Interface(), target.Elem().Interface()) {
+		return fmt.Errorf("%w: MergeStructUpdateTo modified the target %s", ErrInvariantViolated, target.Type())
+	}
+	if err != nil {
+		return nil
+	}
+
+	mergedValue := reflect.ValueOf(merged).Elem()
+	structType := target.Elem().Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() || (struccy.IsWriteAccessAllowed(roles, field.Tag.Get("writexs")) &&
+			struccy.FieldConditionHolds(original.Interface(), field.Tag.Get("writeif"), roles)) {
+			continue
+		}
+		if !reflect.DeepEqual(original.Field(i).Interface(), mergedValue.Field(i).Interface()) {
+			return fmt.Errorf("%w: MergeStructUpdateTo changed field %s of %s, which the roles %v may not write", ErrInvariantViolated, field.Name, structType, roles)
+		}
+	}
+	return nil
+}
+
+// CheckRoundTrip checks that converting the struct to a map with StructToMapFieldsWithReadXS, merging
+// the map into a new struct with MergeMapStringFieldsToStruct and converting that struct again yields
+// the same map. Maps that fail the `validate` tags are not compared, as the failed merge leaves the new
+// struct empty. Fields with a `readif` condition depending on fields missing from the map may appear or disappear.
+func CheckRoundTrip(structPtr any, roles []string) error {
+	fields, err := struccy.StructToMapFieldsWithReadXS(structPtr, roles)
+	if err != nil {
+		return err
+	}
+	fresh := reflect.New(reflect.TypeOf(structPtr).Elem()).Interface()
+	if _, err := struccy.MergeMapStringFieldsToStruct(fresh, fields, roles); err != nil {
+		if onlyValidationErrors(err) {
+			return nil
+		}
+		return fmt.Errorf("%w: MergeMapStringFieldsToStruct rejected the map of %T: %w", ErrInvariantViolated, structPtr, err)
+	}
+	roundTripped, err := struccy.StructToMapFieldsWithReadXS(fresh, roles)
+	if err != nil {
+		return err
+	}
+	structType := reflect.TypeOf(structPtr).Elem()
+	for _, name := range sortedKeys(fields) {
+		if _, ok := roundTripped[name]; !ok {
+			if field, _ := structType.FieldByName(name); !struccy.FieldConditionHolds(fresh, field.Tag.Get("readif"), roles) {
+				delete(fields, name)
+				continue
+			}
+		}
+		if !reflect.DeepEqual(fields[name], roundTripped[name]) {
+			return fmt.Errorf("%w: field %s of %T changed in the round trip from %#v to %#v", ErrInvariantViolated, name, structPtr, fields[name], roundTripped[name])
+		}
+	}
+	for _, name := range sortedKeys(roundTripped) {
+		if _, ok := fields[name]; ok {
+			continue
+		}
+		if field, _ := structType.FieldByName(name); field.Tag.Get("readif") != "" {
+			// hidden in the original by its condition
+			delete(roundTripped, name)
+		}
+	}
+	if len(fields) != len(roundTripped) {
+		return fmt.Errorf("%w: the round trip of %T changed the fields from %v to %v", ErrInvariantViolated, structPtr, sortedKeys(fields), sortedKeys(roundTripped))
+	}
+	return nil
+}
+
+// FuzzInvariants runs CheckReadXS, CheckMergeStructUpdate and CheckRoundTrip on random values of T
+// (see Generate) for random subsets of the roles (at most the first 16 are used):
+//
+//	func FuzzUser(f *testing.F) {
+//		struccytest.FuzzInvariants[User](f, []string{"admin", "self", "guest"})
+//	}
+//
+// The seed corpus runs with go test; go test -fuzz FuzzUser explores further.
+func FuzzInvariants[T any](f *testing.F, roles []string) {
+	f.Helper()
+	if err := Fill(new(T), rand.New(rand.NewPCG(0, 0))); err != nil {
+		f.Fatalf("FuzzInvariants[%T]: %v", *new(T), err)
+	}
+	for seed := int64(0); seed < 8; seed++ {
+		f.Add(seed, seed+100, uint16(seed*37))
+	}
+	f.Add(int64(8), int64(108), uint16(0xffff))
+
+	f.Fuzz(func(t *testing.T, targetSeed int64, updateSeed int64, roleMask uint16) {
+		selected := selectRoles(roles, roleMask)
+		target := Generate[T](rand.New(rand.NewPCG(uint64(targetSeed), 1)))
+		update := Generate[T](rand.New(rand.NewPCG(uint64(updateSeed), 2)))
+		if err := CheckReadXS(target, selected); err != nil {
+			t.Error(err)
+		}
+		if err := CheckMergeStructUpdate(target, update, selected); err != nil {
+			t.Error(err)
+		}
+		if err := CheckRoundTrip(target, selected); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// selectRoles returns the roles whose bit is set in the mask.
+func selectRoles(roles []string, mask uint16) []string {
+	selected := []string{}
+	for i, role := range roles {
+		if i < 16 && mask&(1<<i) != 0 {
+			selected = append(selected, role)
+		}
+	}
+	return selected
+}
+
+func onlyValidationErrors(err error) bool {
+	var fieldErrs *struccy.FieldErrors
+	if !errors.As(err, &fieldErrs) {
+		return false
+	}
+	for _, fieldErr := range fieldErrs.Errors {
+		if !errors.Is(fieldErr, struccy.ErrValidationFailed) {
+			return false
+		}
+	}
+	return true
+}
+
+// deepCopy copies the value including the values behind pointers, slices, maps and interfaces,
+// so modifications through them are noticed. Unexported struct fields are copied shallowly.
+func deepCopy(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+		pointer := reflect.New(value.Type().Elem())
+		pointer.Elem().Set(deepCopy(value.Elem()))
+		return pointer
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		slice := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			slice.Index(i).Set(deepCopy(value.Index(i)))
+		}
+		return slice
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		mapValue := reflect.MakeMapWithSize(value.Type(), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			mapValue.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return mapValue
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(deepCopy(value.Elem()))
+		return copied
+	case reflect.Array:
+		array := reflect.New(value.Type()).Elem()
+		for i := 0; i < value.Len(); i++ {
+			array.Index(i).Set(deepCopy(value.Index(i)))
+		}
+		return array
+	case reflect.Struct:
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).IsExported() {
+				copied.Field(i).Set(deepCopy(value.Field(i)))
+			}
+		}
+		return copied
+	}
+	return value
+}
+
+func sortedKeys(fields map[string]any) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,109 @@
+package struccytest
+
+import (
+	"errors"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fuzzProfile struct {
+	ID       string            `readxs:"*" writexs:"admin"`
+	Name     string            `readxs:"*" writexs:"self,admin" validate:"required"`
+	Age      int8              `readxs:"self,admin" writexs:"self"`
+	Score    float64           `readxs:"!guest" writexs:"admin"`
+	Nickname *string           `readxs:"*" writexs:"self"`
+	Tags     []string          `readxs:"*" writexs:"self"`
+	Settings map[string]string `readxs:"self" writexs:"self"`
+	Address  *testAddress      `readxs:"self,admin" writexs:"self"`
+	Extra    any               `readxs:"admin"`
+	Friends  []fuzzProfile     `readxs:"self"`
+	secret   string
+}
+
+// leakyProfile has a broken ToMapForRoles method returning a field the roles may not read.
+type leakyProfile struct {
+	ID     string `readxs:"*"`
+	Secret string `readxs:"admin"`
+}
+
+func (p *leakyProfile) ToMapForRoles(roles []string) map[string]any {
+	return map[string]any{"ID": p.ID, "Secret": p.Secret}
+}
+
+// conditionalProfile hides fields depending on other fields, some of which the roles cannot read.
+type conditionalProfile struct {
+	Private bool   `readxs:"self" writexs:"self"`
+	Email   string `readxs:"*" writexs:"self" readif:"!Private@!self" writeif:"!Private@!self"`
+	Status  string `readxs:"*" writexs:"admin"`
+	Bio     string `readxs:"*" writexs:"self" readif:"Status=active"`
+}
+
+func FuzzProfileInvariants(f *testing.F) {
+	FuzzInvariants[fuzzProfile](f, []string{"admin", "self", "guest"})
+}
+
+func FuzzConditionalProfileInvariants(f *testing.F) {
+	FuzzInvariants[conditionalProfile](f, []string{"admin", "self", "guest"})
+}
+
+func TestGenerate(t *testing.T) {
+	first := Generate[fuzzProfile](rand.New(rand.NewPCG(1, 2)))
+	second := Generate[fuzzProfile](rand.New(rand.NewPCG(1, 2)))
+	assert.Equal(t, first, second)
+	assert.Empty(t, first.secret)
+
+	filled := 0
+	for seed := uint64(0); seed < 20; seed++ {
+		profile := Generate[fuzzProfile](rand.New(rand.NewPCG(seed, 0)))
+		if profile.Address != nil && len(profile.Tags) > 0 {
+			filled++
+		}
+	}
+	assert.Greater(t, filled, 0)
+
+	assert.Error(t, Fill(fuzzProfile{}, rand.New(rand.NewPCG(0, 0))))
+}
+
+func TestCheckReadXS(t *testing.T) {
+	leaky := &leakyProfile{ID: "1", Secret: "s"}
+	assert.NoError(t, CheckReadXS(leaky, []string{"admin"}))
+	err := CheckReadXS(leaky, []string{"guest"})
+	assert.True(t, errors.Is(err, ErrInvariantViolated))
+	assert.Contains(t, err.Error(), "field Secret")
+}
+
+func TestCheckMergeStructUpdate(t *testing.T) {
+	nickname := "ali"
+	target := &fuzzProfile{ID: "1", Name: "Alice", Nickname: &nickname, Tags: []string{"a"}}
+	update := &fuzzProfile{ID: "2", Name: "Bob", Tags: []string{"b"}}
+	assert.NoError(t, CheckMergeStructUpdate(target, update, []string{"self"}))
+	assert.Equal(t, "ali", *target.Nickname)
+	assert.Error(t, CheckMergeStructUpdate(fuzzProfile{}, update, nil))
+}
+
+func TestCheckRoundTrip(t *testing.T) {
+	// nil pointers, slices, maps and interfaces survive the round trip
+	assert.NoError(t, CheckRoundTrip(&fuzzProfile{}, []string{"admin", "self"}))
+	nickname := "ali"
+	assert.NoError(t, CheckRoundTrip(&fuzzProfile{Name: "Alice", Nickname: &nickname, Extra: "x"}, []string{"admin"}))
+	// Email depends on Private, which guests cannot read
+	assert.NoError(t, CheckRoundTrip(&conditionalProfile{Private: true, Email: "a@b.c", Status: "active"}, []string{"guest"}))
+}
+
+func TestDeepCopy(t *testing.T) {
+	nickname := "ali"
+	profile := fuzzProfile{Nickname: &nickname, Tags: []string{"a"}, Settings: map[string]string{"k": "v"}, Extra: []int{1}}
+	copied := deepCopy(reflect.ValueOf(profile)).Interface().(fuzzProfile)
+	assert.Equal(t, profile, copied)
+	*profile.Nickname = "bob"
+	profile.Tags[0] = "b"
+	profile.Settings["k"] = "w"
+	profile.Extra.([]int)[0] = 2
+	assert.Equal(t, "ali", *copied.Nickname)
+	assert.Equal(t, []string{"a"}, copied.Tags)
+	assert.Equal(t, map[string]string{"k": "v"}, copied.Settings)
+	assert.Equal(t, []int{1}, copied.Extra)
+}
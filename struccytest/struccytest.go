@@ -0,0 +1,313 @@
+// Package struccytest provides test helpers snapshotting the access matrix of struct types in golden
+// files, so that tag changes widening (or narrowing) access show up as test diffs in code review:
+//
+//	func TestUserAccess(t *testing.T) {
+//		struccytest.AssertAccessMatrix(t, &User{}, []string{"admin", "self", "guest"}, "testdata/user.access")
+//	}
+//
+// Run the tests with -update to write the golden files:
+//
+//	go test ./... -run Access -update
+//
+// The matrix has the format of `struccy -type User -roles admin,self,guest` (see cmd/struccy).
+package struccytest
+
+import (
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/itsatony/struccy"
+)
+
+const updateFlag = "update"
+
+func init() {
+	// the test package may define the flag itself
+	if flag.Lookup(updateFlag) == nil {
+		flag.Bool(updateFlag, false, "update the golden files of struccytest.AssertAccessMatrix")
+	}
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// AssertAccessMatrix compares the access matrix of the struct (pointer) v for the roles (see
+// AccessMatrix) with the golden file and reports the changed fields. With the -update flag,
+// the golden file (and its directory) is written instead.
+func AssertAccessMatrix(t testing.TB, v any, roles []string, golden string) bool {
+	t.Helper()
+	actual, err := AccessMatrix(v, roles)
+	if err != nil {
+		t.Errorf("access matrix of %T: %v", v, err)
+		return false
+	}
+
+	if updating() {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Errorf("update %s: %v", golden, err)
+			return false
+		}
+		if err := os.WriteFile(golden, []byte(actual), 0o644); err != nil {
+			t.Errorf("update %s: %v", golden, err)
+			return false
+		}
+		return true
+	}
+
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Errorf("access matrix of %T: %v (run the tests with -%s to create it)", v, err, updateFlag)
+		return false
+	}
+	if diff := diffMatrix(string(expected), actual); diff != "" {
+		t.Errorf("access matrix of %T differs from %s (run the tests with -%s to accept it):\n%s", v, golden, updateFlag, diff)
+		return false
+	}
+	return true
+}
+
+// updating reports whether the -update flag is set.
+func updating() bool {
+	f := flag.Lookup(updateFlag)
+	if f == nil {
+		return false
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return f.Value.String() == "true"
+	}
+	update, _ := getter.Get().(bool)
+	return update
+}
+
+// AccessMatrix renders the access matrix of the struct (pointer) v: a row per field and a column per
+// role, with R if the role may read the field (`readxs`), W if it may write it (`writexs`) and - if
+// neither. Fields are named and embedded structs promoted like encoding/json. The fields of nested
+// structs (also behind pointers, slices, arrays and maps) follow their parent in dot notation and are
+// only accessible if every field along the path allows it, as for struccy.IsAllowedPath. Types with
+// their own JSON or text encoding, like time.Time, and recursive types are not expanded.
+func AccessMatrix(v any, roles []string) (string, error) {
+	typ := reflect.TypeOf(v)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return "", struccy.ErrInvalidStructPointer
+	}
+
+	var builder strings.Builder
+	tw := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(append([]string{"FIELD", "TYPE"}, roles...), "\t"))
+	collector := &matrixCollector{roles: roles, ancestors: map[reflect.Type]bool{}}
+	if typ.PkgPath() != "" {
+		collector.qualifier = regexp.MustCompile(`\b` + regexp.QuoteMeta(path.Base(typ.PkgPath())) + `\.`)
+	}
+	collector.collect(typ, "", &pathTags{})
+	for _, row := range collector.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+type matrixCollector struct {
+	roles     []string
+	qualifier *regexp.Regexp // matches the package qualifier of the matrix type
+	ancestors map[reflect.Type]bool
+	rows      [][]string
+}
+
+// pathTags are the access tags of the fields along a path.
+type pathTags struct {
+	read  []string
+	write []string
+}
+
+func (c *matrixCollector) collect(structType reflect.Type, prefix string, parent *pathTags) {
+	if c.ancestors[structType] {
+		return
+	}
+	c.ancestors[structType] = true
+	defer delete(c.ancestors, structType)
+
+	for _, field := range jsonFields(structType) {
+		name := jsonName(field)
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		tags := &pathTags{
+			read:  append(append([]string{}, parent.read...), field.Tag.Get("readxs")),
+			write: append(append([]string{}, parent.write...), field.Tag.Get("writexs")),
+		}
+		typeName := field.Type.String()
+		if c.qualifier != nil {
+			typeName = c.qualifier.ReplaceAllString(typeName, "")
+		}
+		row := []string{name, typeName}
+		for _, role := range c.roles {
+			row = append(row, cell(role, tags))
+		}
+		c.rows = append(c.rows, row)
+		if nested := nestedStruct(field.Type); nested != nil {
+			c.collect(nested, name, tags)
+		}
+	}
+}
+
+// cell returns R, W, RW or - for the role.
+func cell(role string, tags *pathTags) string {
+	access := ""
+	if allowed(role, tags.read, struccy.IsFieldAccessAllowed) {
+		access += "R"
+	}
+	if allowed(role, tags.write, struccy.IsWriteAccessAllowed) {
+		access += "W"
+	}
+	if access == "" {
+		return "-"
+	}
+	return access
+}
+
+func allowed(role string, tags []string, allow func(roles []string, tagValue string) bool) bool {
+	for _, tag := range tags {
+		if !allow([]string{role}, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonFields returns the fields encoding/json encodes, in order: `json:"-"` fields are skipped and
+// the fields of embedded structs without a tag name are promoted, the shallowest field winning a name.
+func jsonFields(structType reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	taken := map[string]bool{}
+	next := []reflect.Type{structType}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		var level []reflect.StructField
+		for _, t := range current {
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				tag := field.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				tagName, _, _ := strings.Cut(tag, ",")
+				fieldType := field.Type
+				if fieldType.Kind() == reflect.Ptr {
+					fieldType = fieldType.Elem()
+				}
+				if field.Anonymous && tagName == "" && fieldType.Kind() == reflect.Struct {
+					next = append(next, fieldType)
+					continue
+				}
+				if !field.IsExported() || taken[jsonName(field)] {
+					continue
+				}
+				level = append(level, field)
+			}
+		}
+		for _, field := range level {
+			taken[jsonName(field)] = true
+		}
+		fields = append(fields, level...)
+	}
+	return fields
+}
+
+func jsonName(field reflect.StructField) string {
+	if tagName, _, _ := strings.Cut(field.Tag.Get("json"), ","); tagName != "" {
+		return tagName
+	}
+	return field.Name
+}
+
+// nestedStruct returns the struct type whose fields are listed below a field of type t, looking
+// through pointers, slices, arrays and map values.
+func nestedStruct(t reflect.Type) reflect.Type {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		case reflect.Struct:
+			pointer := reflect.PointerTo(t)
+			if pointer.Implements(jsonMarshalerType) || pointer.Implements(textMarshalerType) {
+				return nil
+			}
+			return t
+		default:
+			return nil
+		}
+	}
+}
+
+// diffMatrix returns the changed rows of the matrices, keyed by field, or "" if they are equal.
+func diffMatrix(expected string, actual string) string {
+	if expected == actual {
+		return ""
+	}
+	expectedRows, expectedOrder := matrixRows(expected)
+	actualRows, actualOrder := matrixRows(actual)
+	var diff strings.Builder
+	write := func(prefix string, row string) {
+		diff.WriteString(prefix + row + "\n")
+	}
+	if expectedRows[""] != actualRows[""] {
+		write("- ", expectedRows[""])
+		write("+ ", actualRows[""])
+	}
+	for _, field := range expectedOrder {
+		if row, ok := actualRows[field]; !ok {
+			write("- ", expectedRows[field])
+		} else if normalize(row) != normalize(expectedRows[field]) {
+			write("- ", expectedRows[field])
+			write("+ ", row)
+		}
+	}
+	for _, field := range actualOrder {
+		if _, ok := expectedRows[field]; !ok {
+			write("+ ", actualRows[field])
+		}
+	}
+	if diff.Len() == 0 {
+		return ""
+	}
+	return diff.String()
+}
+
+// matrixRows splits the matrix into rows keyed by field; the header has the key "".
+func matrixRows(matrix string) (map[string]string, []string) {
+	rows := map[string]string{}
+	var order []string
+	for i, line := range strings.Split(strings.TrimRight(matrix, "\n"), "\n") {
+		field := ""
+		if i > 0 {
+			field = strings.Fields(line + " ")[0]
+			order = append(order, field)
+		}
+		rows[field] = strings.TrimRight(line, " ")
+	}
+	return rows, order
+}
+
+// normalize ignores the column alignment.
+func normalize(row string) string {
+	return strings.Join(strings.Fields(row), " ")
+}
@@ -0,0 +1,92 @@
+package struccytest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testAddress struct {
+	City string `json:"city" readxs:"*" writexs:"*"`
+	Note string `json:"note" readxs:"admin" writexs:"admin"`
+}
+
+type testAudit struct {
+	Revision int `json:"revision" readxs:"admin"`
+}
+
+type testUser struct {
+	ID        string       `json:"id" readxs:"*" writexs:"admin"`
+	Email     string       `json:"email" readxs:"self,admin" writexs:"self"`
+	Password  string       `json:"-" writexs:"self"`
+	CreatedAt time.Time    `json:"createdAt" readxs:"*"`
+	Address   *testAddress `json:"address" readxs:"!guest" writexs:"self,admin"`
+	Friends   []testUser   `json:"friends" readxs:"self"`
+	testAudit
+	internal string
+}
+
+// recordingT records the failures of the assertions.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAccessMatrix(t *testing.T) {
+	matrix, err := AccessMatrix(&testUser{}, []string{"admin", "self", "guest"})
+	assert.NoError(t, err)
+	// Password is `json:"-"`, friends is not expanded again and the testAudit fields are promoted
+	assert.Equal(t, `FIELD         TYPE          admin  self  guest
+id            string        RW     R     R
+email         string        R      RW    -
+createdAt     time.Time     R      R     R
+address       *testAddress  RW     RW    -
+address.city  string        RW     RW    -
+address.note  string        RW     -     -
+friends       []testUser    -      R     -
+revision      int           R      -     -
+`, matrix)
+
+	_, err = AccessMatrix("user", nil)
+	assert.Error(t, err)
+}
+
+func TestAssertAccessMatrix(t *testing.T) {
+	assert.True(t, AssertAccessMatrix(t, &testUser{}, []string{"admin", "self", "guest"}, "testdata/user.access"))
+
+	update := flag.Lookup(updateFlag).Value.String()
+	t.Cleanup(func() { _ = flag.Set(updateFlag, update) })
+	assert.NoError(t, flag.Set(updateFlag, "false"))
+
+	golden := filepath.Join(t.TempDir(), "access", "user.access")
+	recorder := &recordingT{TB: t}
+	assert.False(t, AssertAccessMatrix(recorder, &testUser{}, []string{"admin"}, golden))
+	assert.Contains(t, recorder.errors[0], "run the tests with -update to create it")
+
+	assert.NoError(t, flag.Set(updateFlag, "true"))
+	assert.True(t, AssertAccessMatrix(t, &testUser{}, []string{"admin"}, golden))
+	assert.NoError(t, flag.Set(updateFlag, "false"))
+	assert.True(t, AssertAccessMatrix(t, testUser{}, []string{"admin"}, golden))
+
+	// widening the access of a single field shows up as a diff of its row
+	content, err := os.ReadFile(golden)
+	assert.NoError(t, err)
+	narrowed := strings.Replace(string(content), "revision      int           R", "revision      int           -", 1)
+	assert.NoError(t, os.WriteFile(golden, []byte(narrowed), 0o644))
+	recorder = &recordingT{TB: t}
+	assert.False(t, AssertAccessMatrix(recorder, &testUser{}, []string{"admin"}, golden))
+	assert.Len(t, recorder.errors, 1)
+	assert.True(t, strings.HasSuffix(recorder.errors[0], ":\n- revision      int           -\n+ revision      int           R\n"))
+}
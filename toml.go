@@ -0,0 +1,65 @@
+package struccy
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/BurntSushi/toml"
+)
+
+// StructToTOMLWithReadXS converts the specified struct pointer to a TOML document,
+// including only the fields with read access allowed based on the provided xsList.
+// Keys follow the `toml` tag (name, `-`, `omitempty`, `omitzero`); fields without a tag name
+// are keyed by their Go name like github.com/BurntSushi/toml does, unless another naming
+// is selected with WithFieldNaming. Nested values are encoded as a whole, just like
+// StructToJSONFieldsWithReadXS. Nil values are left out, as TOML has no null.
+//
+// If the provided `structPtr` is not a pointer to a struct, the function returns
+// an error (`ErrInvalidStructPointer`).
+func StructToTOMLWithReadXS(structPtr any, xsList []string, opts ...ConvertOption) (string, error) {
+	structValue := reflect.ValueOf(structPtr)
+	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
+		return "", ErrInvalidStructPointer
+	}
+
+	fieldMap := make(map[string]any)
+	include := func(plan *fieldPlan) bool {
+		return plan.allowed(structValue.Elem(), xsList, OpRead)
+	}
+	eachIncludedField(structValue.Elem(), tagKeyTOML, tomlFieldNaming(opts), true, include, func(plan *fieldPlan, value reflect.Value) {
+		fieldMap[plan.name] = value.Interface()
+	})
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(fieldMap); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// DecodeTOMLWithWriteXS decodes a TOML document into the target struct pointer, setting only
+// the fields with write access allowed based on the provided xsList; other keys are ignored,
+// as are keys without a matching field. Keys are matched like in StructToTOMLWithReadXS, falling
+// back to a case-insensitive match like github.com/BurntSushi/toml does.
+//
+// Fields that cannot be decoded do not abort the decoding; they are returned together as a
+// *FieldErrors with ErrInvalidFieldValue as cause, along with the failures of the `validate` tags.
+func DecodeTOMLWithWriteXS(data []byte, targetStruct any, xsList []string, opts ...ConvertOption) error {
+	var doc map[string]toml.Primitive
+	meta, err := toml.Decode(string(data), &doc)
+	if err != nil {
+		return err
+	}
+	return decodeFieldsWithWriteXS(targetStruct, xsList, tagKeyTOML, tomlFieldNaming(opts), true, sortedMapKeys(doc), func(key string, target any) error {
+		return meta.PrimitiveDecode(doc[key], target)
+	})
+}
+
+// tomlFieldNaming returns the naming selected with WithFieldNaming, defaulting to the BurntSushi/toml naming.
+func tomlFieldNaming(opts []ConvertOption) FieldNaming {
+	options := newConvertOptions(opts)
+	if !options.namingSet {
+		return JSONFieldNames
+	}
+	return options.naming
+}
@@ -0,0 +1,65 @@
+package struccy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStructToTOMLWithReadXS(t *testing.T) {
+	settings := &tenantSettings{
+		Name:     "Acme",
+		Plan:     "pro",
+		APIKey:   "secret",
+		Limits:   tenantLimits{Users: 10, Storage: 5},
+		Internal: "x",
+		MaxSeats: 3,
+	}
+
+	// unlike yaml, the embedded tenantBase is promoted without an inline option
+	tomlStr, err := StructToTOMLWithReadXS(settings, []string{"viewer"})
+	assert.NoError(t, err)
+	assert.Equal(t, "MaxSeats = 3\nid = \"\"\nname = \"Acme\"\nplan = \"pro\"\n", tomlStr)
+
+	tomlStr, err = StructToTOMLWithReadXS(settings, []string{"owner"}, WithFieldNaming(SnakeCaseFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, "id = \"\"\nmax_seats = 3\nname = \"Acme\"\nplan = \"pro\"\n\n[limits]\n  users = 10\n  storage = 5\n", tomlStr)
+
+	_, err = StructToTOMLWithReadXS(nil, nil)
+	assert.Equal(t, ErrInvalidStructPointer, err)
+}
+
+func TestDecodeTOMLWithWriteXS(t *testing.T) {
+	data := []byte(`
+name = "Globex"
+plan = "enterprise"
+maxseats = 50
+api_key = "stolen"
+
+[limits]
+users = 1000
+`)
+	settings := &tenantSettings{Plan: "free"}
+	err := DecodeTOMLWithWriteXS(data, settings, []string{"owner"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Globex", settings.Name)
+	assert.Equal(t, "free", settings.Plan)
+	assert.Equal(t, "", settings.APIKey)
+	assert.Equal(t, 50, settings.MaxSeats) // matched case-insensitively
+	assert.Equal(t, tenantLimits{}, settings.Limits)
+
+	err = DecodeTOMLWithWriteXS(data, settings, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, "enterprise", settings.Plan)
+	assert.Equal(t, "stolen", settings.APIKey)
+	assert.Equal(t, 1000, settings.Limits.Users)
+
+	err = DecodeTOMLWithWriteXS([]byte(`maxseats = "many"`), &tenantSettings{Name: "x"}, []string{"owner"})
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 1, fieldErrs.Len())
+	assert.True(t, errors.Is(err, ErrInvalidFieldValue))
+
+	assert.Error(t, DecodeTOMLWithWriteXS([]byte(`name = `), settings, nil))
+}
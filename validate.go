@@ -0,0 +1,254 @@
+package struccy
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const tagNameValidate = "validate"
+
+var ErrValidationFailed = errors.New("validation failed")
+
+// validationRule is a single parsed entry of a `validate` tag, e.g. `min=3` or `required@!admin`.
+type validationRule struct {
+	name  string
+	param string
+	xs    string // role spec the rule applies to (IsFieldAccessAllowed syntax), empty for all roles
+	re    *regexp.Regexp
+}
+
+// validationRules caches the parsed rules per tag value.
+var validationRules sync.Map // map[string][]validationRule
+
+// roleSpecPattern matches the role spec suffix of a rule, e.g. the `!admin|user` in `required@!admin|user`.
+var roleSpecPattern = regexp.MustCompile(`^[!*]?[\w.:-]*(\|[!*]?[\w.:-]*)*$`)
+
+// ValidateStruct evaluates the `validate` tags of the given struct pointer for the provided roles.
+// Nested structs (and non-nil pointers to structs) are validated recursively.
+//
+// Supported rules (comma separated):
+//   - required: the value must not be the zero value (pointers must not be nil)
+//   - omitempty: skip all other rules if the value is the zero value
+//   - min=N, max=N: bounds for numbers, or for the length of strings, slices and maps
+//   - len=N: exact length of strings, slices and maps (or exact value for numbers)
+//   - regex=EXPR: the string value must match the regular expression (EXPR must not contain commas,
+//     and an `@` in EXPR must not be followed by something that looks like a role spec)
+//   - oneof=a b c: the value must be one of the space separated options
+//   - email, url: the string value must be a valid email address / absolute URL
+//   - dive: all following rules are applied to each element of a slice, array or map
+//
+// A rule can be restricted to certain roles by appending `@` and a role spec in the same
+// syntax as readxs/writexs, using `|` instead of commas, e.g. `required@!admin` or `max=10@user|guest`.
+// Unknown rules are ignored, so tags shared with other validators keep working.
+//
+// All failing fields are returned together as a *FieldErrors with ErrValidationFailed as cause.
+func ValidateStruct(structPtr any, roles []string) error {
+	structValue := reflect.ValueOf(structPtr)
+	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	fieldErrs := &FieldErrors{}
+	validateStructValue(structValue.Elem(), roles, "", "", fieldErrs)
+	return fieldErrs.errOrNil()
+}
+
+func validateStructValue(structValue reflect.Value, roles []string, pathPrefix string, jsonPrefix string, fieldErrs *FieldErrors) {
+	validateStructFields(structValue, roles, pathPrefix, jsonPrefix, fieldErrs, make(map[uintptr]bool))
+}
+
+// validateStructFields validates the struct value; ancestors holds the struct pointers on the current path,
+// so that cyclic pointers are validated only once.
+func validateStructFields(structValue reflect.Value, roles []string, pathPrefix string, jsonPrefix string, fieldErrs *FieldErrors, ancestors map[uintptr]bool) {
+	structType := structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		path := pathPrefix + field.Name
+		jsonPath := jsonPrefix + jsonFieldName(field)
+		value := structValue.Field(i)
+
+		if tag := field.Tag.Get(tagNameValidate); tag != "" {
+			validateValue(value, parseValidationRules(tag), roles, func(rule validationRule, offending reflect.Value) {
+				fieldErrs.Add(&FieldError{
+					Path:     path,
+					JSONName: jsonPath,
+					Cause:    ErrValidationFailed,
+					Expected: field.Type,
+					Actual:   offending.Type(),
+					Value:    valueInterface(offending),
+					Rule:     rule.String(),
+				})
+			})
+		}
+
+		if value.Kind() == reflect.Ptr && !value.IsNil() && value.Elem().Kind() == reflect.Struct {
+			pointer := value.Pointer()
+			if ancestors[pointer] {
+				continue
+			}
+			ancestors[pointer] = true
+			validateStructFields(value.Elem(), roles, path+".", jsonPath+".", fieldErrs, ancestors)
+			delete(ancestors, pointer)
+		} else if value.Kind() == reflect.Struct {
+			validateStructFields(value, roles, path+".", jsonPath+".", fieldErrs, ancestors)
+		}
+	}
+}
+
+// validateValue applies the rules to the value and calls fail for every rule that is violated.
+func validateValue(value reflect.Value, rules []validationRule, roles []string, fail func(validationRule, reflect.Value)) {
+	omitEmpty := false
+	for i, rule := range rules {
+		if rule.xs != "" && !IsFieldAccessAllowed(roles, rule.xs) {
+			continue
+		}
+		switch rule.name {
+		case "omitempty":
+			omitEmpty = true
+			continue
+		case "required":
+			if value.IsZero() {
+				fail(rule, value)
+				return
+			}
+			continue
+		}
+
+		if omitEmpty && value.IsZero() {
+			return
+		}
+		// all other rules work on the value behind the pointer
+		actual := value
+		for actual.Kind() == reflect.Ptr {
+			if actual.IsNil() {
+				return
+			}
+			actual = actual.Elem()
+		}
+
+		if rule.name == "dive" {
+			switch actual.Kind() {
+			case reflect.Slice, reflect.Array:
+				for j := 0; j < actual.Len(); j++ {
+					validateValue(actual.Index(j), rules[i+1:], roles, fail)
+				}
+			case reflect.Map:
+				iter := actual.MapRange()
+				for iter.Next() {
+					validateValue(iter.Value(), rules[i+1:], roles, fail)
+				}
+			}
+			return
+		}
+
+		if !rule.check(actual) {
+			fail(rule, value)
+		}
+	}
+}
+
+// check reports whether the (non-pointer) value satisfies the rule.
+func (rule validationRule) check(value reflect.Value) bool {
+	switch rule.name {
+	case "min", "max", "len":
+		limit, err := strconv.ParseFloat(rule.param, 64)
+		if err != nil {
+			return false
+		}
+		measured, ok := measureValue(value)
+		if !ok {
+			return false
+		}
+		switch rule.name {
+		case "min":
+			return measured >= limit
+		case "max":
+			return measured <= limit
+		default:
+			return measured == limit
+		}
+	case "regex":
+		return value.Kind() == reflect.String && rule.re != nil && rule.re.MatchString(value.String())
+	case "oneof":
+		actual := fmt.Sprint(value.Interface())
+		for _, option := range strings.Fields(rule.param) {
+			if actual == option {
+				return true
+			}
+		}
+		return false
+	case "email":
+		if value.Kind() != reflect.String {
+			return false
+		}
+		address, err := mail.ParseAddress(value.String())
+		return err == nil && address.Address == value.String()
+	case "url":
+		if value.Kind() != reflect.String {
+			return false
+		}
+		parsed, err := url.ParseRequestURI(value.String())
+		return err == nil && parsed.Scheme != "" && parsed.Host != ""
+	}
+	// unknown rules are left to other validators
+	return true
+}
+
+func (rule validationRule) String() string {
+	if rule.param == "" {
+		return rule.name
+	}
+	return rule.name + "=" + rule.param
+}
+
+// measureValue returns the numeric value for numbers and the length for strings, slices, arrays and maps.
+func measureValue(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true
+	}
+	return 0, false
+}
+
+func parseValidationRules(tag string) []validationRule {
+	if cached, ok := validationRules.Load(tag); ok {
+		return cached.([]validationRule)
+	}
+	rules := make([]validationRule, 0)
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		rule := validationRule{}
+		if idx := strings.LastIndex(part, "@"); idx >= 0 && roleSpecPattern.MatchString(part[idx+1:]) {
+			rule.xs = strings.ReplaceAll(part[idx+1:], "|", ",")
+			part = part[:idx]
+		}
+		rule.name, rule.param, _ = strings.Cut(part, "=")
+		if rule.name == "regex" {
+			rule.re, _ = regexp.Compile(rule.param)
+		}
+		rules = append(rules, rule)
+	}
+	validationRules.Store(tag, rules)
+	return rules
+}
@@ -0,0 +1,155 @@
+package struccy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type validatedAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"omitempty,len=5,regex=^[0-9]+$"`
+}
+
+type validatedUser struct {
+	Name     string            `json:"name" writexs:"*" validate:"required,min=2,max=10"`
+	Email    string            `json:"email" writexs:"*" validate:"omitempty,email"`
+	Website  *string           `json:"website" writexs:"*" validate:"omitempty,url"`
+	Role     string            `json:"role" writexs:"*" validate:"oneof=user admin"`
+	Age      int               `json:"age" writexs:"*" validate:"min=18@!admin"`
+	Tags     []string          `json:"tags" writexs:"*" validate:"max=3,dive,min=2"`
+	Address  *validatedAddress `json:"address" writexs:"*"`
+	Nickname string            `json:"nickname" writexs:"*" validate:"unique"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	valid := &validatedUser{
+		Name:    "Jane",
+		Email:   "jane@example.com",
+		Website: stringPtr("https://example.com"),
+		Role:    "user",
+		Age:     30,
+		Tags:    []string{"go", "dev"},
+		Address: &validatedAddress{City: "Berlin", Zip: "10115"},
+	}
+	assert.NoError(t, ValidateStruct(valid, []string{"user"}))
+
+	invalid := &validatedUser{
+		Name:    "J",
+		Email:   "not-an-email",
+		Website: stringPtr("example.com"),
+		Role:    "owner",
+		Age:     12,
+		Tags:    []string{"go", "x"},
+		Address: &validatedAddress{Zip: "abcde"},
+	}
+	err := ValidateStruct(invalid, []string{"user"})
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.True(t, errors.Is(err, ErrValidationFailed))
+
+	failed := make(map[string]string)
+	for _, fe := range fieldErrs.Errors {
+		failed[fe.JSONName] = fe.Rule
+	}
+	assert.Equal(t, map[string]string{
+		"name":         "min=2",
+		"email":        "email",
+		"website":      "url",
+		"role":         "oneof=user admin",
+		"age":          "min=18",
+		"tags":         "min=2",
+		"address.city": "required",
+		"address.zip":  "regex=^[0-9]+$",
+	}, failed)
+}
+
+func TestValidateStruct_RoleSpecificRules(t *testing.T) {
+	user := &validatedUser{Name: "Admin", Role: "admin", Age: 5}
+	assert.NoError(t, ValidateStruct(user, []string{"admin"}))
+	assert.Error(t, ValidateStruct(user, []string{"user"}))
+}
+
+func TestValidateStruct_InvalidInput(t *testing.T) {
+	assert.Equal(t, ErrInvalidStructPointer, ValidateStruct(validatedUser{}, nil))
+}
+
+func TestMergeMapStringFieldsToStruct_Validation(t *testing.T) {
+	user := &validatedUser{Name: "Jane", Role: "user", Age: 30}
+	_, err := MergeMapStringFieldsToStruct(user, map[string]any{
+		"Name": "A very long name",
+		"Age":  "thirty",
+	}, []string{"user"})
+
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 2, fieldErrs.Len())
+	assert.Equal(t, ErrFieldTypeMismatch, fieldErrs.Errors[0].Cause)
+	assert.Equal(t, "Age", fieldErrs.Errors[0].Path)
+	assert.Equal(t, ErrValidationFailed, fieldErrs.Errors[1].Cause)
+	assert.Equal(t, "Name", fieldErrs.Errors[1].Path)
+	assert.Equal(t, "max=10", fieldErrs.Errors[1].Rule)
+	// a failed merge leaves the struct unchanged
+	assert.Equal(t, &validatedUser{Name: "Jane", Role: "user", Age: 30}, user)
+
+	user.Address = &validatedAddress{City: "Berlin"}
+	_, err = MergeMapStringFieldsToStruct(user, map[string]any{
+		"Website": "https://example.com",
+		"Zip":     "1",
+		"Address": validatedAddress{Zip: "1"},
+	}, []string{"user"})
+	assert.True(t, errors.Is(err, ErrValidationFailed))
+	assert.Nil(t, user.Website)
+	assert.Equal(t, &validatedAddress{City: "Berlin"}, user.Address)
+}
+
+func TestMergeStructUpdateTo_Validation(t *testing.T) {
+	type Update struct {
+		Role *string `writexs:"*"`
+	}
+	user := &validatedUser{Name: "Jane", Role: "user", Age: 30}
+
+	merged, err := MergeStructUpdateTo(user, &Update{Role: stringPtr("admin")}, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", merged.(*validatedUser).Role)
+
+	merged, err = MergeStructUpdateTo(user, &Update{Role: stringPtr("root")}, []string{"user"})
+	assert.Nil(t, merged)
+	assert.True(t, errors.Is(err, ErrValidationFailed))
+	assert.Equal(t, "user", user.Role)
+}
+
+func TestUpdateStructFields_Validation(t *testing.T) {
+	type Incoming struct {
+		Email string
+	}
+	user := &validatedUser{Name: "Jane", Role: "user", Age: 30}
+	updated, _, err := UpdateStructFields(user, &Incoming{Email: "broken"}, []string{"user"}, true, false)
+	assert.Nil(t, updated)
+	assert.True(t, errors.Is(err, ErrValidationFailed))
+	assert.Equal(t, &validatedUser{Name: "Jane", Role: "user", Age: 30}, user)
+}
+
+func TestValidateStruct_CyclicPointers(t *testing.T) {
+	type node struct {
+		Name string `validate:"required"`
+		Next *node
+	}
+	first := &node{Name: "first"}
+	second := &node{Next: first}
+	first.Next = second
+
+	err := ValidateStruct(first, nil)
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 1, fieldErrs.Len())
+	assert.Equal(t, "Next.Name", fieldErrs.Errors[0].Path)
+
+	second.Name = "second"
+	assert.NoError(t, ValidateStruct(first, nil))
+	_, err = MergeMapStringFieldsToStruct(first, map[string]any{"Name": "updated"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", first.Name)
+	assert.Same(t, first, first.Next.Next)
+}
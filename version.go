@@ -0,0 +1,100 @@
+package struccy
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const tagNameVersion = "version"
+
+var (
+	ErrVersionConflict     = errors.New("version conflict")
+	ErrInvalidVersionField = errors.New("invalid version field")
+)
+
+// VersionConflictError is returned by the merges with WithVersionCheck if the version of the update
+// does not match the version of the target. errors.Is matches it against ErrVersionConflict.
+type VersionConflictError struct {
+	Field    string   // Go name of the version field
+	Current  any      // version of the target
+	Incoming any      // version the update is based on, nil if the update has none
+	Fields   []string // Go names of the writable fields whose update differs from the target
+}
+
+func (e *VersionConflictError) Error() string {
+	msg := fmt.Sprintf("%v: field '%s' is %v, update is based on %v", ErrVersionConflict, e.Field, e.Current, e.Incoming)
+	if len(e.Fields) > 0 {
+		msg += fmt.Sprintf(", conflicting fields: %s", strings.Join(e.Fields, ", "))
+	}
+	return msg
+}
+
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
+
+// versionField returns the field of the struct type tagged `version`, which the merges never take from the
+// update. If the options check or increment the version, it returns ErrInvalidVersionField if several fields
+// are tagged, the tagged field is not an integer, or no field is tagged; otherwise the first tagged field is used.
+func versionField(structType reflect.Type, options *mergeOptions) (reflect.StructField, bool, error) {
+	var version reflect.StructField
+	var invalid error
+	found := false
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if _, ok := field.Tag.Lookup(tagNameVersion); !ok || !field.IsExported() {
+			continue
+		}
+		if found {
+			if invalid == nil {
+				invalid = fmt.Errorf("%w: both %s and %s are tagged", ErrInvalidVersionField, version.Name, field.Name)
+			}
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			invalid = fmt.Errorf("%w: %s must be an integer, got %v", ErrInvalidVersionField, field.Name, field.Type)
+		}
+		version, found = field, true
+	}
+	if !options.checkVersion && !options.incrementVersion {
+		return version, found, nil
+	}
+	if !found {
+		return version, false, fmt.Errorf("%w: no field of %v is tagged", ErrInvalidVersionField, structType)
+	}
+	return version, found, invalid
+}
+
+// checkVersion returns a *VersionConflictError if the incoming version, assigned like in
+// MergeMapStringFieldsToStruct, differs from the version field of structValue. The conflicting
+// fields are only computed on a conflict.
+func checkVersion(structValue reflect.Value, version reflect.StructField, incoming reflect.Value, conflictingFields func() []string) error {
+	current := structValue.FieldByIndex(version.Index)
+	if !changesField(current, func(attempt reflect.Value) error {
+		return assignValueToField(attempt, incoming)
+	}) {
+		return nil
+	}
+	return &VersionConflictError{
+		Field:    version.Name,
+		Current:  current.Interface(),
+		Incoming: valueInterface(incoming),
+		Fields:   conflictingFields(),
+	}
+}
+
+// incrementVersion adds one to the version field of structValue and returns the new version.
+func incrementVersion(structValue reflect.Value, version reflect.StructField) any {
+	field := structValue.FieldByIndex(version.Index)
+	if field.CanInt() {
+		field.SetInt(field.Int() + 1)
+	} else {
+		field.SetUint(field.Uint() + 1)
+	}
+	return field.Interface()
+}
@@ -0,0 +1,108 @@
+package struccy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type versionedDoc struct {
+	ID       string `json:"id" readxs:"*" writexs:"*,@immutable"`
+	Title    string `json:"title" readxs:"*" writexs:"*"`
+	Body     string `json:"body" readxs:"*" writexs:"*"`
+	Secret   string `json:"secret" readxs:"admin" writexs:"admin"`
+	Revision uint   `json:"revision" readxs:"*" writexs:"*" version:""`
+}
+
+func TestMergeStructUpdateToVersion(t *testing.T) {
+	target := &versionedDoc{ID: "1", Title: "a", Body: "b", Revision: 3}
+	check := []MergeOption{WithVersionCheck(), WithVersionIncrement()}
+
+	merged, err := MergeStructUpdateTo(target, &versionedDoc{ID: "1", Title: "new", Body: "b", Revision: 3}, nil, check...)
+	assert.NoError(t, err)
+	assert.Equal(t, &versionedDoc{ID: "1", Title: "new", Body: "b", Revision: 4}, merged)
+	assert.Equal(t, uint(3), target.Revision)
+
+	// a stale update reports the writable fields it would change
+	_, err = MergeStructUpdateTo(merged, &versionedDoc{ID: "1", Title: "other", Body: "c", Secret: "s", Revision: 3}, []string{"user"}, check...)
+	assert.True(t, errors.Is(err, ErrVersionConflict))
+	var conflict *VersionConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.Equal(t, "Revision", conflict.Field)
+	assert.Equal(t, uint(4), conflict.Current)
+	assert.Equal(t, uint(3), conflict.Incoming)
+	assert.Equal(t, []string{"Title", "Body"}, conflict.Fields)
+	assert.Equal(t, "version conflict: field 'Revision' is 4, update is based on 3, conflicting fields: Title, Body", err.Error())
+
+	// without the options the version is neither checked nor taken from the update
+	merged, err = MergeStructUpdateTo(target, &versionedDoc{ID: "1", Title: "x", Revision: 9}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), merged.(*versionedDoc).Revision)
+	assert.Equal(t, "x", merged.(*versionedDoc).Title)
+}
+
+func TestMergeMapStringFieldsToStructVersion(t *testing.T) {
+	target := &versionedDoc{ID: "1", Title: "a", Revision: 3}
+
+	// JSON numbers are converted to the version type
+	_, err := MergeMapStringFieldsToStruct(target, map[string]any{"Title": "b", "Revision": float64(3)}, nil, WithVersionCheck(), WithVersionIncrement())
+	assert.NoError(t, err)
+	assert.Equal(t, &versionedDoc{ID: "1", Title: "b", Revision: 4}, target)
+
+	_, err = MergeMapStringFieldsToStruct(target, map[string]any{"Title": "c", "ID": "2", "Body": "b"}, nil, WithVersionCheck())
+	var conflict *VersionConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.Nil(t, conflict.Incoming, "a missing version is a conflict")
+	assert.Equal(t, []string{"Body", "Title"}, conflict.Fields, "the immutable ID is no conflict")
+	assert.Equal(t, &versionedDoc{ID: "1", Title: "b", Revision: 4}, target)
+
+	_, err = MergeMapStringFieldsToStruct(target, map[string]any{"Revision": 7}, nil, WithVersionIncrement())
+	assert.NoError(t, err)
+	assert.Equal(t, uint(5), target.Revision)
+}
+
+func TestUpdateStructFieldsVersion(t *testing.T) {
+	entity := &versionedDoc{ID: "1", Title: "a", Revision: 3}
+
+	updated, _, err := UpdateStructFields(entity, &versionedDoc{Title: "b", Revision: 3}, nil, false, false, WithVersionCheck(), WithVersionIncrement())
+	assert.NoError(t, err)
+	assert.Equal(t, "b", updated["Title"])
+	assert.Equal(t, uint(4), updated["Revision"])
+
+	// a zero version is compared as well
+	_, _, err = UpdateStructFields(entity, &versionedDoc{Title: "c", Secret: "s"}, []string{"user"}, false, false, WithVersionCheck())
+	var conflict *VersionConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.Equal(t, []string{"Title"}, conflict.Fields)
+	assert.Equal(t, "b", entity.Title)
+}
+
+func TestInvalidVersionField(t *testing.T) {
+	type twoVersions struct {
+		A int `version:""`
+		B int `version:""`
+	}
+	type stringVersion struct {
+		V string `version:""`
+	}
+	_, err := MergeStructUpdateTo(&twoVersions{}, &twoVersions{}, nil, WithVersionIncrement())
+	assert.True(t, errors.Is(err, ErrInvalidVersionField))
+	_, err = MergeMapStringFieldsToStruct(&stringVersion{}, nil, nil, WithVersionCheck())
+	assert.True(t, errors.Is(err, ErrInvalidVersionField))
+	_, _, err = UpdateStructFields(&stringVersion{}, &stringVersion{}, nil, true, false, WithVersionIncrement())
+	assert.True(t, errors.Is(err, ErrInvalidVersionField))
+
+	// merges without version options do not check the version field, but still never take it from the update
+	merged, err := MergeStructUpdateTo(&twoVersions{A: 1, B: 2}, &twoVersions{A: 3, B: 4}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &twoVersions{A: 1, B: 2}, merged, "B has no writexs tag")
+	entity := &stringVersion{V: "a"}
+	_, err = MergeMapStringFieldsToStruct(entity, map[string]any{"V": "b"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", entity.V)
+	_, _, err = UpdateStructFields(entity, &stringVersion{V: "b"}, nil, true, false)
+	assert.NoError(t, err)
+	_, err = MergeStructUpdateTo(&markedAccount{}, &markedAccount{}, nil, WithVersionCheck())
+	assert.True(t, errors.Is(err, ErrInvalidVersionField), "the options need a version field")
+}
@@ -0,0 +1,266 @@
+package struccy
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var xmlNameType = reflect.TypeOf(xml.Name{})
+
+// StructToXMLWithReadXS converts the specified struct pointer to an XML document,
+// including only the fields with read access allowed based on the provided xsList.
+// All `xml` tag features of encoding/xml are honored (attributes, chardata, `omitempty`,
+// nested element paths like `a>b`); fields of embedded structs are promoted. The root element
+// is named by the XMLName field if there is one, otherwise by the struct type name.
+// Nested values are encoded as a whole, just like StructToJSONFieldsWithReadXS.
+//
+// If the provided `structPtr` is not a pointer to a struct, the function returns
+// an error (`ErrInvalidStructPointer`).
+func StructToXMLWithReadXS(structPtr any, xsList []string) (string, error) {
+	structValue := reflect.ValueOf(structPtr)
+	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
+		return "", ErrInvalidStructPointer
+	}
+	structValue = structValue.Elem()
+
+	projection := newXMLProjection(structValue, xsList, OpRead)
+	projected := reflect.New(projection.structType).Elem()
+	for i, plan := range projection.plans {
+		if value, ok := plan.value(structValue); ok {
+			projected.Field(i).Set(value)
+		}
+	}
+
+	var xmlBytes []byte
+	var err error
+	if projection.hasXMLName {
+		xmlBytes, err = xml.Marshal(projected.Interface())
+	} else {
+		start := xml.StartElement{Name: xml.Name{Local: structValue.Type().Name()}}
+		xmlBytes, err = marshalXMLElement(projected.Interface(), start)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(xmlBytes), nil
+}
+
+// DecodeXMLWithWriteXS decodes an XML document into the target struct pointer, setting only
+// the fields with write access allowed based on the provided xsList; elements and attributes of
+// other fields are ignored. Fields not present in the document keep their values.
+// The `xml` tags are honored like in StructToXMLWithReadXS.
+//
+// The decoded struct is checked against its `validate` tags (see ValidateStruct); failures
+// are returned as a *FieldErrors. Malformed XML is returned as the error of encoding/xml.
+func DecodeXMLWithWriteXS(data []byte, targetStruct any, xsList []string) error {
+	targetValue := reflect.ValueOf(targetStruct)
+	if targetValue.Kind() != reflect.Ptr || targetValue.Elem().Kind() != reflect.Struct {
+		return ErrInvalidStructPointer
+	}
+	structValue := targetValue.Elem()
+
+	projection := newXMLProjection(structValue, xsList, OpWrite)
+	projected := reflect.New(projection.structType)
+	for i, plan := range projection.plans {
+		// encoding/xml appends to slices, so they start empty and are only copied back if decoded
+		if value, ok := plan.value(structValue); ok && value.Kind() != reflect.Slice {
+			projected.Elem().Field(i).Set(value)
+		}
+	}
+	if err := xml.Unmarshal(data, projected.Interface()); err != nil {
+		return err
+	}
+	for i, plan := range projection.plans {
+		value := projected.Elem().Field(i)
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			continue
+		}
+		fieldByIndexAlloc(structValue, plan.index).Set(value)
+	}
+
+	fieldErrs := &FieldErrors{}
+	validateStructValue(structValue, xsList, "", "", fieldErrs)
+	return fieldErrs.errOrNil()
+}
+
+// xmlProjection is a struct type holding only the fields the roles may access, with their original xml tags.
+// The `readif`/`writeif` conditions are evaluated on the struct value it is created for.
+type xmlProjection struct {
+	structType reflect.Type
+	plans      []*fieldPlan // the original field of every field of structType
+	hasXMLName bool
+}
+
+func newXMLProjection(structValue reflect.Value, roles []string, op Operation) *xmlProjection {
+	projection := &xmlProjection{}
+	plans := taggedFieldPlans(structValue.Type(), tagKeyXML, JSONFieldNames)
+	fields := make([]reflect.StructField, 0, len(plans))
+	goNames := make(map[string]bool, len(plans))
+	for i := range plans {
+		plan := &plans[i]
+		isXMLName := isXMLNameField(plan.field)
+		if !isXMLName && !plan.allowed(structValue, roles, op) {
+			continue
+		}
+		projection.hasXMLName = projection.hasXMLName || isXMLName
+		projection.plans = append(projection.plans, plan)
+		field := reflect.StructField{Name: plan.field.Name, Type: plan.field.Type, Tag: plan.field.Tag}
+		if goNames[field.Name] {
+			// promoted fields may share a Go name, the tag keeps the xml name they get from it
+			field.Name = fmt.Sprintf("%s_%d", field.Name, i)
+			field.Tag = reflect.StructTag(fmt.Sprintf("xml:%q", xmlTagWithName(plan.field)))
+		}
+		goNames[field.Name] = true
+		fields = append(fields, field)
+	}
+	projection.structType = reflect.StructOf(fields)
+	return projection
+}
+
+// xmlFieldPlans returns the fields of the struct type as seen by encoding/xml: embedded structs without
+// a tag name are flattened, and a promoted field is dropped if a shallower field has the same xml name,
+// element path and mode. Unlike with JSON, elements and attributes of the same name do not conflict,
+// and conflicts on the same depth are kept, so that encoding/xml reports them.
+func xmlFieldPlans(structType reflect.Type) []fieldPlan {
+	candidates := fieldCandidates(structType, tagKeyXML, JSONFieldNames)
+	infos := make([]xmlFieldInfo, len(candidates))
+	for i := range candidates {
+		infos[i] = newXMLFieldInfo(candidates[i].field)
+	}
+	plans := make([]fieldPlan, 0, len(candidates))
+	hasXMLName := false
+	for i := range candidates {
+		if isXMLNameField(candidates[i].field) {
+			// the candidates are ordered by depth, the shallowest XMLName names the element
+			if !hasXMLName {
+				plans = append(plans, candidates[i].fieldPlan)
+				hasXMLName = true
+			}
+			continue
+		}
+		shadowed := false
+		for j := range candidates {
+			if candidates[j].depth < candidates[i].depth && !isXMLNameField(candidates[j].field) && infos[j].conflicts(&infos[i]) {
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed {
+			plans = append(plans, candidates[i].fieldPlan)
+		}
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		return lessIndex(plans[i].index, plans[j].index)
+	})
+	return plans
+}
+
+func isXMLNameField(field reflect.StructField) bool {
+	return field.Name == "XMLName" && field.Type == xmlNameType
+}
+
+// xmlFieldInfo holds what encoding/xml compares to find conflicting fields.
+type xmlFieldInfo struct {
+	xmlns   string
+	name    string
+	parents []string
+	mode    string // the mode options (attr, chardata, ...), empty for elements
+}
+
+func newXMLFieldInfo(field reflect.StructField) xmlFieldInfo {
+	info := xmlFieldInfo{}
+	tag := field.Tag.Get(tagKeyXML)
+	if ns, rest, ok := strings.Cut(tag, " "); ok {
+		info.xmlns, tag = ns, rest
+	}
+	tagName, options, _ := strings.Cut(tag, ",")
+	for _, option := range strings.Split(options, ",") {
+		switch option {
+		case "attr", "cdata", "chardata", "innerxml", "comment", "any":
+			info.mode += option + ","
+		}
+	}
+	switch {
+	case tagName == "":
+		info.name = field.Name
+		if xmlns, name, ok := xmlTypeName(field.Type); ok {
+			info.xmlns, info.name = xmlns, name
+		}
+	default:
+		path := strings.Split(tagName, ">")
+		if path[0] == "" {
+			path[0] = field.Name
+		}
+		info.name = path[len(path)-1]
+		info.parents = path[:len(path)-1]
+	}
+	return info
+}
+
+// conflicts reports whether encoding/xml considers the fields to conflict.
+func (a *xmlFieldInfo) conflicts(b *xmlFieldInfo) bool {
+	if a.mode != b.mode || (a.xmlns != "" && b.xmlns != "" && a.xmlns != b.xmlns) {
+		return false
+	}
+	for p := 0; p < len(a.parents) && p < len(b.parents); p++ {
+		if a.parents[p] != b.parents[p] {
+			return false
+		}
+	}
+	switch {
+	case len(a.parents) > len(b.parents):
+		return a.parents[len(b.parents)] == b.name
+	case len(a.parents) < len(b.parents):
+		return b.parents[len(a.parents)] == a.name
+	}
+	return a.name == b.name && a.xmlns == b.xmlns
+}
+
+// xmlTypeName returns the element name given by the XMLName field of the (pointed to) struct type.
+func xmlTypeName(t reflect.Type) (xmlns string, name string, ok bool) {
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct {
+		return "", "", false
+	}
+	field, found := t.FieldByName("XMLName")
+	if !found || field.Type != xmlNameType {
+		return "", "", false
+	}
+	tagName, _, _ := strings.Cut(field.Tag.Get(tagKeyXML), ",")
+	if tagName == "" {
+		return "", "", false
+	}
+	if ns, local, hasNS := strings.Cut(tagName, " "); hasNS {
+		return ns, local, true
+	}
+	return "", tagName, true
+}
+
+// xmlTagWithName returns the xml tag of the field with the element or attribute name it has by default
+// spelled out, so the field can be renamed. Other modes do not depend on the field name.
+func xmlTagWithName(field reflect.StructField) string {
+	tag := field.Tag.Get(tagKeyXML)
+	if mode := newXMLFieldInfo(field).mode; mode != "" && mode != "attr," {
+		return tag
+	}
+	if _, _, ok := xmlTypeName(field.Type); ok || (tag != "" && !strings.HasPrefix(tag, ",")) {
+		return tag
+	}
+	return field.Name + tag
+}
+
+func marshalXMLElement(v any, start xml.StartElement) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := xml.NewEncoder(&buf)
+	if err := encoder.EncodeElement(v, start); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,131 @@
+package struccy
+
+import (
+	"encoding/xml"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type xmlAudit struct {
+	Revision int `xml:"revision,attr" readxs:"*" writexs:"admin"`
+}
+
+type xmlPartnerOrder struct {
+	XMLName xml.Name `xml:"order"`
+	xmlAudit
+	ID       string   `xml:"id,attr" readxs:"*" writexs:"admin"`
+	Status   string   `xml:"status" readxs:"*" writexs:"partner,admin" validate:"oneof=new shipped"`
+	City     string   `xml:"shipping>address>city" readxs:"*" writexs:"partner"`
+	Items    []string `xml:"items>item" readxs:"*" writexs:"partner"`
+	Margin   float64  `xml:"margin,omitempty" readxs:"admin" writexs:"admin"`
+	Note     string   `xml:",chardata" readxs:"*" writexs:"partner"`
+	Internal string   `xml:"-" readxs:"*" writexs:"*"`
+}
+
+func TestStructToXMLWithReadXS(t *testing.T) {
+	order := &xmlPartnerOrder{
+		xmlAudit: xmlAudit{Revision: 3},
+		ID:       "o-1",
+		Status:   "new",
+		City:     "Berlin",
+		Items:    []string{"pen", "ink"},
+		Margin:   0.25,
+		Note:     "fragile",
+		Internal: "x",
+	}
+
+	xmlStr, err := StructToXMLWithReadXS(order, []string{"partner"})
+	assert.NoError(t, err)
+	assert.Equal(t, `<order revision="3" id="o-1"><status>new</status><shipping><address><city>Berlin</city></address></shipping><items><item>pen</item><item>ink</item></items>fragile</order>`, xmlStr)
+
+	xmlStr, err = StructToXMLWithReadXS(order, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Contains(t, xmlStr, "<margin>0.25</margin>")
+
+	// without XMLName the type name is the root element
+	xmlStr, err = StructToXMLWithReadXS(&xmlAudit{Revision: 1}, []string{"partner"})
+	assert.NoError(t, err)
+	assert.Equal(t, `<xmlAudit revision="1"></xmlAudit>`, xmlStr)
+
+	// so anonymous types need an XMLName
+	address := &struct {
+		City string `xml:"city" readxs:"*"`
+	}{City: "Paris"}
+	_, err = StructToXMLWithReadXS(address, nil)
+	assert.Error(t, err)
+
+	_, err = StructToXMLWithReadXS(*order, nil)
+	assert.Equal(t, ErrInvalidStructPointer, err)
+}
+
+type xmlItemBase struct {
+	Name  string `xml:"name" readxs:"*" writexs:"*"`
+	Label string `xml:"label" readxs:"*" writexs:"*"`
+}
+
+type xmlItem struct {
+	XMLName xml.Name `xml:"item"`
+	AttrID  string   `xml:"id,attr" readxs:"*" writexs:"*"`
+	ID      string   `xml:"id" readxs:"*" writexs:"*"`
+	xmlItemBase
+	Label string `xml:"label" readxs:"admin" writexs:"admin"`
+}
+
+func TestXMLFieldsSharingNames(t *testing.T) {
+	item := &xmlItem{AttrID: "a", ID: "e", xmlItemBase: xmlItemBase{Name: "n", Label: "promoted"}, Label: "own"}
+	expected, err := xml.Marshal(item)
+	assert.NoError(t, err)
+	assert.Equal(t, `<item id="a"><id>e</id><name>n</name><label>own</label></item>`, string(expected))
+
+	// attributes and elements of the same name are separate fields, like with encoding/xml
+	xmlStr, err := StructToXMLWithReadXS(item, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), xmlStr)
+
+	// the label of the embedded struct stays shadowed when the outer one is not readable
+	xmlStr, err = StructToXMLWithReadXS(item, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `<item id="a"><id>e</id><name>n</name></item>`, xmlStr)
+
+	decoded := &xmlItem{}
+	assert.NoError(t, DecodeXMLWithWriteXS(expected, decoded, []string{"admin"}))
+	assert.Equal(t, "a", decoded.AttrID)
+	assert.Equal(t, "e", decoded.ID)
+	assert.Equal(t, xmlItemBase{Name: "n"}, decoded.xmlItemBase)
+	assert.Equal(t, "own", decoded.Label)
+}
+
+func TestDecodeXMLWithWriteXS(t *testing.T) {
+	data := []byte(`<order revision="9" id="hacked"><status>shipped</status><shipping><address><city>Rome</city></address></shipping><items><item>cup</item></items><margin>1</margin>handle with care</order>`)
+	order := &xmlPartnerOrder{
+		xmlAudit: xmlAudit{Revision: 3},
+		ID:       "o-1",
+		Status:   "new",
+		Items:    []string{"pen"},
+		Margin:   0.25,
+		Internal: "x",
+	}
+	err := DecodeXMLWithWriteXS(data, order, []string{"partner"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, order.Revision)
+	assert.Equal(t, "o-1", order.ID)
+	assert.Equal(t, "shipped", order.Status)
+	assert.Equal(t, "Rome", order.City)
+	assert.Equal(t, []string{"cup"}, order.Items)
+	assert.Equal(t, 0.25, order.Margin)
+	assert.Equal(t, "handle with care", order.Note)
+	assert.Equal(t, "x", order.Internal)
+
+	// missing elements keep their values
+	assert.NoError(t, DecodeXMLWithWriteXS([]byte(`<order><status>new</status></order>`), order, []string{"partner"}))
+	assert.Equal(t, "Rome", order.City)
+	assert.Equal(t, []string{"cup"}, order.Items)
+
+	err = DecodeXMLWithWriteXS([]byte(`<order><status>lost</status></order>`), order, []string{"partner"})
+	assert.True(t, errors.Is(err, ErrValidationFailed))
+
+	assert.Error(t, DecodeXMLWithWriteXS([]byte(`<order><status>`), order, nil))
+	assert.Equal(t, ErrInvalidStructPointer, DecodeXMLWithWriteXS(nil, xmlPartnerOrder{}, nil))
+}
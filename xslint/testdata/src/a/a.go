@@ -0,0 +1,28 @@
+package a
+
+type User struct {
+	ID       string `readxs:"*" writexs:"admin"`
+	Name     string `readxs:"*" writexs:"amdin"`                // want `writexs references unknown role "amdin", did you mean "admin"\?`
+	Email    string `readxs:"self,admin" writexs:"admin, self"` // want `writexs entry " self" contains whitespace`
+	Phone    string `readxs:"self,,admin" writexs:"self"`       // want `readxs tag "self,,admin" has an empty entry`
+	Role     string `readxs:"*,admin" writexs:"admin"`          // want `readxs entry "\*" only works as the whole tag value`
+	Password string `writexs:"self"`                            // want `field Password has a writexs tag but no readxs tag`
+	Status   string // want `exported field Status has no readxs or writexs tag`
+	Notes    string `readxs:"admin,!admin" writexs:""`         // want `readxs both allows and denies role "admin"` `empty writexs tag denies access to all roles`
+	Tags     string `readxs:"user,!guest" writexs:"user,user"` // want `readxs allows role "user" next to a negation` `writexs lists "user" more than once`
+	Score    int    `readxs:"admin" writexs:"admin|self"`      // want `writexs entry "admin\|self" is not a valid role`
+	Slug     string `readxs:"*" writexs:"admin,@immutable"`
+	Created  string `readxs:"*" writexs:"@system"`
+	Version  int    `readxs:"*" writexs:"admin,@readonly"` // want `writexs roles have no effect next to @readonly`
+	Handle   string `readxs:"*" writexs:"@immutabel"`      // want `writexs marker "@immutabel" is unknown`
+	internal string
+	Embedded
+}
+
+type Embedded struct {
+	Revision int
+}
+
+type Untagged struct {
+	Name string
+}
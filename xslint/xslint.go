@@ -0,0 +1,265 @@
+// Package xslint provides a go/analysis analyzer checking the `readxs` and `writexs` struct tags
+// of struccy. Run it with the struccy-lint command, standalone or through go vet:
+//
+//	go vet -vettool=$(which struccy-lint) -roles=admin,user,self ./...
+//
+// It reports:
+//   - malformed entries, like empty entries, whitespace (`admin, user` grants the role " user"),
+//     invalid characters or `*` combined with other entries (`*` only works on its own),
+//   - roles missing from the vocabulary configured with -roles,
+//   - exported fields without `readxs` and `writexs` tags in structs that use them,
+//   - fields with a `writexs` tag but no `readxs` tag,
+//   - entries that contradict each other, like `admin,!admin`, duplicates and roles that are
+//     allowed next to a negation (with a negation every role but the negated ones is allowed),
+//   - unknown `writexs` markers and roles next to `@system` or `@readonly`, which no role may write.
+package xslint
+
+import (
+	"fmt"
+	"go/ast"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const (
+	tagNameReadXS  = "readxs"
+	tagNameWriteXS = "writexs"
+)
+
+// Analyzer checks the readxs and writexs struct tags.
+var Analyzer = &analysis.Analyzer{
+	Name:     "xslint",
+	Doc:      "check readxs/writexs struct tags for syntax errors, unknown roles and contradictions",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// roles is the vocabulary set with the -roles flag; no role is reported as unknown if it is empty.
+var roles string
+
+func init() {
+	Analyzer.Flags.StringVar(&roles, "roles", "", "comma separated list of the known roles")
+}
+
+var rolePattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+// markers are the markers struccy accepts in writexs tags, and whether they make the field unwritable for all roles.
+var markers = map[string]bool{"@immutable": false, "@system": true, "@readonly": true}
+
+func run(pass *analysis.Pass) (any, error) {
+	vocabulary := parseVocabulary(roles)
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.StructType)(nil)}, func(node ast.Node) {
+		checkStruct(pass, node.(*ast.StructType), vocabulary)
+	})
+	return nil, nil
+}
+
+func parseVocabulary(list string) map[string]bool {
+	vocabulary := make(map[string]bool)
+	for _, role := range strings.Split(list, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			vocabulary[role] = true
+		}
+	}
+	return vocabulary
+}
+
+func checkStruct(pass *analysis.Pass, structType *ast.StructType, vocabulary map[string]bool) {
+	type taggedField struct {
+		field *ast.Field
+		tag   reflect.StructTag
+	}
+	fields := make([]taggedField, 0, len(structType.Fields.List))
+	usesTags := false
+	for _, field := range structType.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			if unquoted, err := strconv.Unquote(field.Tag.Value); err == nil {
+				tag = reflect.StructTag(unquoted)
+			}
+		}
+		_, hasRead := tag.Lookup(tagNameReadXS)
+		_, hasWrite := tag.Lookup(tagNameWriteXS)
+		usesTags = usesTags || hasRead || hasWrite
+		fields = append(fields, taggedField{field: field, tag: tag})
+	}
+	if !usesTags {
+		return
+	}
+
+	for _, tagged := range fields {
+		readXS, hasRead := tagged.tag.Lookup(tagNameReadXS)
+		writeXS, hasWrite := tagged.tag.Lookup(tagNameWriteXS)
+		names := fieldNames(tagged.field)
+		if hasRead {
+			checkTagValue(pass, tagged.field, tagNameReadXS, readXS, vocabulary)
+		}
+		if hasWrite {
+			checkTagValue(pass, tagged.field, tagNameWriteXS, writeXS, vocabulary)
+		}
+		// embedded fields are promoted, their own fields carry the tags
+		if len(tagged.field.Names) == 0 || !anyExported(tagged.field.Names) {
+			continue
+		}
+		switch {
+		case !hasRead && !hasWrite:
+			pass.Reportf(tagged.field.Pos(), "exported field %s has no readxs or writexs tag, so no role may access it", names)
+		case hasWrite && !hasRead:
+			pass.Reportf(tagged.field.Pos(), "field %s has a writexs tag but no readxs tag, so roles may write it but never read it", names)
+		}
+	}
+}
+
+// checkTagValue reports the problems of a single readxs or writexs tag value.
+func checkTagValue(pass *analysis.Pass, field *ast.Field, tagName string, value string, vocabulary map[string]bool) {
+	pos := field.Tag.Pos()
+	if value == "" {
+		pass.Reportf(pos, "empty %s tag denies access to all roles", tagName)
+		return
+	}
+	if tagName == tagNameWriteXS {
+		if value = checkMarkers(pass, field, value); value == "" {
+			return
+		}
+	}
+	if value == "*" {
+		return
+	}
+
+	entries := strings.Split(value, ",")
+	allowed := make(map[string]bool)
+	denied := make(map[string]bool)
+	var allowedOrder []string
+	for _, entry := range entries {
+		switch {
+		case entry == "":
+			pass.Reportf(pos, "%s tag %q has an empty entry", tagName, value)
+			continue
+		case strings.TrimSpace(entry) != entry:
+			pass.Reportf(pos, "%s entry %q contains whitespace and only matches a role spelled exactly like that", tagName, entry)
+			continue
+		case entry == "*" || entry == "!*":
+			pass.Reportf(pos, "%s entry %q only works as the whole tag value", tagName, entry)
+			continue
+		}
+
+		role, negated := strings.CutPrefix(entry, "!")
+		if !rolePattern.MatchString(role) {
+			pass.Reportf(pos, "%s entry %q is not a valid role", tagName, entry)
+			continue
+		}
+		if len(vocabulary) > 0 && !vocabulary[role] {
+			pass.Reportf(pos, "%s references unknown role %q%s", tagName, role, suggestRole(role, vocabulary))
+		}
+
+		seen := allowed
+		if negated {
+			seen = denied
+		}
+		if seen[role] {
+			pass.Reportf(pos, "%s lists %q more than once", tagName, entry)
+		}
+		seen[role] = true
+		if !negated {
+			allowedOrder = append(allowedOrder, role)
+		}
+	}
+
+	for _, role := range allowedOrder {
+		if denied[role] {
+			pass.Reportf(pos, "%s both allows and denies role %q", tagName, role)
+		} else if len(denied) > 0 {
+			pass.Reportf(pos, "%s allows role %q next to a negation, which already allows every role that is not negated", tagName, role)
+		}
+	}
+}
+
+// checkMarkers reports unknown writexs markers and roles next to markers that no role may write,
+// and returns the role entries of the tag value.
+func checkMarkers(pass *analysis.Pass, field *ast.Field, value string) string {
+	var entries []string
+	unwritable := ""
+	for _, entry := range strings.Split(value, ",") {
+		if !strings.HasPrefix(entry, "@") {
+			entries = append(entries, entry)
+			continue
+		}
+		denies, ok := markers[entry]
+		if !ok {
+			pass.Reportf(field.Tag.Pos(), "writexs marker %q is unknown, use @immutable, @system or @readonly", entry)
+			continue
+		}
+		if denies {
+			unwritable = entry
+		}
+	}
+	if unwritable != "" && len(entries) > 0 {
+		pass.Reportf(field.Tag.Pos(), "writexs roles have no effect next to %s, which no role may write", unwritable)
+		return ""
+	}
+	return strings.Join(entries, ",")
+}
+
+// suggestRole returns a hint naming the closest known role, if one is close enough to be a typo.
+func suggestRole(role string, vocabulary map[string]bool) string {
+	best, bestDistance := "", 3
+	for known := range vocabulary {
+		if distance := editDistance(role, known); distance < bestDistance || (distance == bestDistance && known < best) {
+			best, bestDistance = known, distance
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+// editDistance returns the Damerau-Levenshtein distance (with adjacent transpositions) of a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
+
+func fieldNames(field *ast.Field) string {
+	names := make([]string, len(field.Names))
+	for i, name := range field.Names {
+		names[i] = name.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func anyExported(names []*ast.Ident) bool {
+	for _, name := range names {
+		if name.IsExported() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package xslint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	assert.NoError(t, Analyzer.Flags.Set("roles", "admin,self,user,guest"))
+	defer Analyzer.Flags.Set("roles", "")
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "a")
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"admin", "admin", 0},
+		{"amdin", "admin", 1},
+		{"admn", "admin", 1},
+		{"user", "self", 3},
+		{"", "self", 4},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, editDistance(tt.a, tt.b), "%q vs %q", tt.a, tt.b)
+	}
+}
@@ -0,0 +1,72 @@
+package struccy
+
+import (
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StructToYAMLWithReadXS converts the specified struct pointer to a YAML document,
+// including only the fields with read access allowed based on the provided xsList.
+// Keys follow the `yaml` tag (name, `-`, `omitempty`, `inline`); fields without a tag name
+// are keyed by their lower-cased Go name like gopkg.in/yaml.v3 does, unless another naming
+// is selected with WithFieldNaming. Fields are written in struct order, and nested values
+// are encoded as a whole, just like StructToJSONFieldsWithReadXS.
+//
+// If the provided `structPtr` is not a pointer to a struct, the function returns
+// an error (`ErrInvalidStructPointer`).
+func StructToYAMLWithReadXS(structPtr any, xsList []string, opts ...ConvertOption) (string, error) {
+	structValue := reflect.ValueOf(structPtr)
+	if structValue.Kind() != reflect.Ptr || structValue.Elem().Kind() != reflect.Struct {
+		return "", ErrInvalidStructPointer
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	var encodeErr error
+	include := func(plan *fieldPlan) bool {
+		return encodeErr == nil && plan.allowed(structValue.Elem(), xsList, OpRead)
+	}
+	eachIncludedField(structValue.Elem(), tagKeyYAML, yamlFieldNaming(opts), false, include, func(plan *fieldPlan, value reflect.Value) {
+		valueNode := &yaml.Node{}
+		if encodeErr = valueNode.Encode(value.Interface()); encodeErr != nil {
+			return
+		}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: plan.name}
+		doc.Content = append(doc.Content, keyNode, valueNode)
+	})
+	if encodeErr != nil {
+		return "", encodeErr
+	}
+
+	yamlBytes, err := yaml.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(yamlBytes), nil
+}
+
+// DecodeYAMLWithWriteXS decodes a YAML document into the target struct pointer, setting only
+// the fields with write access allowed based on the provided xsList; other keys are ignored,
+// as are keys without a matching field. Keys are matched like in StructToYAMLWithReadXS.
+//
+// Fields that cannot be decoded do not abort the decoding; they are returned together as a
+// *FieldErrors with ErrInvalidFieldValue as cause, along with the failures of the `validate` tags.
+func DecodeYAMLWithWriteXS(data []byte, targetStruct any, xsList []string, opts ...ConvertOption) error {
+	var doc map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	return decodeFieldsWithWriteXS(targetStruct, xsList, tagKeyYAML, yamlFieldNaming(opts), false, sortedMapKeys(doc), func(key string, target any) error {
+		node := doc[key]
+		return node.Decode(target)
+	})
+}
+
+// yamlFieldNaming returns the naming selected with WithFieldNaming, defaulting to the yaml.v3 naming.
+func yamlFieldNaming(opts []ConvertOption) FieldNaming {
+	options := newConvertOptions(opts)
+	if !options.namingSet {
+		return LowerCaseFieldNames
+	}
+	return options.naming
+}
@@ -0,0 +1,95 @@
+package struccy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantLimits struct {
+	Users   int `yaml:"users" toml:"users"`
+	Storage int `yaml:"storage" toml:"storage"`
+}
+
+type tenantBase struct {
+	ID string `yaml:"id" toml:"id" readxs:"*"`
+}
+
+type tenantSettings struct {
+	tenantBase `yaml:",inline"`
+	Name       string       `yaml:"name" toml:"name" readxs:"*" writexs:"owner,admin" validate:"required"`
+	Theme      string       `yaml:"theme,omitempty" toml:"theme,omitempty" readxs:"*" writexs:"owner,admin"`
+	Plan       string       `yaml:"plan" toml:"plan" readxs:"*" writexs:"admin"`
+	APIKey     string       `yaml:"api_key" toml:"api_key" readxs:"admin" writexs:"admin"`
+	Limits     tenantLimits `yaml:"limits" toml:"limits" readxs:"owner,admin" writexs:"admin"`
+	Internal   string       `yaml:"-" toml:"-" readxs:"*" writexs:"*"`
+	MaxSeats   int          `readxs:"*" writexs:"owner,admin"`
+}
+
+func TestStructToYAMLWithReadXS(t *testing.T) {
+	settings := &tenantSettings{
+		tenantBase: tenantBase{ID: "t1"},
+		Name:       "Acme",
+		Plan:       "pro",
+		APIKey:     "secret",
+		Limits:     tenantLimits{Users: 10, Storage: 5},
+		Internal:   "x",
+		MaxSeats:   3,
+	}
+
+	yamlStr, err := StructToYAMLWithReadXS(settings, []string{"viewer"})
+	assert.NoError(t, err)
+	assert.Equal(t, "id: t1\nname: Acme\nplan: pro\nmaxseats: 3\n", yamlStr)
+
+	yamlStr, err = StructToYAMLWithReadXS(settings, []string{"admin"}, WithFieldNaming(SnakeCaseFieldNames))
+	assert.NoError(t, err)
+	assert.Equal(t, "id: t1\nname: Acme\nplan: pro\napi_key: secret\nlimits:\n    users: 10\n    storage: 5\nmax_seats: 3\n", yamlStr)
+
+	_, err = StructToYAMLWithReadXS(*settings, nil)
+	assert.Equal(t, ErrInvalidStructPointer, err)
+}
+
+func TestDecodeYAMLWithWriteXS(t *testing.T) {
+	data := []byte(`
+id: t2
+name: Globex
+theme: dark
+plan: enterprise
+api_key: stolen
+limits:
+  users: 1000
+maxseats: 50
+unknown: 1
+`)
+	settings := &tenantSettings{Plan: "free"}
+	err := DecodeYAMLWithWriteXS(data, settings, []string{"owner"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", settings.ID)
+	assert.Equal(t, "Globex", settings.Name)
+	assert.Equal(t, "dark", settings.Theme)
+	assert.Equal(t, "free", settings.Plan)
+	assert.Equal(t, "", settings.APIKey)
+	assert.Equal(t, tenantLimits{}, settings.Limits)
+	assert.Equal(t, 50, settings.MaxSeats)
+
+	err = DecodeYAMLWithWriteXS(data, settings, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, "enterprise", settings.Plan)
+	assert.Equal(t, 1000, settings.Limits.Users)
+}
+
+func TestDecodeYAMLWithWriteXS_Errors(t *testing.T) {
+	settings := &tenantSettings{}
+	err := DecodeYAMLWithWriteXS([]byte("maxseats: many\ntheme: dark\n"), settings, []string{"owner"})
+	var fieldErrs *FieldErrors
+	assert.True(t, errors.As(err, &fieldErrs))
+	assert.Equal(t, 2, fieldErrs.Len())
+	assert.Equal(t, "MaxSeats", fieldErrs.Errors[0].Path)
+	assert.True(t, errors.Is(fieldErrs.Errors[0], ErrInvalidFieldValue))
+	assert.True(t, errors.Is(fieldErrs.Errors[1], ErrValidationFailed)) // name is required
+	assert.Equal(t, "dark", settings.Theme)
+
+	assert.Error(t, DecodeYAMLWithWriteXS([]byte("name: [unclosed"), settings, nil))
+	assert.Equal(t, ErrInvalidStructPointer, DecodeYAMLWithWriteXS([]byte("name: x"), tenantSettings{}, nil))
+}